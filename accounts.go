@@ -0,0 +1,170 @@
+package main
+
+import (
+	"log"
+	"net/url"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// AccountConfig holds the credentials of an account the bot runs as
+type AccountConfig struct {
+	MastodonServer string `toml:"mastodon_server"`
+	ClientSecret   string `toml:"client_secret"`
+	AccessToken    string `toml:"access_token"`
+	Username       string `toml:"username"`
+
+	// Overrides of the global settings, left empty to use the global value
+	ReplyVisibility string `toml:"reply_visibility"`
+	AskForConsent   *bool  `toml:"ask_for_consent"`
+	DefaultLanguage string `toml:"default_language"`
+}
+
+// botAccount is a Mastodon client together with the account it is logged in as. The live client
+// is kept apart for streaming, so the account can be driven by another MastodonClient in tests.
+type botAccount struct {
+	MastodonClient
+	live     *mastodon.Client
+	ID       mastodon.ID
+	Username string
+	Primary  bool
+	Config   AccountConfig
+}
+
+// connectAccount creates a client for the account and verifies its account ID
+func connectAccount(account AccountConfig, primary bool) (*botAccount, error) {
+	c := mastodon.NewClient(&mastodon.Config{
+		Server:       account.MastodonServer,
+		ClientSecret: account.ClientSecret,
+		AccessToken:  account.AccessToken,
+	})
+
+	id, err := fetchAndVerifyBotAccountID(c, account.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	connected := &botAccount{MastodonClient: c, live: c, ID: id, Username: account.Username, Primary: primary, Config: account}
+	botAccountIDs[instanceKey(connected, id)] = true
+	botAccounts[account.Username] = connected
+	return connected, nil
+}
+
+// botAccounts holds the connected accounts by username, filled in before any stream starts
+var botAccounts = make(map[string]*botAccount)
+
+// accountByUsername returns the connected account with the username. Work recorded before there were
+// several accounts has no username and belongs to the primary account.
+func accountByUsername(username string) (*botAccount, bool) {
+	if username == "" {
+		username = config.Server.Username
+	}
+	account, ok := botAccounts[username]
+	return account, ok
+}
+
+// streamAccount handles the streaming events of an additional account. Like the main
+// account's stream, a closed stream ends the process so a supervisor can restart it.
+func streamAccount(account *botAccount) {
+	events, err := account.live.NewWSClient().StreamingWSUser(ctx)
+	if err != nil {
+		log.Fatalf("Error connecting to streaming API for %s: %v", account.Username, err)
+	}
+
+	if config.Behavior.CatchUpOnStartup {
+		catchUpNotifications(account)
+	}
+	handleEvents(account, events)
+	log.Fatalf("Streaming connection of %s closed", account.Username)
+}
+
+// accountUsername returns the username of the account the client is logged in as
+func accountUsername(c MastodonClient) string {
+	if account, ok := c.(*botAccount); ok && account.Username != "" {
+		return account.Username
+	}
+	return config.Server.Username
+}
+
+// accountServer returns the server the client is logged in to
+func accountServer(c MastodonClient) string {
+	if account, ok := c.(*botAccount); ok && account.Config.MastodonServer != "" {
+		return account.Config.MastodonServer
+	}
+	return config.Server.MastodonServer
+}
+
+// serverHost returns the host name of a server URL
+func serverHost(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return server
+}
+
+// instanceKey qualifies an ID with the server the client is logged in to, since Mastodon IDs are
+// only unique within an instance. IDs of the primary account's server are used as they are, so
+// state saved before there were several accounts stays valid.
+func instanceKey(c MastodonClient, id mastodon.ID) string {
+	server := accountServer(c)
+	if server == config.Server.MastodonServer {
+		return string(id)
+	}
+	return string(id) + "@" + serverHost(server)
+}
+
+// accountKey qualifies an ID with the account the client is logged in as, for state that every
+// account keeps for itself, like its replies and followers
+func accountKey(c MastodonClient, id mastodon.ID) string {
+	if account, ok := c.(*botAccount); ok && !account.Primary {
+		return string(id) + "/" + account.Username + "@" + serverHost(accountServer(c))
+	}
+	return string(id)
+}
+
+// isPrimaryAccount reports whether the client belongs to the account configured in [server].
+// Scheduled features like the alt-text reminders only run for that account.
+func isPrimaryAccount(c MastodonClient) bool {
+	account, ok := c.(*botAccount)
+	return !ok || account.Primary
+}
+
+// accountSettings are the settings an account can override
+type accountSettings struct {
+	ReplyVisibility string
+	AskForConsent   bool
+	DefaultLanguage string
+}
+
+// settingsFor resolves the settings of the account the client is logged in as,
+// layering the account's overrides over the global configuration
+func settingsFor(c MastodonClient) accountSettings {
+	settings := accountSettings{
+		ReplyVisibility: config.Behavior.ReplyVisibility,
+		AskForConsent:   config.Behavior.AskForConsent,
+		DefaultLanguage: config.Localization.DefaultLanguage,
+	}
+
+	account, ok := c.(*botAccount)
+	if !ok {
+		return settings
+	}
+	if account.Config.ReplyVisibility != "" {
+		settings.ReplyVisibility = account.Config.ReplyVisibility
+	}
+	if account.Config.AskForConsent != nil {
+		settings.AskForConsent = *account.Config.AskForConsent
+	}
+	if account.Config.DefaultLanguage != "" {
+		settings.DefaultLanguage = account.Config.DefaultLanguage
+	}
+	return settings
+}
+
+// accountLanguage returns lang if there are localizations for it, otherwise the account's default language
+func accountLanguage(c MastodonClient, lang string) string {
+	if _, ok := localizations[lang]; ok {
+		return lang
+	}
+	return settingsFor(c).DefaultLanguage
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mattn/go-mastodon"
+)
+
+// testAccounts returns a primary account and a second account on another server,
+// each driven by its own fake client
+func testAccounts(t *testing.T) (primary, second *botAccount, primaryClient, secondClient *fakeClient) {
+	t.Helper()
+	withConfig(t, func(c *Config) {
+		c.Server.MastodonServer = "https://main.example"
+		c.Behavior.FollowBack = true
+	})
+
+	primaryClient, secondClient = newFakeClient(), newFakeClient()
+	primary = &botAccount{MastodonClient: primaryClient, ID: "bot", Username: "altbot", Primary: true,
+		Config: AccountConfig{MastodonServer: "https://main.example"}}
+	second = &botAccount{MastodonClient: secondClient, ID: "bot", Username: "altbot2",
+		Config: AccountConfig{MastodonServer: "https://other.example"}}
+	return primary, second, primaryClient, secondClient
+}
+
+func TestKeysAreScopedToAccountAndInstance(t *testing.T) {
+	primary, second, _, _ := testAccounts(t)
+	sameServer := &botAccount{MastodonClient: newFakeClient(), Username: "altbot3",
+		Config: AccountConfig{MastodonServer: "https://main.example"}}
+
+	if got := instanceKey(primary, "1"); got != "1" {
+		t.Errorf("instanceKey(primary) = %q, want the bare ID", got)
+	}
+	if got := instanceKey(sameServer, "1"); got != "1" {
+		t.Errorf("instanceKey on the primary server = %q, want the bare ID", got)
+	}
+	if got := instanceKey(second, "1"); got != "1@other.example" {
+		t.Errorf("instanceKey(second) = %q, want 1@other.example", got)
+	}
+
+	if accountKey(primary, "1") == accountKey(sameServer, "1") {
+		t.Error("accounts on the same server share their account keys")
+	}
+	if accountKey(primary, "1") == accountKey(second, "1") {
+		t.Error("accounts on different servers share their account keys")
+	}
+}
+
+func TestIsSelfOnlyOnOwnInstance(t *testing.T) {
+	primary, second, _, _ := testAccounts(t)
+	key := instanceKey(second, "42")
+	botAccountIDs[key] = true
+	t.Cleanup(func() { delete(botAccountIDs, key) })
+
+	if !isSelf(second, &mastodon.Account{ID: "42"}) {
+		t.Error("the second account doesn't recognise itself")
+	}
+	if isSelf(primary, &mastodon.Account{ID: "42"}) {
+		t.Error("account 42 of the primary server is taken for the bot")
+	}
+}
+
+func TestDeleteEventReachesOnlyItsAccount(t *testing.T) {
+	primary, second, primaryClient, secondClient := testAccounts(t)
+
+	// Both accounts described a post that happens to have the same ID on both servers
+	mapMutex.Lock()
+	replyMap[accountKey(primary, "100")] = ReplyInfo{OriginalID: "100", ReplyID: "primary-reply"}
+	replyMap[accountKey(second, "100")] = ReplyInfo{OriginalID: "100", ReplyID: "second-reply"}
+	mapMutex.Unlock()
+	t.Cleanup(func() {
+		mapMutex.Lock()
+		delete(replyMap, accountKey(primary, "100"))
+		mapMutex.Unlock()
+	})
+
+	events := make(chan mastodon.Event, 1)
+	events <- &mastodon.DeleteEvent{ID: "100"}
+	close(events)
+	handleEvents(second, events)
+
+	if len(secondClient.deleted) != 1 || secondClient.deleted[0] != "second-reply" {
+		t.Errorf("second account deleted %v, want [second-reply]", secondClient.deleted)
+	}
+	if len(primaryClient.deleted) != 0 {
+		t.Errorf("primary account deleted %v", primaryClient.deleted)
+	}
+
+	mapMutex.Lock()
+	_, primaryKept := replyMap[accountKey(primary, "100")]
+	mapMutex.Unlock()
+	if !primaryKept {
+		t.Error("the primary account's reply was forgotten")
+	}
+}
+
+func TestFollowEventFollowsBackFromItsAccount(t *testing.T) {
+	_, second, primaryClient, secondClient := testAccounts(t)
+
+	events := make(chan mastodon.Event, 1)
+	events <- &mastodon.NotificationEvent{Notification: &mastodon.Notification{
+		ID: "routing-1", Type: "follow", Account: mastodon.Account{ID: "7", Acct: "person@example.social"},
+	}}
+	close(events)
+	handleEvents(second, events)
+
+	if len(secondClient.followed) != 1 || secondClient.followed[0] != "7" {
+		t.Errorf("second account followed %v, want [7]", secondClient.followed)
+	}
+	if len(primaryClient.followed) != 0 {
+		t.Errorf("primary account followed %v", primaryClient.followed)
+	}
+
+	// The follower is only known as a follower of the account they followed
+	followerCacheMutex.Lock()
+	_, primaryKnows := followerCache[accountKey(primaryClient, "7")]
+	_, secondKnows := followerCache[accountKey(second, "7")]
+	followerCacheMutex.Unlock()
+	if primaryKnows || !secondKnows {
+		t.Errorf("follower cached for primary %v, for second %v", primaryKnows, secondKnows)
+	}
+}
+
+func TestConcurrentConsentRequestsAskOnce(t *testing.T) {
+	primary, second, primaryClient, secondClient := testAccounts(t)
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+
+	status := &mastodon.Status{
+		ID:               "200",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image"}},
+	}
+	t.Cleanup(func() {
+		consentMutex.Lock()
+		delete(consentRequests, accountKey(primary, status.ID))
+		delete(consentRequests, accountKey(second, status.ID))
+		consentMutex.Unlock()
+	})
+	request := func(account *botAccount) {
+		requestConsent(account, status, &mastodon.Notification{
+			Account: mastodon.Account{ID: "requester", Acct: "requester"},
+			Status:  &mastodon.Status{ID: "mention", Language: "en"},
+		}, nil)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request(primary)
+		}()
+	}
+	wg.Wait()
+
+	if asked := len(primaryClient.postedToots()); asked != 1 {
+		t.Errorf("the poster was asked %d times, want once", asked)
+	}
+
+	// The same ID on another instance is another post, with its own consent request
+	request(second)
+	if asked := len(secondClient.postedToots()); asked != 1 {
+		t.Errorf("the poster on the other instance was asked %d times, want once", asked)
+	}
+}
+
+func TestThreadStateIsScopedByAccount(t *testing.T) {
+	primary, second, primaryClient, secondClient := testAccounts(t)
+	t.Cleanup(func() {
+		replyDepthMutex.Lock()
+		replyDepthCache = make(map[string]int)
+		replyDepthMutex.Unlock()
+	})
+
+	// Status 301 is a top-level post on the main instance, but a reply on the other one
+	primaryClient.statuses["301"] = &mastodon.Status{ID: "301"}
+	secondClient.statuses["300"] = &mastodon.Status{ID: "300"}
+	secondClient.statuses["301"] = &mastodon.Status{ID: "301", InReplyToID: "300"}
+
+	if depth := replyDepth(primary, &mastodon.Status{ID: "302", InReplyToID: "301"}, 10); depth != 1 {
+		t.Errorf("depth on the main instance = %d, want 1", depth)
+	}
+	if depth := replyDepth(second, &mastodon.Status{ID: "302", InReplyToID: "301"}, 10); depth != 2 {
+		t.Errorf("depth on the other instance = %d, want 2", depth)
+	}
+
+	withConfig(t, func(c *Config) { c.Patrol.MaxAgeMinutes = 0 })
+	post := func() []*mastodon.Status {
+		return []*mastodon.Status{{
+			ID:               "303",
+			Visibility:       "public",
+			Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+			MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image"}},
+		}}
+	}
+	t.Cleanup(func() {
+		patrolSeenMutex.Lock()
+		delete(patrolSeen, accountKey(primary, "303"))
+		delete(patrolSeen, accountKey(second, "303"))
+		patrolSeenMutex.Unlock()
+	})
+	if len(selectPatrolCandidates(primary, post(), time.Now())) != 1 || len(selectPatrolCandidates(second, post(), time.Now())) != 1 {
+		t.Error("a post seen by one account's patrol was skipped by the other's")
+	}
+}
+
+func TestAccountOverridesGlobalSettings(t *testing.T) {
+	primary, second, _, _ := testAccounts(t)
+	withConfig(t, func(c *Config) {
+		c.Behavior.ReplyVisibility = "public"
+		c.Behavior.AskForConsent = true
+		c.Localization.DefaultLanguage = "en"
+	})
+
+	var file struct {
+		Accounts []AccountConfig `toml:"accounts"`
+	}
+	_, err := toml.Decode(`
+[[accounts]]
+username = "altbot2"
+reply_visibility = "unlisted"
+ask_for_consent = false
+default_language = "de"
+`, &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Config = file.Accounts[0]
+
+	if got := settingsFor(primary); got.ReplyVisibility != "public" || !got.AskForConsent || got.DefaultLanguage != "en" {
+		t.Errorf("settingsFor(primary) = %+v, want the global settings", got)
+	}
+	if got := settingsFor(second); got.ReplyVisibility != "unlisted" || got.AskForConsent || got.DefaultLanguage != "de" {
+		t.Errorf("settingsFor(second) = %+v, want its overrides", got)
+	}
+
+	// A missing ask_for_consent keeps the global setting, unlike an explicit false
+	second.Config.AskForConsent = nil
+	if !settingsFor(second).AskForConsent {
+		t.Error("an account without ask_for_consent doesn't ask for consent")
+	}
+
+	if got := accountLanguage(second, "nl"); got != "de" {
+		t.Errorf("accountLanguage(second, nl) = %s, want the account's default de", got)
+	}
+	if got := accountLanguage(second, "fr"); got != "fr" {
+		t.Errorf("accountLanguage(second, fr) = %s, want fr", got)
+	}
+}
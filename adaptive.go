@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// AltTextCounts counts the media of an account's posts with and without alt-text
+type AltTextCounts struct {
+	Human   int `json:"human"`
+	Missing int `json:"missing"`
+}
+
+// AltTextStats tracks per account how often their media already has alt-text
+type AltTextStats struct {
+	Accounts map[string]AltTextCounts `json:"accounts"`
+	filePath string
+	mu       sync.Mutex
+}
+
+var altTextStats = AltTextStats{
+	Accounts: make(map[string]AltTextCounts),
+}
+
+// IsDiligent reports whether the account writes its own alt-text often enough to be skipped.
+// Accounts with fewer than adaptive_skip_min_media recorded media are never skipped.
+func (s *AltTextStats) IsDiligent(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.Accounts[userID]
+	total := counts.Human + counts.Missing
+	if total == 0 || total < config.Behavior.AdaptiveSkipMinMedia {
+		return false
+	}
+	return float64(counts.Human)/float64(total) >= config.Behavior.AdaptiveSkipThreshold
+}
+
+// Record adds the media of a post to the account's counts
+func (s *AltTextStats) Record(userID string, human, missing int) error {
+	if human == 0 && missing == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.Accounts[userID]
+	counts.Human += human
+	counts.Missing += missing
+	s.Accounts[userID] = counts
+
+	return s.saveToFile()
+}
+
+// LoadFromFile loads the stored stats
+func (s *AltTextStats) LoadFromFile(filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filePath = filePath
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist. Start fresh.
+		}
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
+
+func (s *AltTextStats) saveToFile() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filePath, data, 0644)
+}
+
+// countAltTexts counts the describable media of the status with and without alt-text
+func countAltTexts(status *mastodon.Status) (human, missing int) {
+	for _, attachment := range status.MediaAttachments {
+		if !isMediaTypeAllowed(attachment) {
+			continue
+		}
+		if attachment.Description != "" {
+			human++
+		} else if needsGeneration(attachment) {
+			missing++
+		}
+	}
+	return human, missing
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useAltTextStats starts the test with empty alt-text stats saved in a scratch file
+func useAltTextStats(t *testing.T) {
+	t.Helper()
+	altTextStats.mu.Lock()
+	savedAccounts, savedPath := altTextStats.Accounts, altTextStats.filePath
+	altTextStats.Accounts = make(map[string]AltTextCounts)
+	altTextStats.filePath = filepath.Join(t.TempDir(), "alt_text_stats.json")
+	altTextStats.mu.Unlock()
+	t.Cleanup(func() {
+		altTextStats.mu.Lock()
+		altTextStats.Accounts, altTextStats.filePath = savedAccounts, savedPath
+		altTextStats.mu.Unlock()
+	})
+}
+
+func TestIsDiligent(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.AdaptiveSkipThreshold = 0.9
+		c.Behavior.AdaptiveSkipMinMedia = 10
+	})
+	useAltTextStats(t)
+
+	tests := []struct {
+		name           string
+		human, missing int
+		want           bool
+	}{
+		{"never seen", 0, 0, false},
+		{"too few media", 5, 0, false},
+		{"at the threshold", 9, 1, true},
+		{"below the threshold", 8, 2, false},
+		{"always", 20, 0, true},
+	}
+	for _, tt := range tests {
+		if err := altTextStats.Record(tt.name, tt.human, tt.missing); err != nil {
+			t.Fatal(err)
+		}
+		if got := altTextStats.IsDiligent(tt.name); got != tt.want {
+			t.Errorf("IsDiligent(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAltTextStatsPersist(t *testing.T) {
+	useAltTextStats(t)
+	if err := altTextStats.Record("poster", 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := altTextStats.Record("poster", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded AltTextStats
+	if err := loaded.LoadFromFile(altTextStats.filePath); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Accounts["poster"]; got != (AltTextCounts{Human: 5, Missing: 1}) {
+		t.Errorf("loaded counts = %+v, want 5 human and 1 missing", got)
+	}
+}
+
+func TestCountAltTexts(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.AllowedMediaTypes = []string{"image"} })
+	status := &mastodon.Status{MediaAttachments: []mastodon.Attachment{
+		{Type: "image", Description: "A cat."},
+		{Type: "image"},
+		{Type: "image"},
+		{Type: "video"},
+	}}
+
+	if human, missing := countAltTexts(status); human != 1 || missing != 2 {
+		t.Errorf("countAltTexts = %d human, %d missing, want 1 and 2", human, missing)
+	}
+}
+
+func TestDiligentAccountsAreSkipped(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.EnableAdaptiveSkip = true
+		c.Behavior.AdaptiveSkipThreshold = 0.8
+		c.Behavior.AdaptiveSkipMinMedia = 5
+		c.Behavior.AutoDescribeDelaySeconds = 0
+		c.RateLimit.Enabled = false
+	})
+	useAltTextStats(t)
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+	c := newFakeClient()
+	c.relationships["diligent"] = &mastodon.Relationship{ID: "diligent", FollowedBy: true}
+
+	post := func(i int, description string) *mastodon.Status {
+		status := &mastodon.Status{
+			ID:               mastodon.ID(fmt.Sprintf("diligent-%d", i)),
+			Account:          mastodon.Account{ID: "diligent", Acct: "diligent"},
+			CreatedAt:        time.Now(),
+			Visibility:       "public",
+			MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", Description: description, URL: dataURI("image/png", testImage(t, 8, 8, color.White))}},
+		}
+		c.statuses[status.ID] = status
+		forgetReplies(t, c, status.ID)
+		return status
+	}
+
+	// Until enough media were seen, a missing description is filled in
+	handleUpdate(c, post(0, ""))
+	if provider.calls() != 1 {
+		t.Fatalf("the provider was called %d times for a new account, want 1", provider.calls())
+	}
+	for i := 1; i <= 5; i++ {
+		handleUpdate(c, post(i, "Written by the poster."))
+	}
+
+	handleUpdate(c, post(6, ""))
+	if provider.calls() != 1 {
+		t.Errorf("the provider was called %d times, want the diligent account's slip skipped", provider.calls())
+	}
+	if got := altTextStats.Accounts["diligent"]; got != (AltTextCounts{Human: 5, Missing: 2}) {
+		t.Errorf("recorded counts = %+v, want 5 human and 2 missing", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// blockedByRetention is how long an account that refused the bot's reply is skipped,
+// after which the bot tries again in case it was unblocked
+const blockedByRetention = 30 * 24 * time.Hour
+
+// BlockedBy remembers the accounts the bot couldn't reply to because they blocked or limited it
+type BlockedBy struct {
+	Accounts map[string]time.Time `json:"accounts"`
+	filePath string
+	mu       sync.Mutex
+}
+
+var blockedBy = BlockedBy{
+	Accounts: make(map[string]time.Time),
+}
+
+// blockedByKey identifies the account per bot account, a block only applies to the account that was blocked
+func blockedByKey(c MastodonClient, accountID mastodon.ID) string {
+	return accountUsername(c) + "/" + string(accountID)
+}
+
+// Add records that the account refused a reply of the bot account
+func (b *BlockedBy) Add(c MastodonClient, accountID mastodon.ID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Accounts[blockedByKey(c, accountID)] = time.Now()
+	return b.saveToFile()
+}
+
+// Contains reports whether the account refused a reply of the bot account recently
+func (b *BlockedBy) Contains(c MastodonClient, accountID mastodon.ID) bool {
+	if !config.Behavior.SkipBlockingAccounts {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	since, ok := b.Accounts[blockedByKey(c, accountID)]
+	return ok && time.Since(since) < blockedByRetention
+}
+
+// LoadFromFile loads the stored accounts, dropping the ones past the retention
+func (b *BlockedBy) LoadFromFile(filePath string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.filePath = filePath
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist. Start fresh.
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return err
+	}
+
+	for key, since := range b.Accounts {
+		if time.Since(since) >= blockedByRetention {
+			delete(b.Accounts, key)
+		}
+	}
+	return nil
+}
+
+func (b *BlockedBy) saveToFile() error {
+	if b.filePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.filePath, data, 0644)
+}
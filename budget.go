@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ErrBudgetExceeded is returned when the spending cap of the paid provider is reached and no fallback is configured
+var ErrBudgetExceeded = errors.New("provider budget exceeded")
+
+// paidProviders lists the providers whose usage counts against the budget
+var paidProviders = map[string]bool{"gemini": true}
+
+// BudgetUsage tracks the approximate spending on the paid provider for the current day and month
+type BudgetUsage struct {
+	Day           string  `json:"day"`
+	DailyCost     float64 `json:"daily_cost"`
+	DailyTokens   int64   `json:"daily_tokens"`
+	Month         string  `json:"month"`
+	MonthlyCost   float64 `json:"monthly_cost"`
+	MonthlyTokens int64   `json:"monthly_tokens"`
+	filePath      string
+	mu            sync.Mutex
+}
+
+var budgetUsage BudgetUsage
+
+// rollOver resets the counters when a new day or month has started
+func (bu *BudgetUsage) rollOver(now time.Time) {
+	if day := now.Format("2006-01-02"); bu.Day != day {
+		bu.Day = day
+		bu.DailyCost = 0
+		bu.DailyTokens = 0
+	}
+	if month := now.Format("2006-01"); bu.Month != month {
+		bu.Month = month
+		bu.MonthlyCost = 0
+		bu.MonthlyTokens = 0
+	}
+}
+
+// Record adds the tokens of a single call and persists the usage
+func (bu *BudgetUsage) Record(inputTokens, outputTokens int64) {
+	if !config.Budget.Enabled {
+		return
+	}
+
+	bu.mu.Lock()
+	defer bu.mu.Unlock()
+
+	bu.rollOver(time.Now())
+	cost := float64(inputTokens)/1e6*config.Budget.InputCostPerMillionTokens +
+		float64(outputTokens)/1e6*config.Budget.OutputCostPerMillionTokens
+
+	bu.DailyCost += cost
+	bu.DailyTokens += inputTokens + outputTokens
+	bu.MonthlyCost += cost
+	bu.MonthlyTokens += inputTokens + outputTokens
+
+	if err := bu.saveToFile(); err != nil {
+		log.Printf("Error saving budget usage: %v", err)
+	}
+}
+
+// Exceeded reports whether the daily or monthly budget has been used up
+func (bu *BudgetUsage) Exceeded() bool {
+	if !config.Budget.Enabled {
+		return false
+	}
+
+	bu.mu.Lock()
+	defer bu.mu.Unlock()
+
+	bu.rollOver(time.Now())
+	if config.Budget.DailyLimit > 0 && bu.DailyCost >= config.Budget.DailyLimit {
+		return true
+	}
+	return config.Budget.MonthlyLimit > 0 && bu.MonthlyCost >= config.Budget.MonthlyLimit
+}
+
+// LoadFromFile loads the usage persisted by a previous run
+func (bu *BudgetUsage) LoadFromFile(filePath string) error {
+	bu.mu.Lock()
+	defer bu.mu.Unlock()
+
+	bu.filePath = filePath
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist. Start fresh.
+		}
+		return err
+	}
+	return json.Unmarshal(data, bu)
+}
+
+func (bu *BudgetUsage) saveToFile() error {
+	data, err := json.Marshal(bu)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(bu.filePath, data, 0644)
+}
+
+// recordGeminiUsage adds the token usage reported in a Gemini response to the budget
+func recordGeminiUsage(resp *genai.GenerateContentResponse) {
+	if resp == nil || resp.UsageMetadata == nil {
+		return
+	}
+	budgetUsage.Record(int64(resp.UsageMetadata.PromptTokenCount), int64(resp.UsageMetadata.CandidatesTokenCount))
+}
+
+// fallbackProvider is used instead of the paid provider once the budget is exceeded, nil if not configured
+var fallbackProvider Provider
+
+// activeProvider returns the provider to use for the next call of the media type, taking the budget into account
+func activeProvider(mediaType string) (Provider, error) {
+	provider := providerFor(mediaType)
+	if !paidProviders[provider.Name()] || !budgetUsage.Exceeded() {
+		return provider, nil
+	}
+	if fallbackProvider != nil && fallbackProvider.SupportsMedia(mediaType) {
+		return fallbackProvider, nil
+	}
+	return nil, ErrBudgetExceeded
+}
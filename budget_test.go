@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+)
+
+// meteredProvider is a fake paid provider that spends the given tokens on every call
+type meteredProvider struct {
+	*fakeProvider
+	tokens int64
+}
+
+func (p *meteredProvider) DescribeImage(prompt string, image []byte, format string) (string, error) {
+	budgetUsage.Record(p.tokens, 0)
+	return p.fakeProvider.DescribeImage(prompt, image, format)
+}
+
+// useBudget caps the spending at limit per day, with tokens costing one per token, starting unspent
+func useBudget(t *testing.T, limit float64) {
+	t.Helper()
+	withConfig(t, func(c *Config) {
+		c.Budget.Enabled = true
+		c.Budget.InputCostPerMillionTokens = 1e6
+		c.Budget.DailyLimit = limit
+		c.Cache.Enabled = false
+	})
+	budgetUsage = BudgetUsage{filePath: t.TempDir() + "/budget.json"}
+	savedFallback := fallbackProvider
+	t.Cleanup(func() {
+		budgetUsage = BudgetUsage{}
+		fallbackProvider = savedFallback
+	})
+	fallbackProvider = nil
+}
+
+func TestBudgetCapStopsGeneration(t *testing.T) {
+	useBudget(t, 3)
+	provider := &meteredProvider{fakeProvider: &fakeProvider{name: "gemini", response: "A white square"}, tokens: 2}
+	useProvider(t, provider)
+	image := dataURI("image/png", testImage(t, 4, 4, color.White))
+
+	for i := 0; i < 2; i++ {
+		if _, err := generateImageAltText(image, "", "en", "", false, nil); err != nil {
+			t.Fatalf("description %d failed under the budget: %v", i+1, err)
+		}
+	}
+	if !budgetUsage.Exceeded() {
+		t.Fatalf("spent %v of 3 without exceeding the budget", budgetUsage.DailyCost)
+	}
+
+	if _, err := generateImageAltText(image, "", "en", "", false, nil); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("generateImageAltText = %v over the budget, want ErrBudgetExceeded", err)
+	}
+	if provider.calls() != 2 {
+		t.Errorf("the paid provider was called %d times, want 2", provider.calls())
+	}
+}
+
+func TestBudgetCapSwitchesToTheFallback(t *testing.T) {
+	useBudget(t, 1)
+	paid := &fakeProvider{name: "gemini", response: "Paid"}
+	useProvider(t, paid)
+	fallback := &fakeProvider{name: "ollama", response: "Free", media: map[string]bool{"image": true}}
+	fallbackProvider = fallback
+	budgetUsage.Record(1, 0)
+
+	altText, err := generateImageAltText(dataURI("image/png", testImage(t, 4, 4, color.White)), "", "en", "", false, nil)
+	if err != nil || altText != "Free" || paid.calls() != 0 {
+		t.Errorf("generateImageAltText = %q, %v with %d paid calls, want the fallback's description", altText, err, paid.calls())
+	}
+
+	// Media the fallback can't handle isn't described at all
+	if _, err := activeProvider("video"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("activeProvider(video) = %v, want ErrBudgetExceeded", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/nfnt/resize"
+)
+
+// DescriptionCacheEntry is a previously generated description for an image
+type DescriptionCacheEntry struct {
+	Hash      uint64
+	Variant   string
+	AltText   string
+	Timestamp time.Time
+}
+
+// DescriptionCache stores generated descriptions keyed by the perceptual hash of the image,
+// so re-encoded or resized copies of the same image share a description
+type DescriptionCache struct {
+	entries []DescriptionCacheEntry
+	mu      sync.Mutex
+}
+
+var descriptionCache DescriptionCache
+
+// Get returns the cached description of the closest matching image within the configured Hamming distance.
+// The variant identifies how the description was generated, e.g. its language and style.
+func (dc *DescriptionCache) Get(hash uint64, variant string) (string, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	bestDistance := config.Cache.MaxHammingDistance + 1
+	var best string
+
+	for _, entry := range dc.entries {
+		if entry.Variant != variant || entry.expired() {
+			continue
+		}
+
+		distance := bits.OnesCount64(entry.Hash ^ hash)
+		if distance < bestDistance {
+			bestDistance = distance
+			best = entry.AltText
+		}
+	}
+
+	return best, best != ""
+}
+
+// Put adds a description to the cache, evicting expired and the oldest entries if necessary
+func (dc *DescriptionCache) Put(hash uint64, variant, altText string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	entries := dc.entries[:0]
+	for _, entry := range dc.entries {
+		if !entry.expired() {
+			entries = append(entries, entry)
+		}
+	}
+
+	if config.Cache.MaxEntries > 0 && len(entries) >= config.Cache.MaxEntries {
+		entries = entries[len(entries)-config.Cache.MaxEntries+1:]
+	}
+
+	dc.entries = append(entries, DescriptionCacheEntry{
+		Hash:      hash,
+		Variant:   variant,
+		AltText:   altText,
+		Timestamp: time.Now(),
+	})
+}
+
+// expired reports whether the entry is older than cache.ttl_hours. With a TTL of 0 entries never expire.
+func (entry DescriptionCacheEntry) expired() bool {
+	ttl := time.Duration(config.Cache.TTLHours) * time.Hour
+	return ttl > 0 && time.Since(entry.Timestamp) > ttl
+}
+
+// perceptualHash computes a 64-bit difference hash (dHash) of the image data
+func perceptualHash(imgData []byte) (uint64, error) {
+	img, _, err := decodeImage(imgData)
+	if err != nil {
+		return 0, err
+	}
+
+	return differenceHash(img), nil
+}
+
+// differenceHash shrinks the image to 9x8 grayscale pixels and sets a bit for every
+// pixel that is brighter than its right neighbour
+func differenceHash(img image.Image) uint64 {
+	small := resize.Resize(9, 8, img, resize.Bilinear)
+	bounds := small.Bounds()
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := color.GrayModel.Convert(small.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+			right := color.GrayModel.Convert(small.At(bounds.Min.X+x+1, bounds.Min.Y+y)).(color.Gray).Y
+			if left > right {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+
+	return hash
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDescriptionCacheTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttlHours int
+		age      time.Duration
+		hit      bool
+	}{
+		{"fresh", 24, time.Hour, true},
+		{"expired", 24, 25 * time.Hour, false},
+		{"no expiry", 0, 1000 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Cache.TTLHours = tt.ttlHours
+				c.Cache.MaxHammingDistance = 0
+			})
+			cache := &DescriptionCache{entries: []DescriptionCacheEntry{
+				{Hash: 42, Variant: "en", AltText: "A cat", Timestamp: time.Now().Add(-tt.age)},
+			}}
+
+			if _, hit := cache.Get(42, "en"); hit != tt.hit {
+				t.Errorf("hit = %v, want %v", hit, tt.hit)
+			}
+
+			// Adding another description keeps exactly the entries that haven't expired
+			cache.Put(7, "en", "A dog")
+			if _, kept := cache.Get(42, "en"); kept != tt.hit {
+				t.Errorf("entry kept after Put = %v, want %v", kept, tt.hit)
+			}
+		})
+	}
+}
+
+func TestDescriptionCacheMatchesVariantAndDistance(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Cache.TTLHours = 0
+		c.Cache.MaxHammingDistance = 2
+	})
+	cache := &DescriptionCache{}
+	cache.Put(0b1111, "en", "A cat")
+
+	if altText, hit := cache.Get(0b1100, "en"); !hit || altText != "A cat" {
+		t.Errorf("Get within distance 2 = %q, %v", altText, hit)
+	}
+	if _, hit := cache.Get(0b1000, "en"); hit {
+		t.Error("Get matched an image 3 bits away")
+	}
+	if _, hit := cache.Get(0b1111, "de"); hit {
+		t.Error("Get matched another variant")
+	}
+}
+
+// wavesImage is a smooth pattern of light and dark patches, like the shapes of a photo.
+// Inverted, the patches swap places.
+func wavesImage(width, height int, inverted bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := 128 + 100*math.Sin(float64(x)*7/float64(width))*math.Cos(float64(y)*5/float64(height))
+			if inverted {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{uint8(v), uint8(v * 0.8), uint8(255 - v/2), 255})
+		}
+	}
+	return img
+}
+
+func TestRecompressedImageHitsTheCache(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Cache.MaxHammingDistance = 4
+		c.Cache.TTLHours = 0
+	})
+
+	var original, recompressed, other bytes.Buffer
+	if err := png.Encode(&original, wavesImage(320, 240, false)); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(original.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&recompressed, decoded, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&other, wavesImage(320, 240, true)); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := func(data []byte) uint64 {
+		t.Helper()
+		h, err := perceptualHash(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	cache := &DescriptionCache{}
+	cache.Put(hash(original.Bytes()), "en", "Waves")
+
+	if altText, hit := cache.Get(hash(recompressed.Bytes()), "en"); !hit || altText != "Waves" {
+		t.Errorf("the JPEG copy got %q, %v, want the cached description", altText, hit)
+	}
+	if _, hit := cache.Get(hash(other.Bytes()), "en"); hit {
+		t.Error("a different image hit the cache")
+	}
+	if differenceHash(decoded) != hash(original.Bytes()) {
+		t.Error("the hash of the encoded image differs from the hash of the image itself")
+	}
+}
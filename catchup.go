@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// maxProcessedNotifications bounds how many handled notifications are remembered
+const maxProcessedNotifications = 1000
+
+// NotificationCursors remembers per account the newest notification that was handled,
+// so notifications that arrived while the bot was down can be caught up on at startup.
+// The latest handled notifications are remembered as well, so a notification arriving
+// through both the catch-up and the stream is only handled once.
+type NotificationCursors struct {
+	Accounts     map[string]mastodon.ID `json:"accounts"`
+	Processed    []string               `json:"processed"` // "account/id" of the handled notifications, oldest first
+	processedSet map[string]bool
+	inFlight     map[string]bool // Claimed notifications that are still being handled
+	filePath     string
+	mu           sync.Mutex
+}
+
+var notificationCursors = NotificationCursors{
+	Accounts:     make(map[string]mastodon.ID),
+	processedSet: make(map[string]bool),
+	inFlight:     make(map[string]bool),
+}
+
+// Get returns the newest handled notification of the account, or "" if none was recorded yet
+func (n *NotificationCursors) Get(account string) mastodon.ID {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.Accounts[account]
+}
+
+// Advance moves the account's cursor to the notification if it is newer
+func (n *NotificationCursors) Advance(account string, id mastodon.ID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if isNewerID(id, n.Accounts[account]) {
+		n.Accounts[account] = id
+		if err := n.saveToFile(); err != nil {
+			log.Printf("Error saving notification cursors: %v", err)
+		}
+	}
+}
+
+// Claim reports whether the notification still has to be handled and reserves it for the caller
+// until MarkHandled, so a copy arriving in the meantime isn't handled a second time
+func (n *NotificationCursors) Claim(account string, id mastodon.ID) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := account + "/" + string(id)
+	if n.processedSet[key] || n.inFlight[key] {
+		return false
+	}
+	n.inFlight[key] = true
+	return true
+}
+
+// MarkHandled records the notification as handled and moves the account's cursor past it.
+// It's called once handling is done, so a notification interrupted by a restart is caught up on.
+func (n *NotificationCursors) MarkHandled(account string, id mastodon.ID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := account + "/" + string(id)
+	delete(n.inFlight, key)
+	if n.processedSet[key] {
+		return
+	}
+
+	n.processedSet[key] = true
+	n.Processed = append(n.Processed, key)
+	if len(n.Processed) > maxProcessedNotifications {
+		for _, oldest := range n.Processed[:len(n.Processed)-maxProcessedNotifications] {
+			delete(n.processedSet, oldest)
+		}
+		n.Processed = n.Processed[len(n.Processed)-maxProcessedNotifications:]
+	}
+
+	if isNewerID(id, n.Accounts[account]) {
+		n.Accounts[account] = id
+	}
+	if err := n.saveToFile(); err != nil {
+		log.Printf("Error saving notification cursors: %v", err)
+	}
+}
+
+// LoadFromFile loads the stored cursors
+func (n *NotificationCursors) LoadFromFile(filePath string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.filePath = filePath
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist. Start fresh.
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, n); err != nil {
+		return err
+	}
+
+	for _, key := range n.Processed {
+		n.processedSet[key] = true
+	}
+	return nil
+}
+
+func (n *NotificationCursors) saveToFile() error {
+	if n.filePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(n.filePath, data, 0644)
+}
+
+// isNewerID compares Mastodon IDs, which are numbers that grow over time, without parsing them
+func isNewerID(id, than mastodon.ID) bool {
+	if len(id) != len(than) {
+		return len(id) > len(than)
+	}
+	return id > than
+}
+
+// catchUpNotifications handles the mentions and follows that arrived since the account's cursor.
+// Without a cursor there is nothing to catch up on, so the cursor starts at the latest notification.
+func catchUpNotifications(c MastodonClient) {
+	account := accountUsername(c)
+	cursor := notificationCursors.Get(account)
+	if cursor == "" {
+		latest, err := c.GetNotifications(ctx, &mastodon.Pagination{Limit: 1})
+		if err != nil {
+			log.Printf("Error fetching latest notification for %s: %v", account, err)
+		} else if len(latest) > 0 {
+			notificationCursors.Advance(account, latest[0].ID)
+		}
+		return
+	}
+
+	maxAge := time.Duration(config.Behavior.CatchUpMaxAgeMinutes) * time.Minute
+	handled := 0
+	for {
+		// min_id returns the page right after the cursor, so paging forward never skips any
+		notifications, err := c.GetNotifications(ctx, &mastodon.Pagination{MinID: cursor, Limit: 40})
+		if err != nil {
+			log.Printf("Error fetching missed notifications for %s: %v", account, err)
+			break
+		}
+		if len(notifications) == 0 {
+			break
+		}
+
+		sort.Slice(notifications, func(i, j int) bool {
+			return isNewerID(notifications[j].ID, notifications[i].ID)
+		})
+		for _, notification := range notifications {
+			cursor = notification.ID
+			// Answering a mention days later helps nobody, missed follows are still followed back
+			if notification.Type == "mention" && maxAge > 0 && time.Since(notification.CreatedAt) > maxAge {
+				notificationCursors.MarkHandled(account, notification.ID)
+				continue
+			}
+			handleNotification(c, notification)
+			handled++
+		}
+	}
+
+	if handled > 0 {
+		log.Printf("Caught up on %d missed notifications for %s", handled, account)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useNotificationCursors starts the test without any handled notifications
+func useNotificationCursors(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		notificationCursors.mu.Lock()
+		defer notificationCursors.mu.Unlock()
+		notificationCursors.Accounts = make(map[string]mastodon.ID)
+		notificationCursors.Processed = nil
+		notificationCursors.processedSet = make(map[string]bool)
+		notificationCursors.inFlight = make(map[string]bool)
+		notificationCursors.filePath = ""
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestNotificationFromStreamAndCatchUpIsHandledOnce(t *testing.T) {
+	useNotificationCursors(t)
+	withConfig(t, func(c *Config) {
+		c.Behavior.FollowBack = true
+		c.Behavior.MaxFollowBacksPerDay = 0
+		c.Behavior.WelcomeMessage = false
+	})
+
+	c := newFakeClient()
+	follow := &mastodon.Notification{
+		ID:        "101",
+		Type:      "follow",
+		CreatedAt: time.Now(),
+		Account:   mastodon.Account{ID: "fan", Acct: "fan"},
+	}
+	c.notifications = []*mastodon.Notification{follow}
+	notificationCursors.Advance(accountUsername(c), "100")
+
+	// The follow arrives through the stream while the catch-up still sees it as missed
+	handleNotification(c, follow)
+	catchUpNotifications(c)
+
+	if len(c.followed) != 1 {
+		t.Errorf("followed %v, want the follower followed back once", c.followed)
+	}
+	if cursor := notificationCursors.Get(accountUsername(c)); cursor != "101" {
+		t.Errorf("cursor = %s, want 101", cursor)
+	}
+}
+
+func TestNotificationIsClaimedUntilHandled(t *testing.T) {
+	useNotificationCursors(t)
+
+	if !notificationCursors.Claim("altbot", "5") {
+		t.Fatal("a new notification couldn't be claimed")
+	}
+	if notificationCursors.Claim("altbot", "5") {
+		t.Error("a notification still being handled was claimed again")
+	}
+	if cursor := notificationCursors.Get("altbot"); cursor != "" {
+		t.Errorf("cursor moved to %s before the notification was handled", cursor)
+	}
+
+	notificationCursors.MarkHandled("altbot", "5")
+	if notificationCursors.Claim("altbot", "5") {
+		t.Error("a handled notification was claimed again")
+	}
+	if !notificationCursors.Claim("altbot2", "5") {
+		t.Error("the same notification ID of another account couldn't be claimed")
+	}
+}
+
+func TestUnfinishedNotificationIsHandledAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification_cursors.json")
+	before := &NotificationCursors{Accounts: make(map[string]mastodon.ID), processedSet: make(map[string]bool), inFlight: make(map[string]bool)}
+	if err := before.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	before.Claim("altbot", "4")
+	before.MarkHandled("altbot", "4")
+	before.Claim("altbot", "5") // The bot stops before handling is done
+
+	after := &NotificationCursors{Accounts: make(map[string]mastodon.ID), processedSet: make(map[string]bool), inFlight: make(map[string]bool)}
+	if err := after.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if cursor := after.Get("altbot"); cursor != "4" {
+		t.Errorf("cursor = %s, want 4", cursor)
+	}
+	if after.Claim("altbot", "4") {
+		t.Error("a handled notification was claimed again after the restart")
+	}
+	if !after.Claim("altbot", "5") {
+		t.Error("an unfinished notification couldn't be claimed after the restart")
+	}
+}
+
+// followNotification returns a follow notification by a new account for the ID
+func followNotification(id string) *mastodon.Notification {
+	return &mastodon.Notification{
+		ID:        mastodon.ID(id),
+		Type:      "follow",
+		CreatedAt: time.Now(),
+		Account:   mastodon.Account{ID: mastodon.ID("follower-" + id), Acct: "follower-" + id},
+	}
+}
+
+func TestCatchUpResumesFromThePersistedCursor(t *testing.T) {
+	useNotificationCursors(t)
+	withConfig(t, func(c *Config) {
+		c.Behavior.FollowBack = true
+		c.Behavior.MaxFollowBacksPerDay = 0
+		c.Behavior.WelcomeMessage = false
+	})
+	path := filepath.Join(t.TempDir(), "notification_cursors.json")
+	c := newFakeClient()
+	account := accountUsername(c)
+
+	// The first start has no cursor, it starts at the latest notification without handling it
+	c.notifications = []*mastodon.Notification{followNotification("90"), followNotification("95")}
+	if err := notificationCursors.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	catchUpNotifications(c)
+	if len(c.followed) != 0 {
+		t.Errorf("followed %v on the first start", c.followed)
+	}
+
+	// While the bot is down, more follows arrive than fit on one page
+	for i := 100; i < 150; i++ {
+		c.notifications = append(c.notifications, followNotification(fmt.Sprint(i)))
+	}
+	useNotificationCursors(t)
+	if err := notificationCursors.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if cursor := notificationCursors.Get(account); cursor != "95" {
+		t.Fatalf("persisted cursor = %s, want 95", cursor)
+	}
+	catchUpNotifications(c)
+
+	if len(c.followed) != 50 || c.followed[0] != "follower-100" || c.followed[49] != "follower-149" {
+		t.Errorf("followed %d accounts from %v, want the 50 missed ones in order", len(c.followed), c.followed)
+	}
+
+	// The next start resumes after the last handled notification
+	useNotificationCursors(t)
+	if err := notificationCursors.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if cursor := notificationCursors.Get(account); cursor != "149" {
+		t.Errorf("persisted cursor = %s, want 149", cursor)
+	}
+	catchUpNotifications(c)
+	if len(c.followed) != 50 {
+		t.Errorf("followed %d accounts after another restart, want no more", len(c.followed)-50)
+	}
+}
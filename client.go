@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// MastodonClient is the subset of the Mastodon API the bot uses, so handlers
+// can be driven by something other than a live *mastodon.Client
+type MastodonClient interface {
+	GetStatus(ctx context.Context, id mastodon.ID) (*mastodon.Status, error)
+	GetStatusContext(ctx context.Context, id mastodon.ID) (*mastodon.Context, error)
+	PostStatus(ctx context.Context, toot *mastodon.Toot) (*mastodon.Status, error)
+	DeleteStatus(ctx context.Context, id mastodon.ID) error
+	UploadMediaFromMedia(ctx context.Context, media *mastodon.Media) (*mastodon.Attachment, error)
+	AccountFollow(ctx context.Context, id mastodon.ID) (*mastodon.Relationship, error)
+	AccountUnfollow(ctx context.Context, id mastodon.ID) (*mastodon.Relationship, error)
+	GetAccountRelationships(ctx context.Context, ids []string) ([]*mastodon.Relationship, error)
+	GetAccount(ctx context.Context, id mastodon.ID) (*mastodon.Account, error)
+	GetAccountCurrentUser(ctx context.Context) (*mastodon.Account, error)
+	GetNotifications(ctx context.Context, pg *mastodon.Pagination) ([]*mastodon.Notification, error)
+	GetTimelineHome(ctx context.Context, pg *mastodon.Pagination) ([]*mastodon.Status, error)
+	GetTimelinePublic(ctx context.Context, isLocal bool, pg *mastodon.Pagination) ([]*mastodon.Status, error)
+	GetTimelineHashtag(ctx context.Context, tag string, isLocal bool, pg *mastodon.Pagination) ([]*mastodon.Status, error)
+}
+
+var _ MastodonClient = (*mastodon.Client)(nil)
+
+// fetchStatus wraps GetStatus so callers never receive a nil status without an error
+func fetchStatus(ctx context.Context, c MastodonClient, id mastodon.ID) (*mastodon.Status, error) {
+	status, err := c.GetStatus(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, fmt.Errorf("status %s not found", id)
+	}
+	return status, nil
+}
+
+// isStatusGone reports whether err means the status was deleted or is not visible to the bot
+func isStatusGone(err error) bool {
+	var apiErr *mastodon.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case 403, 404, 410:
+			return true
+		}
+	}
+	return false
+}
+
+// isForbidden reports whether err means the bot isn't allowed to do this, e.g. because it was blocked.
+// Retrying won't change that.
+func isForbidden(err error) bool {
+	var apiErr *mastodon.APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == 401 || apiErr.StatusCode == 403)
+}
+
+// isTransientError reports whether err is likely to go away on its own and the request is safe to repeat,
+// like rate limiting, server errors or a connection that couldn't be made. Timeouts and connections lost
+// after sending aren't, the server may have accepted the post anyway.
+func isTransientError(err error) bool {
+	var apiErr *mastodon.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == 429 || apiErr.StatusCode >= 500
+	}
+
+	// The request never left when the server couldn't be looked up or connected to
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// postRetryDelays are the waits before each retry of a post that failed with a transient error
+var postRetryDelays = []time.Duration{2 * time.Second, 10 * time.Second}
+
+// postStatus posts the toot, retrying transient errors. Other errors, like being blocked, are returned right away.
+// Nothing is posted while the bot is paused.
+func postStatus(ctx context.Context, c MastodonClient, toot *mastodon.Toot) (*mastodon.Status, error) {
+	if isPaused() {
+		return nil, ErrPaused
+	}
+
+	status, err := c.PostStatus(ctx, toot)
+	for _, delay := range postRetryDelays {
+		if err == nil || !isTransientError(err) {
+			break
+		}
+		log.Printf("Error posting status, retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		status, err = c.PostStatus(ctx, toot)
+	}
+	return status, err
+}
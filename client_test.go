@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestFetchStatusReportsMissingStatusAsGone(t *testing.T) {
+	c := newFakeClient(&mastodon.Status{ID: "1"})
+
+	status, err := fetchStatus(ctx, c, "1")
+	if err != nil || status.ID != "1" {
+		t.Fatalf("fetchStatus(1) = %v, %v", status, err)
+	}
+
+	if _, err := fetchStatus(ctx, c, "2"); !isStatusGone(err) {
+		t.Errorf("fetchStatus(2) error = %v, want a gone status", err)
+	}
+}
+
+func TestHandleDeleteEventDeletesBotReply(t *testing.T) {
+	c := newFakeClient()
+	mapMutex.Lock()
+	replyMap["original"] = ReplyInfo{OriginalID: "original", ReplyID: "reply"}
+	mapMutex.Unlock()
+
+	handleDeleteEvent(c, "original")
+
+	if len(c.deleted) != 1 || c.deleted[0] != "reply" {
+		t.Errorf("deleted = %v, want [reply]", c.deleted)
+	}
+	mapMutex.Lock()
+	_, exists := replyMap["original"]
+	mapMutex.Unlock()
+	if exists {
+		t.Error("reply map still contains the deleted post")
+	}
+}
+
+func TestHandleFollowFollowsBack(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.FollowBack = true
+		c.Behavior.FollowBackSkipBots = true
+	})
+
+	tests := []struct {
+		name    string
+		account mastodon.Account
+		follow  bool
+	}{
+		{"person", mastodon.Account{ID: "person", Acct: "person@example.social"}, true},
+		{"bot", mastodon.Account{ID: "bot-account", Acct: "bot@example.social", Bot: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient()
+			handleFollow(c, &mastodon.Notification{ID: "1", Type: "follow", Account: tt.account})
+
+			if followed := len(c.followed) == 1 && c.followed[0] == tt.account.ID; followed != tt.follow {
+				t.Errorf("followed = %v, want follow %v", c.followed, tt.follow)
+			}
+		})
+	}
+}
+
+func TestPointToDescriptionRepliesWithLink(t *testing.T) {
+	c := newFakeClient()
+	notification := &mastodon.Notification{
+		Account: mastodon.Account{ID: "requester", Acct: "requester"},
+		Status:  &mastodon.Status{ID: "mention", Visibility: "public", Language: "en"},
+	}
+
+	if !pointToDescription(c, "https://example.social/@altbot/1", notification) {
+		t.Fatal("pointToDescription reported failure")
+	}
+
+	posted := c.postedToots()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d toots, want 1", len(posted))
+	}
+	if posted[0].InReplyToID != "mention" || !strings.Contains(posted[0].Status, "https://example.social/@altbot/1") || !strings.HasPrefix(posted[0].Status, "@requester ") {
+		t.Errorf("posted %+v", posted[0])
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"rate limited", &mastodon.APIError{StatusCode: 429}, true},
+		{"server error", &mastodon.APIError{StatusCode: 503}, true},
+		{"forbidden", &mastodon.APIError{StatusCode: 403}, false},
+		{"invalid", &mastodon.APIError{StatusCode: 422}, false},
+		{"connection refused", &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, true},
+		{"unknown host", &url.Error{Op: "Post", Err: &net.DNSError{Err: "no such host", Name: "example.social"}}, true},
+		{"connection reset", &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}, false},
+		{"timeout", &url.Error{Op: "Post", Err: context.DeadlineExceeded}, false},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.transient {
+			t.Errorf("isTransientError(%s) = %v, want %v", tt.name, got, tt.transient)
+		}
+	}
+}
+
+func TestPostStatusRetriesOnlyWhatIsSafeToRepeat(t *testing.T) {
+	saved := postRetryDelays
+	postRetryDelays = []time.Duration{0, 0}
+	t.Cleanup(func() { postRetryDelays = saved })
+
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"server error", &mastodon.APIError{StatusCode: 502}, 3},
+		{"timeout", &url.Error{Op: "Post", Err: context.DeadlineExceeded}, 1},
+		{"forbidden", &mastodon.APIError{StatusCode: 403}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient()
+			c.postErr = tt.err
+			if _, err := postStatus(ctx, c, &mastodon.Toot{Status: "hello"}); !errors.Is(err, tt.err) {
+				t.Errorf("postStatus = %v, want %v", err, tt.err)
+			}
+			if c.postAttempts != tt.attempts {
+				t.Errorf("posted %d times, want %d", c.postAttempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestForbiddenReplyRemembersTheBlock(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.RateLimit.Enabled = false
+		c.Behavior.SkipBlockingAccounts = true
+	})
+	useProvider(t, &fakeProvider{response: "A white square"})
+
+	status := &mastodon.Status{
+		ID:               "blocked-post",
+		Account:          mastodon.Account{ID: "blocker", Acct: "blocker"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "1", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "blocked-mention", Account: status.Account, Language: "en"})
+	c.postErr = &mastodon.APIError{StatusCode: 403}
+	forgetReplies(t, c, status.ID)
+	t.Cleanup(func() {
+		blockedBy.mu.Lock()
+		delete(blockedBy.Accounts, blockedByKey(c, "blocker"))
+		blockedBy.mu.Unlock()
+	})
+
+	generateAndPostAltText(c, status, "blocked-mention", nil)
+
+	if c.postAttempts != 1 {
+		t.Errorf("posted %d times, a refused reply isn't retried", c.postAttempts)
+	}
+	if !isForbidden(c.postErr) || !blockedBy.Contains(c, "blocker") {
+		t.Error("the account that refused the reply isn't remembered")
+	}
+	if blockedBy.Contains(c, "someone-else") {
+		t.Error("an account that never refused a reply is skipped")
+	}
+}
+
+// nilStatusClient answers lookups of the missing status with neither a status nor an error
+type nilStatusClient struct {
+	*fakeClient
+	missing mastodon.ID
+}
+
+func (n *nilStatusClient) GetStatus(ctx context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	if id == n.missing {
+		return nil, nil
+	}
+	return n.fakeClient.GetStatus(ctx, id)
+}
+
+func TestFetchStatusNeverReturnsNil(t *testing.T) {
+	c := &nilStatusClient{fakeClient: newFakeClient(), missing: "missing"}
+
+	status, err := fetchStatus(ctx, c, "missing")
+	if status != nil || err == nil {
+		t.Errorf("fetchStatus = %v, %v, want an error instead of a nil status", status, err)
+	}
+}
+
+func TestIsStatusGone(t *testing.T) {
+	tests := []struct {
+		err  error
+		gone bool
+	}{
+		{&mastodon.APIError{StatusCode: 403}, true},
+		{&mastodon.APIError{StatusCode: 404}, true},
+		{&mastodon.APIError{StatusCode: 410}, true},
+		{&mastodon.APIError{StatusCode: 500}, false},
+		{&mastodon.APIError{StatusCode: 429}, false},
+		{errors.New("connection reset"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isStatusGone(tt.err); got != tt.gone {
+			t.Errorf("isStatusGone(%v) = %v, want %v", tt.err, got, tt.gone)
+		}
+	}
+}
+
+func TestUnavailableStatusesStopTheHandlers(t *testing.T) {
+	errs := map[string]error{
+		"deleted":   &mastodon.APIError{StatusCode: 404},
+		"gone":      &mastodon.APIError{StatusCode: 410},
+		"forbidden": &mastodon.APIError{StatusCode: 403},
+		"outage":    &mastodon.APIError{StatusCode: 503},
+		"nil":       nil,
+	}
+	requester := mastodon.Account{ID: "requester", Acct: "requester"}
+	post := &mastodon.Status{
+		ID:               "present",
+		Account:          requester,
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: "https://files.example/cat.png"}},
+	}
+	mention := &mastodon.Status{ID: "asking", Account: requester, InReplyToID: "missing", Content: "<p>@altbot please describe</p>", Visibility: "public", Language: "en"}
+	handlers := map[string]func(c MastodonClient){
+		"notification": func(c MastodonClient) {
+			handleNotification(c, &mastodon.Notification{ID: "unavailable", Type: "mention", Account: requester, Status: mention})
+		},
+		"mention": func(c MastodonClient) {
+			handleMention(c, &mastodon.Notification{Type: "mention", Account: requester, Status: mention})
+		},
+		"consent": func(c MastodonClient) {
+			useConsentRequest(t, c, "missing", ConsentRequest{RequestID: "asking", PosterID: "requester"})
+			handleConsentResponse(c, "missing", &mastodon.Status{ID: "yes", Account: requester, Content: "<p>@altbot yes</p>"})
+		},
+		"description": func(c MastodonClient) {
+			generateAndPostAltText(c, post, "missing", nil)
+		},
+		"redo": func(c MastodonClient) {
+			mapMutex.Lock()
+			replyMap[accountKey(c, "missing")] = ReplyInfo{OriginalID: "missing", ReplyID: "old-reply", RequesterID: "requester", Timestamp: time.Now()}
+			mapMutex.Unlock()
+			t.Cleanup(func() {
+				mapMutex.Lock()
+				delete(replyMap, accountKey(c, "missing"))
+				mapMutex.Unlock()
+			})
+			handleRedo(c, "missing", &mastodon.Notification{Type: "mention", Account: requester, Status: mention}, "")
+		},
+	}
+	for errName, err := range errs {
+		for handlerName, handle := range handlers {
+			t.Run(handlerName+"/"+errName, func(t *testing.T) {
+				withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+				useNotificationCursors(t)
+				provider := &fakeProvider{response: "A cat"}
+				useProvider(t, provider)
+				fake := newFakeClient(post, mention)
+				var c MastodonClient = fake
+				if err == nil {
+					c = &nilStatusClient{fakeClient: fake, missing: "missing"}
+				} else {
+					fake.statusErrs["missing"] = err
+				}
+
+				handle(c)
+
+				if posted := fake.postedToots(); len(posted) != 0 {
+					t.Errorf("posted %+v without the status", posted)
+				}
+				if provider.calls() != 0 {
+					t.Error("described media without the status")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// mentionText returns the plain text of a post's HTML content for command parsing. Mention links,
+// including the bot's own, and any remaining plain @-mentions are removed, hashtags are kept.
+func mentionText(content string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		log.Printf("Error parsing HTML: %v", err)
+		return content
+	}
+
+	var words []string
+	for _, word := range strings.Fields(extractText(doc, true)) {
+		if !strings.HasPrefix(word, "@") {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// parseAttachmentSelection parses an attachment selector like "describe 2" or "describe 1,3"
+// from the content of a mention. It returns the selected 1-based attachment indices, or nil
+// if no selector was given and all attachments should be described. A selector with anything
+// but numbers in it, like "describe 1,x", is reported as invalid instead of partly applied.
+func parseAttachmentSelection(content string) ([]int, bool) {
+	words := strings.Fields(strings.ToLower(mentionText(content)))
+
+	for i, word := range words {
+		if word != "describe" {
+			continue
+		}
+
+		var selection []int
+		for _, arg := range words[i+1:] {
+			// Punctuation ending the sentence isn't part of the selector, as in "describe 2."
+			arg = strings.TrimRight(arg, ".!?;:")
+			if !isSelectorArgument(arg) {
+				break
+			}
+
+			for _, number := range strings.FieldsFunc(arg, func(r rune) bool { return r == ',' }) {
+				index, err := strconv.Atoi(strings.TrimPrefix(number, "#"))
+				if err != nil {
+					return nil, false
+				}
+				selection = append(selection, index)
+			}
+		}
+
+		return selection, true
+	}
+
+	return nil, true
+}
+
+// isSelectorArgument reports whether the word after "describe" is meant as attachment numbers,
+// rather than the start of a sentence like "describe this please"
+func isSelectorArgument(arg string) bool {
+	if arg == "" {
+		return false
+	}
+	return strings.ContainsRune(arg, ',') || arg[0] == '#' || (arg[0] >= '0' && arg[0] <= '9')
+}
+
+// validateAttachmentSelection checks that every selected index refers to an existing attachment
+func validateAttachmentSelection(selection []int, attachmentCount int) bool {
+	for _, index := range selection {
+		if index < 1 || index > attachmentCount {
+			return false
+		}
+	}
+	return true
+}
+
+// isAttachmentSelected reports whether the attachment at the 0-based position i is part of the selection
+func isAttachmentSelected(selection []int, i int) bool {
+	if len(selection) == 0 {
+		return true
+	}
+
+	for _, index := range selection {
+		if index == i+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCommandWord reports whether the mention content contains the command word
+func hasCommandWord(content, command string) bool {
+	for _, word := range strings.Fields(strings.ToLower(mentionText(content))) {
+		if word == command {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestParseAttachmentSelection(t *testing.T) {
+	tests := []struct {
+		content   string
+		selection []int
+		ok        bool
+	}{
+		{"<p>@altbot</p>", nil, true},
+		{"<p>@altbot describe</p>", nil, true},
+		{"<p>@altbot describe this please</p>", nil, true},
+		{"<p>@altbot describe 2</p>", []int{2}, true},
+		{"<p>@altbot describe 2.</p>", []int{2}, true},
+		{"<p>@altbot Describe #3!</p>", []int{3}, true},
+		{"<p>@altbot describe 1,3</p>", []int{1, 3}, true},
+		{"<p>@altbot describe 1, 3 please</p>", []int{1, 3}, true},
+		{"<p>@altbot describe 1,x</p>", nil, false},
+		{"<p>@altbot describe 2 and 1,x</p>", []int{2}, true},
+		{"<p>@altbot describe 1 2x</p>", nil, false},
+	}
+	for _, tt := range tests {
+		selection, ok := parseAttachmentSelection(tt.content)
+		if ok != tt.ok || !reflect.DeepEqual(selection, tt.selection) {
+			t.Errorf("parseAttachmentSelection(%q) = %v, %v, want %v, %v", tt.content, selection, ok, tt.selection, tt.ok)
+		}
+	}
+}
+
+func TestInvalidSelectionIsAnswered(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.AskForConsent = false
+		c.RateLimit.Enabled = false
+	})
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+
+	image := dataURI("image/png", testImage(t, 4, 4, color.White))
+	mention := &mastodon.Status{
+		ID:          "selecting-mention",
+		InReplyToID: "two-images",
+		Account:     mastodon.Account{ID: "poster", Acct: "poster"},
+		Content:     "<p>@altbot describe 1,x</p>",
+		Visibility:  "public",
+		Language:    "en",
+	}
+	c := newFakeClient(mention, &mastodon.Status{
+		ID:               "two-images",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: image}, {ID: "b", Type: "image", URL: image}},
+	})
+	forgetReplies(t, c, "two-images")
+
+	handleMention(c, &mastodon.Notification{Account: mention.Account, Status: mention})
+
+	if provider.calls() != 0 {
+		t.Errorf("described %d images for an invalid selection", provider.calls())
+	}
+	posted := c.postedToots()
+	if len(posted) != 1 || !strings.Contains(posted[0].Status, "only has 2 attachment(s)") {
+		t.Errorf("posted %+v, want the invalid selection reply", posted)
+	}
+}
+
+func TestMentionText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"bot mention",
+			`<p><span class="h-card" translate="no"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> describe 2</p>`,
+			"describe 2",
+		},
+		{
+			"several mentions and a hashtag",
+			`<p><span class="h-card" translate="no"><a href="https://other.example/@friend" class="u-url mention">@<span>friend</span></a></span> <span class="h-card" translate="no"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> yes please <a href="https://example.social/tags/NoAI" class="mention hashtag" rel="tag">#<span>NoAI</span></a></p>`,
+			"yes please #NoAI",
+		},
+		{
+			"paragraphs and line breaks",
+			`<p><span class="h-card"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> redo</p><p>focus on<br />the sign</p>`,
+			"redo focus on the sign",
+		},
+		{
+			"plain mention and entities",
+			`<p>@altbot@example.social it&#39;s &quot;blurry&quot;</p>`,
+			`it's "blurry"`,
+		},
+	}
+	for _, tt := range tests {
+		if got := mentionText(tt.content); got != tt.want {
+			t.Errorf("%s: mentionText = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestApplyConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		replyOnError bool
+	}{
+		{"older config", "[behavior]\nask_for_consent = true\n", true},
+		{"enabled", "[behavior]\nreply_on_error = true\n", true},
+		{"disabled", "[behavior]\nreply_on_error = false\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { *c = Config{} })
+
+			md, err := toml.Decode(tt.file, &config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			applyConfigDefaults(md)
+
+			if config.Behavior.ReplyOnError != tt.replyOnError {
+				t.Errorf("reply_on_error = %v, want %v", config.Behavior.ReplyOnError, tt.replyOnError)
+			}
+		})
+	}
+}
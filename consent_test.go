@@ -0,0 +1,237 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestConsentResponseFromOthersChecksNoLinks(t *testing.T) {
+	server, requests := linkServer(t)
+	withConfig(t, func(c *Config) { c.ImageProcessing.DescribeLinkedImages = true })
+
+	c := newFakeClient(&mastodon.Status{
+		ID:      "post",
+		Account: mastodon.Account{ID: "poster", Acct: "poster"},
+		Content: linksContent(server.URL, "/img1", "/img2"),
+	})
+	consentMutex.Lock()
+	consentRequests["post"] = ConsentRequest{RequestID: "mention", PosterID: "poster"}
+	consentMutex.Unlock()
+	t.Cleanup(func() {
+		consentMutex.Lock()
+		delete(consentRequests, "post")
+		consentMutex.Unlock()
+	})
+
+	handleConsentResponse(c, "post", &mastodon.Status{
+		ID:      "answer",
+		Account: mastodon.Account{ID: "someone", Acct: "someone"},
+		Content: "<p>@altbot yes</p>",
+	})
+
+	if n := requests.Load(); n != 0 {
+		t.Errorf("checked %d links for an unauthorized consent response", n)
+	}
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Errorf("posted %+v for an unauthorized consent response", posted)
+	}
+	consentMutex.Lock()
+	_, pending := consentRequests["post"]
+	consentMutex.Unlock()
+	if !pending {
+		t.Error("an unauthorized response removed the consent request")
+	}
+}
+
+func TestConsentResponseIsReadFromHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		described bool
+	}{
+		{"yes", `<p><span class="h-card" translate="no"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> Yes!</p>`, true},
+		{"yes on its own line", `<p><span class="h-card"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> sure,<br />y</p>`, true},
+		{"no", `<p><span class="h-card"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> no thanks</p>`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+			provider := &fakeProvider{response: "A white square"}
+			useProvider(t, provider)
+
+			post := &mastodon.Status{
+				ID:               "asked-post",
+				Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+			}
+			answer := &mastodon.Status{ID: "answer", Account: post.Account, Content: tt.content, Visibility: "public", Language: "en"}
+			c := newFakeClient(post, answer)
+			forgetReplies(t, c, post.ID)
+			consentMutex.Lock()
+			consentRequests[accountKey(c, post.ID)] = ConsentRequest{RequestID: "asking-mention", PosterID: "poster"}
+			consentMutex.Unlock()
+			t.Cleanup(func() {
+				consentMutex.Lock()
+				delete(consentRequests, accountKey(c, post.ID))
+				consentMutex.Unlock()
+			})
+
+			handleConsentResponse(c, post.ID, answer)
+
+			if described := provider.calls() > 0; described != tt.described {
+				t.Errorf("described = %v, want %v", described, tt.described)
+			}
+		})
+	}
+}
+
+// useConsentRequest registers a pending consent request for the post until the test ends
+func useConsentRequest(t *testing.T, c MastodonClient, postID mastodon.ID, request ConsentRequest) {
+	t.Helper()
+	consentMutex.Lock()
+	consentRequests[accountKey(c, postID)] = request
+	consentMutex.Unlock()
+	t.Cleanup(func() {
+		consentMutex.Lock()
+		delete(consentRequests, accountKey(c, postID))
+		consentMutex.Unlock()
+	})
+}
+
+func TestConsentDenialIsAcknowledged(t *testing.T) {
+	tests := []struct {
+		name        string
+		acknowledge bool
+		requester   *mastodon.Account // nil when the request was deleted
+		want        string
+	}{
+		{"requester", true, &mastodon.Account{ID: "requester", Acct: "requester"}, "@poster @requester "},
+		{"poster asked", true, &mastodon.Account{ID: "poster", Acct: "poster"}, "@poster "},
+		{"requester opted out", true, &mastodon.Account{ID: "private", Acct: "private", Note: "#nobot"}, "@poster "},
+		{"request deleted", true, nil, "@poster "},
+		{"disabled", false, &mastodon.Account{ID: "requester", Acct: "requester"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Behavior.AcknowledgeConsentDenial = tt.acknowledge
+				c.DNI.Tags = []string{"#nobot"}
+			})
+			provider := &fakeProvider{response: "A white square"}
+			useProvider(t, provider)
+
+			post := &mastodon.Status{ID: "denied-post", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Visibility: "public"}
+			answer := &mastodon.Status{ID: "denial", Account: post.Account, Content: "<p>@altbot nein</p>", Visibility: "unlisted", Language: "de"}
+			c := newFakeClient(post, answer)
+			if tt.requester != nil {
+				c.statuses["denied-request"] = &mastodon.Status{ID: "denied-request", Account: *tt.requester}
+			}
+			useConsentRequest(t, c, post.ID, ConsentRequest{RequestID: "denied-request", PosterID: "poster"})
+
+			handleConsentResponse(c, post.ID, answer)
+
+			posted := c.postedToots()
+			if provider.calls() != 0 {
+				t.Error("the post was described after consent was denied")
+			}
+			if tt.want == "" {
+				if len(posted) != 0 {
+					t.Errorf("posted %+v, want no acknowledgement", posted)
+				}
+				return
+			}
+			want := tt.want + getLocalizedString("de", "consentDenied", "response")
+			if len(posted) != 1 || posted[0].Status != want {
+				t.Fatalf("posted %+v, want %q", posted, want)
+			}
+			if posted[0].InReplyToID != "denial" || posted[0].Visibility != "unlisted" || posted[0].Language != "de" {
+				t.Errorf("acknowledgement = %+v, want an unlisted German reply to the denial", posted[0])
+			}
+		})
+	}
+}
+
+// useMetrics records the metric events of the test in a fresh, enabled metrics manager
+func useMetrics(t *testing.T) *MetricsManager {
+	t.Helper()
+	saved := metricsManager
+	metricsManager = &MetricsManager{enabled: true}
+	t.Cleanup(func() { metricsManager = saved })
+	return metricsManager
+}
+
+// eventCounts counts the logged events of each type for the user
+func eventCounts(mm *MetricsManager, userID string) map[string]int {
+	mm.fileMutex.Lock()
+	defer mm.fileMutex.Unlock()
+	counts := make(map[string]int)
+	for _, event := range mm.logs {
+		if event.UserID == hashUserID(userID) {
+			counts[event.EventType]++
+		}
+	}
+	return counts
+}
+
+func TestConsentOutcomesAreCounted(t *testing.T) {
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+	mm := useMetrics(t)
+	useProvider(t, &fakeProvider{response: "A white square"})
+	poster := mastodon.Account{ID: "counted-poster", Acct: "counted-poster"}
+	asker := &mastodon.Notification{Type: "mention", Account: mastodon.Account{ID: "asker", Acct: "asker"},
+		Status: &mastodon.Status{ID: "counted-mention", Account: mastodon.Account{ID: "asker", Acct: "asker"}, Language: "en"}}
+	image := dataURI("image/png", testImage(t, 4, 4, color.White))
+	c := newFakeClient(asker.Status)
+	posts := make(map[string]*mastodon.Status)
+	for _, id := range []string{"granted", "denied", "expired"} {
+		post := &mastodon.Status{ID: mastodon.ID("counted-" + id), Account: poster, Visibility: "public",
+			MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: image}}}
+		answer := &mastodon.Status{ID: mastodon.ID("answer-" + id), Account: poster, Visibility: "public", Language: "en"}
+		c.statuses[post.ID], c.statuses[answer.ID] = post, answer
+		posts[id] = post
+		forgetReplies(t, c, post.ID)
+		t.Cleanup(func() {
+			consentMutex.Lock()
+			delete(consentRequests, accountKey(c, post.ID))
+			consentMutex.Unlock()
+		})
+
+		requestConsent(c, post, asker, nil)
+	}
+
+	// Asking again while waiting for an answer isn't another request
+	requestConsent(c, posts["granted"], asker, nil)
+
+	answer := func(id, content string) {
+		status := c.statuses[mastodon.ID("answer-"+id)]
+		status.Content = content
+		handleConsentResponse(c, posts[id].ID, status)
+	}
+	answer("granted", "<p>@altbot yes</p>")
+	answer("denied", "<p>@altbot no</p>")
+
+	consentMutex.Lock()
+	request := consentRequests[accountKey(c, posts["expired"].ID)]
+	request.Timestamp = time.Now().AddDate(0, 0, -31)
+	consentRequests[accountKey(c, posts["expired"].ID)] = request
+	consentMutex.Unlock()
+	cleanupOldConsentRequests()
+
+	want := map[string]int{"consent_requested": 3, "consent_granted": 1, "consent_denied": 1, "consent_expired": 1}
+	counts := eventCounts(mm, string(poster.ID))
+	for event, n := range want {
+		if counts[event] != n {
+			t.Errorf("%s logged %d times, want %d", event, counts[event], n)
+		}
+	}
+	consentMutex.Lock()
+	_, pending := consentRequests[accountKey(c, posts["expired"].ID)]
+	consentMutex.Unlock()
+	if pending {
+		t.Error("the expired consent request is still pending")
+	}
+}
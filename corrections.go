@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// CorrectionRecord is a correction a user replied to one of the bot's descriptions with
+type CorrectionRecord struct {
+	Timestamp        time.Time   `json:"timestamp"`
+	OriginalStatusID mastodon.ID `json:"original_status_id"`
+	ReplyID          mastodon.ID `json:"reply_id"`
+	CorrectionID     mastodon.ID `json:"correction_id"`
+	Language         string      `json:"language,omitempty"`
+	AltText          string      `json:"alt_text"`
+	Correction       string      `json:"correction"`
+}
+
+// findOriginalForReply returns the ID of the original post the given reply of the client's account belongs to
+func findOriginalForReply(c MastodonClient, replyID mastodon.ID) (mastodon.ID, bool) {
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+
+	for key, replyInfo := range replyMap {
+		if replyInfo.ReplyID == replyID && key == accountKey(c, replyInfo.OriginalID) {
+			return replyInfo.OriginalID, true
+		}
+	}
+
+	return "", false
+}
+
+// handleCorrection stores a user's reply to one of the bot's descriptions as a correction record
+func handleCorrection(c MastodonClient, originalID mastodon.ID, botReply *mastodon.Status, correction *mastodon.Status) {
+	if !config.Corrections.Enabled || isDNI(c, &correction.Account) {
+		return
+	}
+
+	// Drop the mentions so only the correction itself is stored
+	correctionText := mentionText(correction.Content)
+
+	if correctionText == "" {
+		return
+	}
+
+	record := CorrectionRecord{
+		Timestamp:        time.Now(),
+		OriginalStatusID: originalID,
+		ReplyID:          botReply.ID,
+		CorrectionID:     correction.ID,
+		Language:         correction.Language,
+		AltText:          stripHTMLTags(botReply.Content),
+		Correction:       correctionText,
+	}
+
+	if err := appendCorrection(config.Corrections.FilePath, record); err != nil {
+		log.Printf("Error saving correction: %v", err)
+		return
+	}
+
+	log.Printf("Saved correction for reply %s", botReply.ID)
+}
+
+// appendCorrection appends a correction record as a JSON line to the corrections file
+func appendCorrection(filePath string, record CorrectionRecord) error {
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(record)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// readCorrections returns the records stored in the corrections file
+func readCorrections(t *testing.T, filePath string) []CorrectionRecord {
+	t.Helper()
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []CorrectionRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record CorrectionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid correction line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestCorrectionsAreStored(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		account mastodon.Account
+		content string
+		want    string
+	}{
+		{"correction", true, mastodon.Account{ID: "reader", Acct: "reader"}, `<p><span class="h-card"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> It&#39;s a <b>dog</b>, not a cat.</p>`, "It's a dog, not a cat."},
+		{"disabled", false, mastodon.Account{ID: "reader", Acct: "reader"}, "<p>@altbot It's a dog.</p>", ""},
+		{"only a mention", true, mastodon.Account{ID: "reader", Acct: "reader"}, "<p>@altbot</p>", ""},
+		{"dni", true, mastodon.Account{ID: "private", Acct: "private", Note: "#nobot"}, "<p>@altbot It's a dog.</p>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "corrections.jsonl")
+			withConfig(t, func(c *Config) {
+				c.Corrections.Enabled = tt.enabled
+				c.Corrections.FilePath = filePath
+				c.DNI.Tags = []string{"#nobot"}
+			})
+			botReply := &mastodon.Status{ID: "described", Account: mastodon.Account{ID: "bot", Acct: "altbot"}, InReplyToID: "cat-post", Content: "<p>@poster A cat on a sofa.</p>"}
+			correction := &mastodon.Status{ID: "correction", Account: tt.account, InReplyToID: "described", Content: tt.content, Language: "en"}
+			c := newFakeClient(botReply, correction)
+			forgetReplies(t, c, "cat-post")
+			mapMutex.Lock()
+			replyMap[accountKey(c, "cat-post")] = ReplyInfo{OriginalID: "cat-post", ReplyID: "described", Timestamp: time.Now()}
+			mapMutex.Unlock()
+
+			handleNotification(c, &mastodon.Notification{ID: mastodon.ID("correction-" + tt.name), Type: "mention", Account: tt.account, Status: correction})
+
+			records := readCorrections(t, filePath)
+			if tt.want == "" {
+				if len(records) != 0 {
+					t.Errorf("stored %+v, want nothing", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("stored %d corrections, want 1", len(records))
+			}
+			record := records[0]
+			if record.OriginalStatusID != "cat-post" || record.ReplyID != "described" || record.CorrectionID != "correction" || record.Language != "en" {
+				t.Errorf("record = %+v, want it linked to the post, the reply and the correction", record)
+			}
+			if record.AltText != "@poster A cat on a sofa." || record.Correction != tt.want {
+				t.Errorf("record has alt-text %q and correction %q, want the description and %q", record.AltText, record.Correction, tt.want)
+			}
+			if posted := c.postedToots(); len(posted) != 0 {
+				t.Errorf("answered a correction with %+v", posted)
+			}
+		})
+	}
+}
+
+func TestRepliesToOtherAccountsDescriptionsAreNotCorrections(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "corrections.jsonl")
+	withConfig(t, func(c *Config) {
+		c.Corrections.Enabled = true
+		c.Corrections.FilePath = filePath
+	})
+	primary, second, _, _ := testAccounts(t)
+	forgetReplies(t, primary, "shared-post")
+	mapMutex.Lock()
+	replyMap[accountKey(primary, "shared-post")] = ReplyInfo{OriginalID: "shared-post", ReplyID: "primary-reply", Timestamp: time.Now()}
+	mapMutex.Unlock()
+
+	if _, ok := findOriginalForReply(primary, "primary-reply"); !ok {
+		t.Error("the primary account doesn't know its own reply")
+	}
+	if _, ok := findOriginalForReply(second, "primary-reply"); ok {
+		t.Error("the second account claimed the primary account's reply")
+	}
+}
@@ -32,6 +32,7 @@ function getChartDefaults() {
 function processMetrics(data) {
     const eventCounts = {};
     const mediaTypeCounts = {};
+    const failureCategoryCounts = {};
     const userSet = new Set();
     const imageResponseTimes = [];
     const hourlyActivity = Array(24).fill(0);
@@ -56,6 +57,12 @@ function processMetrics(data) {
             }
         }
 
+        // Break down failed generations by the category of their error
+        if (event.EventType === 'failed_generation' && event.Details) {
+            const category = event.Details.category || 'other';
+            failureCategoryCounts[category] = (failureCategoryCounts[category] || 0) + 1;
+        }
+
         // Hourly activity
         const hour = new Date(event.Timestamp).getHours();
         hourlyActivity[hour]++;
@@ -98,6 +105,7 @@ function processMetrics(data) {
     return {
         eventCounts,
         mediaTypeCounts,
+        failureCategoryCounts,
         uniqueUsers: userSet.size,
         imageResponseTimes,
         hourlyActivity,
@@ -162,6 +170,36 @@ function updateCharts(metrics) {
             }
         }
     });
+
+    // Failure Category Distribution Pie Chart
+    const ctx4 = document.getElementById('failureCategoryPie').getContext('2d');
+    if (charts.failureCategoryPie) charts.failureCategoryPie.destroy();
+    charts.failureCategoryPie = new Chart(ctx4, {
+        type: 'pie',
+        data: {
+            labels: Object.keys(metrics.failureCategoryCounts),
+            datasets: [{
+                data: Object.values(metrics.failureCategoryCounts),
+                backgroundColor: [
+                    '#ef4444',
+                    '#f97316',
+                    '#f59e0b',
+                    '#eab308',
+                    '#dc2626',
+                    '#b91c1c',
+                    '#9ca3af'
+                ]
+            }]
+        },
+        options: {
+            ...defaultOptions,
+            plugins: {
+                legend: {
+                    display: false
+                }
+            }
+        }
+    });
     const ctx3 = document.getElementById('combinedChart').getContext('2d');
     if (charts.combinedChart) charts.combinedChart.destroy();
 
@@ -295,6 +333,12 @@ function getEventIcon(type) {
         request: '🔍',
         successful_generation: '✨',
         rate_limit_hit: '⚠️',
+        provider_latency: '⏱️',
+        failed_generation: '❌',
+        consent_requested: '🙋',
+        consent_granted: '✅',
+        consent_denied: '🚫',
+        consent_expired: '⌛',
         follow: '👤',
         error: '❌'
     };
@@ -306,6 +350,12 @@ function getEventColor(type) {
         request: 'linear-gradient(135deg, #6366f1, #8b5cf6)',
         successful_generation: 'linear-gradient(135deg, #22c55e, #16a34a)',
         rate_limit_hit: 'linear-gradient(135deg, #f59e0b, #d97706)',
+        provider_latency: 'linear-gradient(135deg, #64748b, #475569)',
+        failed_generation: 'linear-gradient(135deg, #ef4444, #dc2626)',
+        consent_requested: 'linear-gradient(135deg, #a855f7, #9333ea)',
+        consent_granted: 'linear-gradient(135deg, #22c55e, #16a34a)',
+        consent_denied: 'linear-gradient(135deg, #f97316, #ea580c)',
+        consent_expired: 'linear-gradient(135deg, #64748b, #475569)',
         follow: 'linear-gradient(135deg, #06b6d4, #0891b2)',
         error: 'linear-gradient(135deg, #ef4444, #dc2626)'
     };
@@ -362,9 +412,16 @@ function updateTimeline(data, append = false) {
 
         let details = '';
         if (event.Details) {
+            if (event.Details.provider) {
+                details += `Provider: ${event.Details.provider} • `;
+            }
             if (event.Details.mediaType) {
                 details += `Media Type: ${event.Details.mediaType}`;
             }
+            if (event.Details.category) {
+                details += details ? ' • ' : '';
+                details += `Category: ${event.Details.category}`;
+            }
             if (event.Details.responseTime) {
                 details += details ? ' • ' : '';
                 details += `Response Time: ${event.Details.responseTime}ms`;
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// isDataURI reports whether the URL embeds its media inline, as some federated software does for small images
+func isDataURI(mediaURL string) bool {
+	return len(mediaURL) > 5 && strings.EqualFold(mediaURL[:5], "data:")
+}
+
+// isImageDataURI reports whether the data URI embeds an image
+func isImageDataURI(mediaURL string) bool {
+	return isDataURI(mediaURL) && strings.HasPrefix(strings.ToLower(strings.TrimSpace(mediaURL[5:])), "image/")
+}
+
+// loggableURL shortens data URIs, which can be megabytes long, to their header and size for the logs
+func loggableURL(mediaURL string) string {
+	if !isDataURI(mediaURL) {
+		return mediaURL
+	}
+	header, payload, _ := strings.Cut(mediaURL, ",")
+	if len(header) > 64 {
+		header = header[:64] + "…"
+	}
+	return fmt.Sprintf("%s,…(%d bytes)", header, len(payload))
+}
+
+// recordedMediaURL returns the URL to keep in records like the dead letters. Data URIs are replaced
+// by their media type and SHA-256, which is enough to find them in the post again.
+func recordedMediaURL(mediaURL string) string {
+	if !isDataURI(mediaURL) {
+		return mediaURL
+	}
+	header, _, _ := strings.Cut(mediaURL[5:], ",")
+	mediaType, _, _ := strings.Cut(header, ";")
+	if len(mediaType) > 64 {
+		mediaType = mediaType[:64]
+	}
+	digest := sha256.Sum256([]byte(mediaURL))
+	return "data:" + mediaType + ";sha256," + hex.EncodeToString(digest[:])
+}
+
+// decodeDataURI returns the media embedded in a data URI, either base64 or percent-encoded,
+// respecting the same size limit as downloaded media
+func decodeDataURI(dataURI string) ([]byte, error) {
+	header, payload, found := strings.Cut(dataURI[5:], ",")
+	if !found {
+		return nil, fmt.Errorf("%w: malformed data URI", ErrUnsupportedFormat)
+	}
+
+	maxSize := int(config.ImageProcessing.MaxSizeMB) * 1024 * 1024
+	isBase64 := strings.HasSuffix(strings.ToLower(strings.TrimSpace(header)), ";base64")
+
+	// Base64 grows the data by a third, so the size is known before decoding
+	if isBase64 && maxSize > 0 && len(payload)/4*3 > maxSize {
+		return nil, fmt.Errorf("%w: data URI exceeds maximum limit of %d MB", ErrMediaTooLarge, config.ImageProcessing.MaxSizeMB)
+	}
+
+	var data []byte
+	var err error
+	if isBase64 {
+		// Line breaks and spaces are allowed in base64 and may be percent-encoded in HTML attributes
+		payload, err = url.PathUnescape(payload)
+		if err == nil {
+			payload = strings.Join(strings.Fields(payload), "")
+			data, err = base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+			}
+		}
+	} else {
+		var unescaped string
+		unescaped, err = url.PathUnescape(payload)
+		data = []byte(unescaped)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid data URI: %v", ErrUnsupportedFormat, err)
+	}
+
+	if maxSize > 0 && len(data) > maxSize {
+		return nil, fmt.Errorf("%w: data URI exceeds maximum limit of %d MB", ErrMediaTooLarge, config.ImageProcessing.MaxSizeMB)
+	}
+	return data, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDataURI(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxSizeMB = 1 })
+
+	tests := []struct {
+		name string
+		uri  string
+		want []byte
+		err  error
+	}{
+		{"base64", "data:image/png;base64,aGVsbG8=", []byte("hello"), nil},
+		{"base64 without padding", "data:image/png;base64,aGVsbG8", []byte("hello"), nil},
+		{"base64 with line breaks", "data:image/png;base64,aGVs%0AbG8=", []byte("hello"), nil},
+		{"uppercase scheme", "DATA:image/png;BASE64,aGVsbG8=", []byte("hello"), nil},
+		{"percent-encoded", "data:image/svg+xml,%3Csvg%2F%3E", []byte("<svg/>"), nil},
+		{"missing comma", "data:image/png;base64", nil, ErrUnsupportedFormat},
+		{"invalid base64", "data:image/png;base64,!!!!", nil, ErrUnsupportedFormat},
+		{"too large", "data:image/png;base64," + strings.Repeat("AAAA", 350000), nil, ErrMediaTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeDataURI(tt.uri)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataURIsStayOutOfLogsAndRecords(t *testing.T) {
+	uri := "data:image/png;base64," + strings.Repeat("A", 4000)
+
+	if got, want := loggableURL(uri), "data:image/png;base64,…(4000 bytes)"; got != want {
+		t.Errorf("loggableURL = %q, want %q", got, want)
+	}
+	if got := loggableURL("https://example.social/a.png"); got != "https://example.social/a.png" {
+		t.Errorf("loggableURL changed a regular URL to %q", got)
+	}
+
+	recorded := recordedMediaURL(uri)
+	if !strings.HasPrefix(recorded, "data:image/png;sha256,") || len(recorded) > 100 {
+		t.Errorf("recordedMediaURL = %q", recorded)
+	}
+	if recordedMediaURL(uri+"A") == recorded {
+		t.Error("different data URIs are recorded the same")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// deadLetterFile holds the failed generations, one JSON object per line
+const deadLetterFile = "dead_letters.json"
+
+var deadLetterMutex sync.Mutex
+
+// DeadLetter is a generation that failed for a reason other than the media itself,
+// like a provider outage, and can be tried again later
+type DeadLetter struct {
+	Timestamp      time.Time   `json:"timestamp"`
+	Account        string      `json:"account,omitempty"`
+	StatusID       mastodon.ID `json:"status_id"`
+	ReplyToID      mastodon.ID `json:"reply_to_id"`
+	AttachmentURL  string      `json:"attachment_url"`
+	AttachmentType string      `json:"attachment_type"`
+	Error          string      `json:"error"`
+}
+
+// isDeadLetterError reports whether a failed generation is worth trying again. Denied, blocked,
+// unreadable and oversized media would fail the same way again.
+func isDeadLetterError(err error) bool {
+	if errors.Is(err, ErrDeniedImage) {
+		return false
+	}
+	switch errorCategory(err) {
+	case ErrorProviderBlocked, ErrorUnsupportedFormat:
+		return false
+	case ErrorSizeLimit:
+		return errors.Is(err, ErrTempStorageFull)
+	}
+	return true
+}
+
+// recordDeadLetter appends the failed generation of the client's account to the dead-letter file
+func recordDeadLetter(c MastodonClient, status *mastodon.Status, replyToID mastodon.ID, attachment mastodon.Attachment, err error) {
+	if !config.Behavior.DeadLetters || !isDeadLetterError(err) {
+		return
+	}
+
+	appendDeadLetters([]DeadLetter{{
+		Timestamp:      time.Now(),
+		Account:        accountUsername(c),
+		StatusID:       status.ID,
+		ReplyToID:      replyToID,
+		AttachmentURL:  recordedMediaURL(attachment.URL),
+		AttachmentType: attachment.Type,
+		Error:          err.Error(),
+	}})
+}
+
+// appendDeadLetters writes the entries to the end of the dead-letter file
+func appendDeadLetters(entries []DeadLetter) {
+	deadLetterMutex.Lock()
+	defer deadLetterMutex.Unlock()
+
+	file, err := os.OpenFile(deadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Error opening dead-letter file: %v", err)
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			log.Printf("Error writing dead letter: %v", err)
+			return
+		}
+	}
+}
+
+// takeDeadLetters reads and clears the dead-letter file. Generations that fail again are recorded anew,
+// entries that can't be tried yet are put back by the caller.
+func takeDeadLetters() ([]DeadLetter, error) {
+	deadLetterMutex.Lock()
+	defer deadLetterMutex.Unlock()
+
+	file, err := os.Open(deadLetterFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []DeadLetter
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Error decoding dead letter: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	file.Close()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, os.Remove(deadLetterFile)
+}
+
+// reprocessDeadLetters describes the failed attachments again with the account that failed them,
+// replying where the original reply would have gone. Entries whose post can't be fetched right now
+// are kept for the next run. It returns the number of attachments that were tried again.
+func reprocessDeadLetters() (int, error) {
+	// The failed attachments stay recorded until the bot is resumed
+	if isPaused() {
+		return 0, ErrPaused
+	}
+
+	entries, err := takeDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+
+	// Attachments of the same post and request are described together in one reply
+	type request struct {
+		account   string
+		statusID  mastodon.ID
+		replyToID mastodon.ID
+	}
+	var order []request
+	pending := make(map[request][]DeadLetter)
+	for _, entry := range entries {
+		key := request{entry.Account, entry.StatusID, entry.ReplyToID}
+		if pending[key] == nil {
+			order = append(order, key)
+		}
+		pending[key] = append(pending[key], entry)
+	}
+
+	reprocessed := 0
+	for _, key := range order {
+		account, ok := accountByUsername(key.account)
+		if !ok {
+			log.Printf("Keeping dead letter for status %s, account %q isn't connected", key.statusID, key.account)
+			appendDeadLetters(pending[key])
+			continue
+		}
+
+		status, err := fetchStatus(ctx, account, key.statusID)
+		if err != nil {
+			if isStatusGone(err) {
+				log.Printf("Dropping dead letter for status %s: %v", key.statusID, err)
+			} else {
+				log.Printf("Keeping dead letter for status %s: %v", key.statusID, err)
+				appendDeadLetters(pending[key])
+			}
+			continue
+		}
+
+		urls := make(map[string]string) // Attachment URL to attachment type
+		for _, entry := range pending[key] {
+			urls[entry.AttachmentURL] = entry.AttachmentType
+		}
+
+		// Linked images, card images and images sent by URL aren't attachments of the post itself.
+		// Embedded images are only recorded by their digest, so they're taken from the post again.
+		if len(status.MediaAttachments) == 0 {
+			for url, attachmentType := range urls {
+				if !isDataURI(url) {
+					status.MediaAttachments = append(status.MediaAttachments, mastodon.Attachment{Type: attachmentType, URL: url})
+				}
+			}
+			for _, link := range extractLinks(status.Content) {
+				if _, failed := urls[recordedMediaURL(link)]; failed && isImageDataURI(link) {
+					status.MediaAttachments = append(status.MediaAttachments, mastodon.Attachment{Type: "image", URL: link})
+				}
+			}
+		}
+
+		var selection []int
+		for i, attachment := range status.MediaAttachments {
+			if _, failed := urls[recordedMediaURL(attachment.URL)]; failed {
+				selection = append(selection, i+1)
+			}
+		}
+		if len(selection) == 0 {
+			continue
+		}
+
+		generateAndPostAltText(account, status, key.replyToID, selection)
+		reprocessed += len(selection)
+	}
+
+	return reprocessed, nil
+}
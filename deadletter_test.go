@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useAccounts registers the accounts as connected for the duration of the test
+func useAccounts(t *testing.T, accounts ...*botAccount) {
+	t.Helper()
+	for _, account := range accounts {
+		botAccounts[account.Username] = account
+	}
+	t.Cleanup(func() {
+		for _, account := range accounts {
+			delete(botAccounts, account.Username)
+		}
+		os.Remove(deadLetterFile)
+	})
+}
+
+func TestReprocessKeepsDeadLettersItCantRetry(t *testing.T) {
+	primary, _, primaryClient, _ := testAccounts(t)
+	withConfig(t, func(c *Config) { c.Server.Username = "altbot" })
+	useAccounts(t, primary)
+	primaryClient.statusErrs["down"] = errors.New("connection refused")
+
+	appendDeadLetters([]DeadLetter{
+		{Account: "altbot", StatusID: "down", AttachmentURL: "https://example.social/a.png", AttachmentType: "image"},
+		{StatusID: "gone", AttachmentURL: "https://example.social/b.png", AttachmentType: "image"},
+		{Account: "removed", StatusID: "other", AttachmentURL: "https://example.social/c.png", AttachmentType: "image"},
+	})
+
+	count, err := reprocessDeadLetters()
+	if err != nil || count != 0 {
+		t.Fatalf("reprocessDeadLetters() = %d, %v, want 0, nil", count, err)
+	}
+
+	kept, err := takeDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statusIDs []string
+	for _, entry := range kept {
+		statusIDs = append(statusIDs, string(entry.StatusID))
+	}
+	if strings.Join(statusIDs, ",") != "down,other" {
+		t.Errorf("kept dead letters for %v, want [down other]", statusIDs)
+	}
+}
+
+func TestReprocessUsesTheFailedAccount(t *testing.T) {
+	primary, second, primaryClient, secondClient := testAccounts(t)
+	withConfig(t, func(c *Config) {
+		c.Server.Username = "altbot"
+		c.Behavior.DeadLetters = true
+		c.RateLimit.Enabled = false
+	})
+	useAccounts(t, primary, second)
+	useProvider(t, &fakeProvider{response: "A green square"})
+
+	imageURL := dataURI("image/png", testImage(t, 8, 8, color.RGBA{0, 255, 0, 255}))
+	secondClient.statuses["post"] = &mastodon.Status{
+		ID:               "post",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: imageURL}},
+	}
+	secondClient.statuses["mention"] = &mastodon.Status{
+		ID:          "mention",
+		InReplyToID: "post",
+		Account:     mastodon.Account{ID: "requester", Acct: "requester"},
+		Visibility:  "public",
+		Language:    "en",
+	}
+	recordDeadLetter(second, secondClient.statuses["post"], "mention", secondClient.statuses["post"].MediaAttachments[0], ErrMediaUnavailable)
+
+	count, err := reprocessDeadLetters()
+	if err != nil || count != 1 {
+		t.Fatalf("reprocessDeadLetters() = %d, %v, want 1, nil", count, err)
+	}
+
+	posted := secondClient.postedToots()
+	if len(posted) != 1 || posted[0].InReplyToID != "mention" || !strings.Contains(posted[0].Status, "A green square") {
+		t.Errorf("second account posted %+v", posted)
+	}
+	if len(primaryClient.postedToots()) != 0 || len(primaryClient.fetched) != 0 {
+		t.Error("the primary account was used for the second account's dead letter")
+	}
+}
+
+func TestReprocessFindsEmbeddedImagesAgain(t *testing.T) {
+	primary, _, primaryClient, _ := testAccounts(t)
+	withConfig(t, func(c *Config) {
+		c.Server.Username = "altbot"
+		c.Behavior.DeadLetters = true
+		c.RateLimit.Enabled = false
+	})
+	useAccounts(t, primary)
+	useProvider(t, &fakeProvider{response: "A white square"})
+
+	imageURL := dataURI("image/png", testImage(t, 8, 8, color.White))
+	post := &mastodon.Status{
+		ID:         "post",
+		Account:    mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility: "public",
+		Content:    `<p>Look <img src="` + imageURL + `"></p>`,
+	}
+	primaryClient.statuses["post"] = post
+	primaryClient.statuses["mention"] = &mastodon.Status{
+		ID:          "mention",
+		InReplyToID: "post",
+		Account:     mastodon.Account{ID: "requester", Acct: "requester"},
+		Visibility:  "public",
+		Language:    "en",
+	}
+	recordDeadLetter(primary, post, "mention", mastodon.Attachment{Type: "image", URL: imageURL}, ErrMediaUnavailable)
+
+	data, err := os.ReadFile(deadLetterFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), imageURL) {
+		t.Error("the dead letter holds the whole data URI")
+	}
+
+	if count, err := reprocessDeadLetters(); err != nil || count != 1 {
+		t.Fatalf("reprocessDeadLetters() = %d, %v, want 1, nil", count, err)
+	}
+	if posted := primaryClient.postedToots(); len(posted) != 1 || !strings.Contains(posted[0].Status, "A white square") {
+		t.Errorf("posted %+v", posted)
+	}
+}
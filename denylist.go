@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDeniedImage is returned when an image matches the configured deny list
+var ErrDeniedImage = errors.New("image is on the deny list")
+
+// ImageDenyList holds the SHA-256 hashes of images that should never be described
+type ImageDenyList struct {
+	hashes  map[string]bool
+	modTime time.Time
+	loaded  bool
+	mu      sync.Mutex
+}
+
+var imageDenyList ImageDenyList
+
+// Contains reports whether the image data matches a denied hash.
+// The hash file is reloaded whenever it has been modified.
+func (dl *ImageDenyList) Contains(data []byte) bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.reloadIfChanged()
+
+	if len(dl.hashes) == 0 {
+		return false
+	}
+
+	sum := sha256.Sum256(data)
+	return dl.hashes[hex.EncodeToString(sum[:])]
+}
+
+// reloadIfChanged rebuilds the hash set from the config and the hash file if the file changed
+func (dl *ImageDenyList) reloadIfChanged() {
+	var modTime time.Time
+	filePath := config.Moderation.DeniedImageHashesFile
+	if filePath != "" {
+		info, err := os.Stat(filePath)
+		if err == nil {
+			modTime = info.ModTime()
+		}
+	}
+
+	if dl.loaded && modTime.Equal(dl.modTime) {
+		return
+	}
+
+	hashes := make(map[string]bool)
+	for _, hash := range config.Moderation.DeniedImageHashes {
+		hashes[strings.ToLower(strings.TrimSpace(hash))] = true
+	}
+
+	if !modTime.IsZero() {
+		fileHashes, err := readHashFile(filePath)
+		if err != nil {
+			log.Printf("Error reading denied image hashes file: %v", err)
+		}
+		for _, hash := range fileHashes {
+			hashes[hash] = true
+		}
+		log.Printf("Loaded %d denied image hashes", len(hashes))
+	}
+
+	dl.hashes = hashes
+	dl.modTime = modTime
+	dl.loaded = true
+}
+
+// readHashFile reads one hash per line, ignoring empty lines and comments starting with #
+func readHashFile(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var hashes []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hashes = append(hashes, strings.ToLower(strings.Fields(line)[0]))
+	}
+
+	return hashes, scanner.Err()
+}
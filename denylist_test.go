@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+)
+
+// useDenyList starts the test with an empty deny list, which is reloaded from the modified config
+func useDenyList(t *testing.T) {
+	t.Helper()
+	imageDenyList = ImageDenyList{}
+	t.Cleanup(func() { imageDenyList = ImageDenyList{} })
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDeniedImageIsNotDescribed(t *testing.T) {
+	denied := testImage(t, 4, 4, color.Black)
+	allowed := testImage(t, 4, 4, color.White)
+	withConfig(t, func(c *Config) {
+		c.Moderation.DeniedImageHashes = []string{" " + strings.ToUpper(sha256Hex(denied)) + " "}
+	})
+	useDenyList(t)
+	provider := &fakeProvider{response: "A square"}
+	useProvider(t, provider)
+
+	if _, err := generateImageAltText(dataURI("image/png", denied), "", "en", "", false, nil); !errors.Is(err, ErrDeniedImage) {
+		t.Errorf("generateImageAltText = %v for a denied image, want ErrDeniedImage", err)
+	}
+	if provider.calls() != 0 {
+		t.Error("the denied image was sent to the provider")
+	}
+
+	if _, err := generateImageAltText(dataURI("image/png", allowed), "", "en", "", false, nil); err != nil {
+		t.Errorf("generateImageAltText = %v for an allowed image", err)
+	}
+}
+
+func TestDenyListReadsTheHashFile(t *testing.T) {
+	denied := []byte("denied image")
+	file := t.TempDir() + "/denied.txt"
+	content := "# Known bad images\n\n" + sha256Hex(denied) + " spam campaign\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(c *Config) { c.Moderation.DeniedImageHashesFile = file })
+
+	var denyList ImageDenyList
+	if !denyList.Contains(denied) {
+		t.Error("the image listed in the hash file isn't denied")
+	}
+	if denyList.Contains([]byte("another image")) {
+		t.Error("an unlisted image is denied")
+	}
+}
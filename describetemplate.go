@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TemplatedDescription is an image description split into the parts of the description template
+type TemplatedDescription struct {
+	Subject string
+	Setting string
+	Details string
+	Text    string
+}
+
+// descriptionTemplateMode returns the configured llm.description_template, "prose", "labeled" or "" if off.
+// Gemini's structured output has its own template and takes precedence.
+func descriptionTemplateMode() string {
+	if config.Gemini.StructuredOutput {
+		return ""
+	}
+
+	switch mode := strings.ToLower(config.LLM.DescriptionTemplate); mode {
+	case "prose", "labeled":
+		return mode
+	default:
+		return ""
+	}
+}
+
+// parseTemplatedDescription reads the labeled lines the model was asked for. Lines without a label
+// continue the previous part. It reports false if the response doesn't follow the template at all.
+func parseTemplatedDescription(response string) (TemplatedDescription, bool) {
+	var description TemplatedDescription
+	fields := map[string]*string{
+		"SUBJECT": &description.Subject,
+		"SETTING": &description.Setting,
+		"DETAILS": &description.Details,
+		"TEXT":    &description.Text,
+	}
+
+	found := false
+	var current *string
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.Trim(strings.TrimSpace(line), "*"))
+		if line == "" {
+			continue
+		}
+
+		if label, value, ok := strings.Cut(line, ":"); ok {
+			if field, isLabel := fields[strings.ToUpper(strings.Trim(strings.TrimSpace(label), "*"))]; isLabel {
+				current = field
+				*current = strings.TrimSpace(strings.Trim(value, "* "))
+				found = true
+				continue
+			}
+		}
+		if current != nil {
+			*current = strings.TrimSpace(*current + " " + line)
+		}
+	}
+
+	return description, found && description.Subject != ""
+}
+
+// formatTemplatedDescription renders the description as prose or as labeled lines, leaving out empty parts
+func formatTemplatedDescription(description TemplatedDescription, mode string, lang string) string {
+	parts := []struct {
+		labelKey string
+		value    string
+	}{
+		{"templateSubject", description.Subject},
+		{"templateSetting", description.Setting},
+		{"templateDetails", description.Details},
+	}
+
+	var lines []string
+	var sentences []string
+	for _, part := range parts {
+		if part.value == "" || isEmptyTemplateValue(part.value) {
+			continue
+		}
+		if mode == "labeled" {
+			lines = append(lines, getLocalizedString(lang, part.labelKey, "response")+": "+part.value)
+		} else {
+			sentences = append(sentences, sentence(part.value))
+		}
+	}
+	if len(sentences) > 0 {
+		lines = append(lines, strings.Join(sentences, " "))
+	}
+
+	if text := description.Text; text != "" && !isEmptyTemplateValue(text) {
+		lines = append(lines, getLocalizedString(lang, "templateText", "response")+": \""+strings.Trim(text, "\"")+"\"")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// applyDescriptionTemplate formats a response to the templated prompt, leaving other responses as they are
+func applyDescriptionTemplate(altText string, lang string) string {
+	mode := descriptionTemplateMode()
+	if mode == "" {
+		return altText
+	}
+	description, ok := parseTemplatedDescription(altText)
+	if !ok {
+		return altText
+	}
+	return formatTemplatedDescription(description, mode, lang)
+}
+
+// isEmptyTemplateValue reports whether the model filled a part with a placeholder for nothing
+func isEmptyTemplateValue(value string) bool {
+	switch strings.ToLower(strings.Trim(value, " .-()")) {
+	case "", "none", "n/a", "nothing", "no text":
+		return true
+	}
+	return false
+}
+
+// sentence makes the part start and end like a sentence, so the parts read as prose when joined
+func sentence(value string) string {
+	first, size := utf8.DecodeRuneInString(value)
+	value = string(unicode.ToUpper(first)) + value[size:]
+
+	if strings.HasSuffix(value, ".") || strings.HasSuffix(value, "!") || strings.HasSuffix(value, "?") || strings.HasSuffix(value, "。") {
+		return value
+	}
+	return value + "."
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestParseTemplatedDescription(t *testing.T) {
+	response := "**SUBJECT:** a red bus\nSetting: a rainy street\nat night\nDETAILS: none\nText: \"Route 7\"\n"
+
+	description, ok := parseTemplatedDescription(response)
+	if !ok {
+		t.Fatal("the labeled response wasn't recognised")
+	}
+	want := TemplatedDescription{Subject: "a red bus", Setting: "a rainy street at night", Details: "none", Text: "\"Route 7\""}
+	if description != want {
+		t.Errorf("parsed %+v, want %+v", description, want)
+	}
+
+	for _, response := range []string{"A red bus on a rainy street.", "SETTING: a rainy street"} {
+		if _, ok := parseTemplatedDescription(response); ok {
+			t.Errorf("parseTemplatedDescription(%q) was accepted without a subject", response)
+		}
+	}
+}
+
+func TestFormatTemplatedDescription(t *testing.T) {
+	description := TemplatedDescription{Subject: "a red bus", Setting: "a rainy street", Details: "n/a", Text: "Route 7"}
+
+	if got, want := formatTemplatedDescription(description, "prose", "en"), "A red bus. A rainy street.\nText: \"Route 7\""; got != want {
+		t.Errorf("prose = %q, want %q", got, want)
+	}
+	if got, want := formatTemplatedDescription(description, "labeled", "en"), "Subject: a red bus\nSetting: a rainy street\nText: \"Route 7\""; got != want {
+		t.Errorf("labeled = %q, want %q", got, want)
+	}
+}
+
+func TestApplyDescriptionTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		structured bool
+		response   string
+		want       string
+	}{
+		{"off", "", false, "SUBJECT: a cat", "SUBJECT: a cat"},
+		{"prose", "prose", false, "SUBJECT: a cat\nTEXT: none", "A cat."},
+		{"untemplated answer", "prose", false, "A cat on a sofa.", "A cat on a sofa."},
+		{"structured output wins", "labeled", true, "SUBJECT: a cat", "SUBJECT: a cat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.LLM.DescriptionTemplate = tt.template
+				c.Gemini.StructuredOutput = tt.structured
+			})
+			if got := applyDescriptionTemplate(tt.response, "en"); got != tt.want {
+				t.Errorf("applyDescriptionTemplate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
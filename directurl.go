@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// handleDirectImageURL describes the images linked in a direct message to the bot that isn't a reply,
+// answering in the same direct conversation. It reports whether the message contained any.
+func handleDirectImageURL(c MastodonClient, notification *mastodon.Notification) bool {
+	message := notification.Status
+	if !config.ImageProcessing.DescribeDirectURLs || message.Visibility != "direct" || len(message.MediaAttachments) > 0 {
+		return false
+	}
+
+	attachments := linkedImageAttachments(message.Content)
+	if len(attachments) == 0 {
+		return false
+	}
+
+	log.Printf("Describing %d linked images sent by @%s in a direct message", len(attachments), notification.Account.Acct)
+
+	// The message itself is described, so rate limits and the reply visibility apply as for any post
+	status := *message
+	status.MediaAttachments = attachments
+	generateAndPostAltText(c, &status, message.ID, nil)
+	return true
+}
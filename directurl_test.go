@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestImageURLsInDirectMessagesAreDescribed(t *testing.T) {
+	server, _ := linkServer(t)
+	withConfig(t, func(c *Config) {
+		c.ImageProcessing.DescribeDirectURLs = true
+		c.ImageProcessing.MaxSizeMB = 1
+		c.RateLimit.Enabled = false
+	})
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+
+	message := &mastodon.Status{
+		ID:         "dm",
+		Account:    mastodon.Account{ID: "sender", Acct: "sender"},
+		Content:    linksContent(server.URL, "/page", "/img1"),
+		Visibility: "direct",
+		Language:   "en",
+	}
+	c := newFakeClient(message)
+	forgetReplies(t, c, message.ID)
+
+	if !handleDirectImageURL(c, &mastodon.Notification{Account: message.Account, Status: message}) {
+		t.Fatal("the linked image wasn't recognised")
+	}
+	if provider.calls() != 1 {
+		t.Errorf("described %d images, want the one linked image", provider.calls())
+	}
+	posted := c.postedToots()
+	if len(posted) != 1 || posted[0].InReplyToID != "dm" || posted[0].Visibility != "direct" {
+		t.Errorf("posted %+v, want a direct reply to the message", posted)
+	}
+}
+
+func TestImageURLsOutsideDirectMessagesAreIgnored(t *testing.T) {
+	server, requests := linkServer(t)
+	content := linksContent(server.URL, "/img1")
+
+	tests := []struct {
+		name    string
+		enabled bool
+		message mastodon.Status
+	}{
+		{"disabled", false, mastodon.Status{ID: "dm", Visibility: "direct", Content: content}},
+		{"public", true, mastodon.Status{ID: "post", Visibility: "public", Content: content}},
+		{"with attachments", true, mastodon.Status{ID: "dm", Visibility: "direct", Content: content, MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image"}}}},
+		{"without links", true, mastodon.Status{ID: "dm", Visibility: "direct", Content: "<p>@altbot hello</p>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.ImageProcessing.DescribeDirectURLs = tt.enabled })
+			requests.Store(0)
+			c := newFakeClient()
+
+			if handleDirectImageURL(c, &mastodon.Notification{Account: tt.message.Account, Status: &tt.message}) {
+				t.Error("the message was handled as a direct image URL")
+			}
+			if len(c.postedToots()) != 0 || requests.Load() != 0 {
+				t.Errorf("posted %d replies and checked %d links", len(c.postedToots()), requests.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// maxDescribedEmojis limits how many custom emojis of a single post are described
+const maxDescribedEmojis = 8
+
+// describeEmojis replies to an "emoji" command with descriptions of the custom emojis used in the post
+func describeEmojis(c MastodonClient, status *mastodon.Status, notification *mastodon.Notification) {
+	lang := notification.Status.Language
+
+	emojis := status.Emojis
+	if len(emojis) > maxDescribedEmojis {
+		emojis = emojis[:maxDescribedEmojis]
+	}
+
+	var lines []string
+	if len(emojis) == 0 {
+		lines = append(lines, getLocalizedString(lang, "noCustomEmojis", "response"))
+	} else {
+		mediaTypes := make([]string, len(emojis))
+		for i := range mediaTypes {
+			mediaTypes[i] = "image"
+		}
+
+		if !rateLimiter.IncrementAll(c, string(notification.Account.ID), mediaTypes) {
+			log.Printf("User @%s has exceeded their rate limit", notification.Account.Acct)
+			metricsManager.logRateLimitHit(string(notification.Account.ID))
+			if config.RateLimit.ExceededReply == "silent" {
+				return
+			}
+			lines = append(lines, getLocalizedString(lang, "rateLimitExceeded", "response"))
+		} else {
+			lines = emojiDescriptions(emojis, lang)
+			if len(lines) == 0 {
+				lines = append(lines, getLocalizedString(lang, "altTextError", "response"))
+			}
+		}
+	}
+
+	message := renderReplyTemplate(config.Behavior.ReplyTemplate, "@"+notification.Account.Acct, strings.Join(lines, "\n"), providerFooter(c, lang))
+
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: notification.Status.ID,
+		Visibility:  mapReplyVisibility(settingsFor(c).ReplyVisibility, notification.Status.Visibility),
+		Language:    lang,
+	})
+	if err != nil {
+		log.Printf("Error posting emoji descriptions: %v", err)
+	}
+}
+
+// emojiDescriptions describes each emoji, returning one ":shortcode: description" line per emoji
+func emojiDescriptions(emojis []mastodon.Emoji, lang string) []string {
+	var lines []string
+	for _, emoji := range emojis {
+		emojiURL := emoji.StaticURL
+		if emojiURL == "" {
+			emojiURL = emoji.URL
+		}
+
+		altText, err := generateImageAltText(emojiURL, "", lang, "", false, nil)
+		if err != nil || altText == "" {
+			log.Printf("Error describing emoji :%s:: %v", emoji.ShortCode, err)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(":%s: %s", emoji.ShortCode, truncateAltText(altText, config.LLM.MaxAltChars)))
+	}
+	return lines
+}
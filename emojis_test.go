@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// emojiPost returns a post using the given number of custom emojis
+func emojiPost(t *testing.T, id mastodon.ID, count int) *mastodon.Status {
+	status := &mastodon.Status{ID: id, Account: mastodon.Account{ID: "emoji-poster", Acct: "emoji-poster"}, Visibility: "public"}
+	for i := 0; i < count; i++ {
+		image := dataURI("image/png", testImage(t, 4, 4, color.RGBA{B: uint8(10 * i), A: 255}))
+		emoji := mastodon.Emoji{ShortCode: fmt.Sprintf("blob%d", i), URL: image}
+		if i%2 == 0 {
+			// The static version is preferred for animated emojis
+			emoji.URL, emoji.StaticURL = "https://files.example/animated.gif", image
+		}
+		status.Emojis = append(status.Emojis, emoji)
+	}
+	return status
+}
+
+// emojiRequest describes the emojis of the post as asked for by a mention
+func emojiRequest(c *fakeClient, status *mastodon.Status) {
+	mention := &mastodon.Status{ID: "emoji-mention", Account: mastodon.Account{ID: "asker", Acct: "asker"}, Content: "<p>@altbot emoji</p>", Visibility: "unlisted", Language: "en"}
+	describeEmojis(c, status, &mastodon.Notification{Type: "mention", Account: mention.Account, Status: mention})
+}
+
+func TestDescribeEmojis(t *testing.T) {
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+	provider := &fakeProvider{response: "A blue blob."}
+	useProvider(t, provider)
+	c := newFakeClient()
+
+	emojiRequest(c, emojiPost(t, "emoji-post", 2))
+
+	posted := c.postedToots()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d replies, want 1", len(posted))
+	}
+	if !strings.HasPrefix(posted[0].Status, "@asker :blob0: A blue blob.\n:blob1: A blue blob.") {
+		t.Errorf("reply = %q, want a line per emoji", posted[0].Status)
+	}
+	if posted[0].InReplyToID != "emoji-mention" || posted[0].Visibility != "unlisted" {
+		t.Errorf("reply = %+v, want an unlisted reply to the mention", posted[0])
+	}
+	if provider.calls() != 2 {
+		t.Errorf("the provider was called %d times, want 2", provider.calls())
+	}
+}
+
+func TestDescribeEmojisLimitsTheCount(t *testing.T) {
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+	provider := &fakeProvider{response: "A blob."}
+	useProvider(t, provider)
+	c := newFakeClient()
+
+	emojiRequest(c, emojiPost(t, "many-emojis", maxDescribedEmojis+3))
+
+	if provider.calls() != maxDescribedEmojis {
+		t.Errorf("described %d emojis, want %d", provider.calls(), maxDescribedEmojis)
+	}
+}
+
+func TestDescribeEmojisFallbacks(t *testing.T) {
+	tests := []struct {
+		name     string
+		emojis   int
+		err      error
+		limit    bool
+		response string
+	}{
+		{"no emojis", 0, nil, false, "noCustomEmojis"},
+		{"failed", 2, errors.New("the model fell over"), false, "altTextError"},
+		{"rate limited", 2, nil, true, "rateLimitExceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+			c := newFakeClient()
+			if tt.limit {
+				useRateLimit(t, 1)
+				c = clientWithUser("asker")
+			}
+			useProvider(t, &fakeProvider{response: "A blob.", err: tt.err})
+
+			emojiRequest(c, emojiPost(t, mastodon.ID("fallback-"+tt.name), tt.emojis))
+
+			want := getLocalizedString("en", tt.response, "response")
+			if posted := c.postedToots(); len(posted) != 1 || !strings.HasPrefix(posted[0].Status, "@asker "+want) {
+				t.Errorf("posted %+v, want %q", posted, want)
+			}
+		})
+	}
+}
+
+func TestEmojiCommand(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.ImageProcessing.DescribeEmojis = true
+		c.RateLimit.Enabled = false
+	})
+	provider := &fakeProvider{response: "A blob."}
+	useProvider(t, provider)
+	status := emojiPost(t, "emoji-command-post", 1)
+	status.MediaAttachments = []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}}
+	mention := &mastodon.Status{ID: "emoji-command", Account: mastodon.Account{ID: "asker", Acct: "asker"}, InReplyToID: status.ID, Content: "<p>@altbot emoji</p>", Language: "en"}
+	c := newFakeClient(status, mention)
+	forgetReplies(t, c, status.ID)
+
+	handleMention(c, &mastodon.Notification{Type: "mention", Account: mention.Account, Status: mention})
+
+	posted := c.postedToots()
+	if len(posted) != 1 || !strings.Contains(posted[0].Status, ":blob0: A blob.") {
+		t.Errorf("posted %+v, want the emoji described instead of the image", posted)
+	}
+}
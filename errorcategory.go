@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrMediaTooLarge is returned when media exceeds a size limit, e.g. of the download or the PDF pages
+var ErrMediaTooLarge = errors.New("media exceeds the size limit")
+
+// ErrUnsupportedFormat is returned when the media can't be decoded or the provider doesn't accept its format
+var ErrUnsupportedFormat = errors.New("unsupported media format")
+
+// ErrMediaUnavailable is returned when the media server answered a download with an error
+var ErrMediaUnavailable = errors.New("media could not be downloaded")
+
+// ErrorCategory classifies why a generation failed. It picks the message the requester gets
+// and breaks down the failed generations in the metrics.
+type ErrorCategory string
+
+const (
+	ErrorNetwork           ErrorCategory = "network"
+	ErrorSizeLimit         ErrorCategory = "size_limit"
+	ErrorUnsupportedFormat ErrorCategory = "unsupported_format"
+	ErrorProviderQuota     ErrorCategory = "provider_quota"
+	ErrorProviderBlocked   ErrorCategory = "provider_blocked"
+	ErrorTimeout           ErrorCategory = "timeout"
+	ErrorOther             ErrorCategory = "other"
+)
+
+// GenerationError is a failed generation together with its category
+type GenerationError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Category, e.Err)
+}
+
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// categorizeError wraps the error with its category, errors that were categorized before keep theirs
+func categorizeError(err error) *GenerationError {
+	if err == nil {
+		return nil
+	}
+	var genErr *GenerationError
+	if errors.As(err, &genErr) {
+		return genErr
+	}
+	return &GenerationError{Category: errorCategory(err), Err: err}
+}
+
+// errorCategory determines the category from the sentinel errors of the pipeline and the
+// errors of the network and provider libraries
+func errorCategory(err error) ErrorCategory {
+	var genErr *GenerationError
+	if errors.As(err, &genErr) {
+		return genErr.Category
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrAttachmentTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, ErrBudgetExceeded), isQuotaError(err):
+		return ErrorProviderQuota
+	case errors.Is(err, ErrContentBlocked):
+		return ErrorProviderBlocked
+	case errors.Is(err, ErrMediaTooLarge), errors.Is(err, ErrTempStorageFull):
+		return ErrorSizeLimit
+	case errors.Is(err, ErrUnsupportedFormat), errors.Is(err, ErrUnsupportedMedia):
+		return ErrorUnsupportedFormat
+	case errors.Is(err, ErrMediaUnavailable), errors.As(err, &netErr):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// isQuotaError reports whether Gemini rejected the request because the quota of the API key is used up
+func isQuotaError(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.ResourceExhausted
+}
+
+// messageKey returns the localization key of the reply for the failed generation of the given media type
+func (e *GenerationError) messageKey(mediaType string) string {
+	switch e.Category {
+	case ErrorNetwork:
+		return "mediaUnavailable"
+	case ErrorSizeLimit:
+		// A full temporary storage is the bot's limit, not the media's
+		if errors.Is(e.Err, ErrTempStorageFull) {
+			return "tempStorageFull"
+		}
+		return "mediaTooLarge"
+	case ErrorUnsupportedFormat:
+		return "unsupportedFormat"
+	case ErrorProviderQuota:
+		return "temporarilyUnavailable"
+	case ErrorProviderBlocked:
+		return "contentBlocked"
+	case ErrorTimeout:
+		return "attachmentTimeout"
+	}
+	return altTextErrorKey(mediaType)
+}
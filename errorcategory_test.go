@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCategoriesPickMessageAndMetric(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		category ErrorCategory
+		message  string
+	}{
+		{"attachment timeout", ErrAttachmentTimeout, ErrorTimeout, "attachmentTimeout"},
+		{"deadline", fmt.Errorf("download: %w", context.DeadlineExceeded), ErrorTimeout, "attachmentTimeout"},
+		{"budget", ErrBudgetExceeded, ErrorProviderQuota, "temporarilyUnavailable"},
+		{"quota", status.Error(codes.ResourceExhausted, "quota"), ErrorProviderQuota, "temporarilyUnavailable"},
+		{"blocked", fmt.Errorf("%w: safety", ErrContentBlocked), ErrorProviderBlocked, "contentBlocked"},
+		{"too large", ErrMediaTooLarge, ErrorSizeLimit, "mediaTooLarge"},
+		{"temp storage full", ErrTempStorageFull, ErrorSizeLimit, "tempStorageFull"},
+		{"unsupported format", ErrUnsupportedFormat, ErrorUnsupportedFormat, "unsupportedFormat"},
+		{"unsupported media", ErrUnsupportedMedia, ErrorUnsupportedFormat, "unsupportedFormat"},
+		{"unavailable", ErrMediaUnavailable, ErrorNetwork, "mediaUnavailable"},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorNetwork, "mediaUnavailable"},
+		{"other", errors.New("something else"), ErrorOther, "altTextError"},
+		{"categorized before", &GenerationError{Category: ErrorTimeout, Err: errors.New("slow")}, ErrorTimeout, "attachmentTimeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genErr := categorizeError(tt.err)
+			if genErr.Category != tt.category {
+				t.Errorf("category = %s, want %s", genErr.Category, tt.category)
+			}
+			if key := genErr.messageKey("image"); key != tt.message {
+				t.Errorf("messageKey = %s, want %s", key, tt.message)
+			}
+			if !errors.Is(genErr, tt.err) {
+				t.Errorf("the categorized error doesn't wrap %v", tt.err)
+			}
+			for lang := range localizations {
+				if getLocalizedString(lang, tt.message, "response") == "" {
+					t.Errorf("%s has no %s message", lang, tt.message)
+				}
+			}
+		})
+	}
+}
+
+func TestOtherErrorsUseTheMediaTypesMessage(t *testing.T) {
+	genErr := categorizeError(errors.New("something else"))
+	for mediaType, want := range map[string]string{"image": "altTextError", "video": "videoAltTextError", "gifv": "videoAltTextError", "audio": "audioAltTextError"} {
+		if key := genErr.messageKey(mediaType); key != want {
+			t.Errorf("messageKey(%s) = %s, want %s", mediaType, key, want)
+		}
+	}
+}
+
+func TestFailedGenerationMetricCarriesTheCategory(t *testing.T) {
+	mm := &MetricsManager{enabled: true}
+	mm.logFailedGeneration("user", "image", categorizeError(ErrMediaTooLarge).Category)
+
+	if len(mm.logs) != 1 || mm.logs[0].EventType != "failed_generation" || mm.logs[0].Details["category"] != "size_limit" {
+		t.Errorf("logs = %+v, want one failed generation of category size_limit", mm.logs)
+	}
+}
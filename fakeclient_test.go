@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// TestMain sets up the globals the handlers expect from main
+func TestMain(m *testing.M) {
+	ctx = context.Background()
+	config.Localization.DefaultLanguage = "en"
+	if err := loadLocalizations(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading localizations: %v\n", err)
+		os.Exit(1)
+	}
+	metricsManager = NewMetricsManager(false, "", time.Hour)
+	rateLimiter = NewRateLimiter()
+
+	// The handlers save their state next to the binary, which is a scratch directory during the tests
+	dir, err := os.MkdirTemp("", "altbot-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating the test directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error changing to the test directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeClient is an in-memory MastodonClient. It serves the statuses, accounts and notifications
+// it was given and records everything the bot posts, follows, deletes and uploads.
+type fakeClient struct {
+	mu sync.Mutex
+
+	statuses      map[mastodon.ID]*mastodon.Status
+	statusErrs    map[mastodon.ID]error // returned instead of the status, e.g. to simulate an outage
+	contexts      map[mastodon.ID]*mastodon.Context
+	accounts      map[mastodon.ID]*mastodon.Account
+	relationships map[mastodon.ID]*mastodon.Relationship
+	relationErr   error // returned by GetAccountRelationships
+	notifications []*mastodon.Notification
+	timeline      []*mastodon.Status
+	currentUser   *mastodon.Account
+	postErr       error
+	postAttempts  int
+
+	posted     []*mastodon.Toot
+	followed   []mastodon.ID
+	unfollowed []mastodon.ID
+	deleted    []mastodon.ID
+	uploaded   []*mastodon.Media
+	fetched    []mastodon.ID
+	nextID     int
+}
+
+var _ MastodonClient = (*fakeClient)(nil)
+
+func newFakeClient(statuses ...*mastodon.Status) *fakeClient {
+	f := &fakeClient{
+		statuses:      make(map[mastodon.ID]*mastodon.Status),
+		statusErrs:    make(map[mastodon.ID]error),
+		contexts:      make(map[mastodon.ID]*mastodon.Context),
+		accounts:      make(map[mastodon.ID]*mastodon.Account),
+		relationships: make(map[mastodon.ID]*mastodon.Relationship),
+		currentUser:   &mastodon.Account{ID: "bot", Username: "altbot", Acct: "altbot"},
+	}
+	for _, status := range statuses {
+		f.statuses[status.ID] = status
+	}
+	return f
+}
+
+// notFound is the error Mastodon answers unknown IDs with
+func notFound() error {
+	return &mastodon.APIError{StatusCode: 404}
+}
+
+func (f *fakeClient) GetStatus(_ context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetched = append(f.fetched, id)
+	if err := f.statusErrs[id]; err != nil {
+		return nil, err
+	}
+	status, ok := f.statuses[id]
+	if !ok {
+		return nil, notFound()
+	}
+	return status, nil
+}
+
+func (f *fakeClient) GetStatusContext(_ context.Context, id mastodon.ID) (*mastodon.Context, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if statusContext, ok := f.contexts[id]; ok {
+		return statusContext, nil
+	}
+	return &mastodon.Context{}, nil
+}
+
+func (f *fakeClient) PostStatus(_ context.Context, toot *mastodon.Toot) (*mastodon.Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.postAttempts++
+	if f.postErr != nil {
+		return nil, f.postErr
+	}
+	f.posted = append(f.posted, toot)
+	f.nextID++
+	id := mastodon.ID(fmt.Sprintf("posted-%d", f.nextID))
+	status := &mastodon.Status{
+		ID:          id,
+		URL:         "https://example.social/@altbot/" + string(id),
+		Content:     toot.Status,
+		InReplyToID: toot.InReplyToID,
+		Visibility:  toot.Visibility,
+		Account:     *f.currentUser,
+	}
+	f.statuses[id] = status
+	return status, nil
+}
+
+func (f *fakeClient) DeleteStatus(_ context.Context, id mastodon.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, id)
+	delete(f.statuses, id)
+	return nil
+}
+
+func (f *fakeClient) UploadMediaFromMedia(_ context.Context, media *mastodon.Media) (*mastodon.Attachment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploaded = append(f.uploaded, media)
+	return &mastodon.Attachment{ID: mastodon.ID(fmt.Sprintf("media-%d", len(f.uploaded))), Type: "image", Description: media.Description}, nil
+}
+
+func (f *fakeClient) AccountFollow(_ context.Context, id mastodon.ID) (*mastodon.Relationship, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.followed = append(f.followed, id)
+	return &mastodon.Relationship{ID: id, Following: true}, nil
+}
+
+func (f *fakeClient) AccountUnfollow(_ context.Context, id mastodon.ID) (*mastodon.Relationship, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unfollowed = append(f.unfollowed, id)
+	return &mastodon.Relationship{ID: id}, nil
+}
+
+func (f *fakeClient) GetAccountRelationships(_ context.Context, ids []string) ([]*mastodon.Relationship, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.relationErr != nil {
+		return nil, f.relationErr
+	}
+	var relationships []*mastodon.Relationship
+	for _, id := range ids {
+		if relationship, ok := f.relationships[mastodon.ID(id)]; ok {
+			relationships = append(relationships, relationship)
+		} else {
+			relationships = append(relationships, &mastodon.Relationship{ID: mastodon.ID(id)})
+		}
+	}
+	return relationships, nil
+}
+
+func (f *fakeClient) GetAccount(_ context.Context, id mastodon.ID) (*mastodon.Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	account, ok := f.accounts[id]
+	if !ok {
+		return nil, notFound()
+	}
+	return account, nil
+}
+
+func (f *fakeClient) GetAccountCurrentUser(_ context.Context) (*mastodon.Account, error) {
+	return f.currentUser, nil
+}
+
+// GetNotifications returns the notifications newer than pg.MinID, newest first like Mastodon
+func (f *fakeClient) GetNotifications(_ context.Context, pg *mastodon.Pagination) ([]*mastodon.Notification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var notifications []*mastodon.Notification
+	for _, notification := range f.notifications {
+		if pg == nil || pg.MinID == "" || isNewerID(notification.ID, pg.MinID) {
+			notifications = append(notifications, notification)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return isNewerID(notifications[i].ID, notifications[j].ID)
+	})
+
+	if pg != nil && pg.Limit > 0 && int64(len(notifications)) > pg.Limit {
+		// min_id pages start right after the cursor, so the oldest ones are kept
+		if pg.MinID != "" {
+			notifications = notifications[int64(len(notifications))-pg.Limit:]
+		} else {
+			notifications = notifications[:pg.Limit]
+		}
+	}
+	return notifications, nil
+}
+
+func (f *fakeClient) GetTimelineHome(_ context.Context, _ *mastodon.Pagination) ([]*mastodon.Status, error) {
+	return f.timeline, nil
+}
+
+func (f *fakeClient) GetTimelinePublic(_ context.Context, _ bool, _ *mastodon.Pagination) ([]*mastodon.Status, error) {
+	return f.timeline, nil
+}
+
+func (f *fakeClient) GetTimelineHashtag(_ context.Context, _ string, _ bool, _ *mastodon.Pagination) ([]*mastodon.Status, error) {
+	return f.timeline, nil
+}
+
+// postedToots returns a copy of what the bot posted so far
+func (f *fakeClient) postedToots() []*mastodon.Toot {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*mastodon.Toot(nil), f.posted...)
+}
+
+// withConfig runs the test with a modified copy of the config, restoring it afterwards
+func withConfig(t *testing.T, modify func(c *Config)) {
+	t.Helper()
+	saved := config
+	t.Cleanup(func() { config = saved })
+	modify(&config)
+}
+
+// forgetReplies removes the bot's replies to the statuses from the reply map once the test is done
+func forgetReplies(t *testing.T, c MastodonClient, ids ...mastodon.ID) {
+	t.Helper()
+	t.Cleanup(func() {
+		mapMutex.Lock()
+		defer mapMutex.Unlock()
+		for _, id := range ids {
+			delete(replyMap, accountKey(c, id))
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an in-memory Provider. It answers every call with the configured response
+// or error, after any queued first answers, and records the prompts and images it was sent.
+type fakeProvider struct {
+	mu sync.Mutex
+
+	name     string
+	media    map[string]bool // supported media types, nil for all
+	formats  map[string]bool // accepted image formats, nil for JPEG and PNG
+	response string
+	err      error
+	delay    time.Duration // how long every call takes
+	first    []string      // answered one per call before response and err
+
+	prompts     []string
+	images      [][]byte
+	formatsSent []string
+}
+
+var _ Provider = (*fakeProvider)(nil)
+
+func (p *fakeProvider) Name() string {
+	if p.name == "" {
+		return "fake"
+	}
+	return p.name
+}
+
+func (p *fakeProvider) SupportsMedia(mediaType string) bool {
+	return p.media == nil || p.media[mediaType]
+}
+
+func (p *fakeProvider) PreferredImageFormat() string { return "" }
+
+func (p *fakeProvider) AcceptsImageFormat(format string) bool {
+	if p.formats == nil {
+		return format == "jpeg" || format == "png"
+	}
+	return p.formats[format]
+}
+
+func (p *fakeProvider) DescribeImage(prompt string, image []byte, format string) (string, error) {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, prompt)
+	p.images = append(p.images, image)
+	p.formatsSent = append(p.formatsSent, format)
+	return p.answer()
+}
+
+func (p *fakeProvider) describeFile(prompt string) (string, error) {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, prompt)
+	return p.answer()
+}
+
+// answer returns the next queued answer, or the configured response once they are used up
+func (p *fakeProvider) answer() (string, error) {
+	if len(p.first) > 0 {
+		response := p.first[0]
+		p.first = p.first[1:]
+		return response, nil
+	}
+	return p.response, p.err
+}
+
+func (p *fakeProvider) DescribeVideo(prompt string, videoFilePath string) (string, error) {
+	return p.describeFile(prompt)
+}
+
+func (p *fakeProvider) DescribeAudio(prompt string, audioFilePath string) (string, error) {
+	return p.describeFile(prompt)
+}
+
+func (p *fakeProvider) DescribeDocument(prompt string, documentFilePath string) (string, error) {
+	return p.describeFile(prompt)
+}
+
+// calls returns how many requests the provider received
+func (p *fakeProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.prompts)
+}
+
+// useProvider makes the provider the configured one for the duration of the test
+func useProvider(t *testing.T, provider Provider) {
+	t.Helper()
+	saved, savedMedia := llmProvider, mediaProviders
+	t.Cleanup(func() { llmProvider, mediaProviders = saved, savedMedia })
+	llmProvider = provider
+	mediaProviders = make(map[string]Provider)
+}
+
+// testImage returns a PNG of the given size, filled with a single color
+func testImage(t *testing.T, width, height int, fill color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// dataURI embeds the data as a base64 data URI, which fetchMedia decodes without a request
+func dataURI(mediaType string, data []byte) string {
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
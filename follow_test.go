@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestShouldFollowBack(t *testing.T) {
+	yearOld := time.Now().AddDate(-1, 0, 0)
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		account mastodon.Account
+		want    bool
+	}{
+		{"anyone", func(c *Config) {}, mastodon.Account{ID: "a", CreatedAt: yearOld}, true},
+		{"bot allowed", func(c *Config) {}, mastodon.Account{ID: "a", Bot: true, CreatedAt: yearOld}, true},
+		{"bot skipped", func(c *Config) { c.Behavior.FollowBackSkipBots = true }, mastodon.Account{ID: "a", Bot: true, CreatedAt: yearOld}, false},
+		{"human with bots skipped", func(c *Config) { c.Behavior.FollowBackSkipBots = true }, mastodon.Account{ID: "a", CreatedAt: yearOld}, true},
+		{"dni allowed", func(c *Config) {}, mastodon.Account{ID: "a", Note: "#nobot", CreatedAt: yearOld}, true},
+		{"dni skipped", func(c *Config) { c.Behavior.FollowBackSkipDNI = true }, mastodon.Account{ID: "a", Note: "<p>#nobot</p>", CreatedAt: yearOld}, false},
+		{"new account", func(c *Config) { c.Behavior.FollowBackMinAccountAgeDays = 7 }, mastodon.Account{ID: "a", CreatedAt: time.Now().AddDate(0, 0, -2)}, false},
+		{"old enough", func(c *Config) { c.Behavior.FollowBackMinAccountAgeDays = 7 }, mastodon.Account{ID: "a", CreatedAt: time.Now().AddDate(0, 0, -8)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.DNI.Tags = []string{"#nobot"}
+				tt.config(c)
+			})
+			if got := shouldFollowBack(newFakeClient(), &tt.account); got != tt.want {
+				t.Errorf("shouldFollowBack = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowersFailingTheCriteriaAreNotFollowed(t *testing.T) {
+	useFollowBackLimit(t, 0)
+	withConfig(t, func(c *Config) { c.Behavior.FollowBackSkipBots = true })
+	c := newFakeClient()
+
+	handleFollow(c, &mastodon.Notification{Type: "follow", Account: mastodon.Account{ID: "robot", Acct: "robot", Bot: true}})
+	newFollower(c, "person")
+
+	if len(c.followed) != 1 || c.followed[0] != "person" {
+		t.Errorf("followed %v, want only the person", c.followed)
+	}
+}
+
+// forgetWelcomes lets the accounts be welcomed again by later tests
+func forgetWelcomes(t *testing.T, c MastodonClient, ids ...mastodon.ID) {
+	t.Cleanup(func() {
+		welcomedMutex.Lock()
+		defer welcomedMutex.Unlock()
+		for _, id := range ids {
+			delete(welcomedAccounts, accountKey(c, id))
+		}
+	})
+}
+
+func TestWelcomeMessageIsSentOnce(t *testing.T) {
+	useFollowBackLimit(t, 0)
+	withConfig(t, func(c *Config) {
+		c.Behavior.WelcomeMessage = true
+		c.DNI.Tags = []string{"#nobot"}
+	})
+	c := newFakeClient()
+	forgetWelcomes(t, c, "returning", "optout")
+
+	// Following again after an unfollow doesn't repeat the welcome
+	newFollower(c, "returning")
+	newFollower(c, "returning")
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sendWelcomeMessage(c, c.accounts["returning"])
+		}()
+	}
+	wg.Wait()
+
+	handleFollow(c, &mastodon.Notification{Type: "follow", Account: mastodon.Account{ID: "optout", Acct: "optout", Note: "#nobot"}})
+
+	posted := c.postedToots()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d welcome messages, want 1", len(posted))
+	}
+	if posted[0].Visibility != "direct" || !strings.HasPrefix(posted[0].Status, "@returning ") || !strings.Contains(posted[0].Status, "#nobot") {
+		t.Errorf("welcome message = %+v, want a direct message mentioning the opt-out tag", posted[0])
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// FollowBackLimit counts the follow-backs of the current day per bot account, so instances don't
+// take a burst of new follows for spam. Followers beyond the daily limit wait in a queue.
+type FollowBackLimit struct {
+	Day      string                   `json:"day"`
+	Counts   map[string]int           `json:"counts"`
+	Queued   map[string][]mastodon.ID `json:"queued"`
+	filePath string
+	mu       sync.Mutex
+}
+
+var followBackLimit = FollowBackLimit{
+	Counts: make(map[string]int),
+	Queued: make(map[string][]mastodon.ID),
+}
+
+// rollOver resets the counts when a new day has started, at local midnight
+func (f *FollowBackLimit) rollOver(now time.Time) {
+	if day := now.Format("2006-01-02"); f.Day != day {
+		f.Day = day
+		f.Counts = make(map[string]int)
+	}
+}
+
+// Take counts a follow-back of the bot account, reporting false if today's limit is used up
+func (f *FollowBackLimit) Take(c MastodonClient) bool {
+	limit := config.Behavior.MaxFollowBacksPerDay
+	if limit <= 0 {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rollOver(time.Now())
+	account := accountUsername(c)
+	if f.Counts[account] >= limit {
+		return false
+	}
+	f.Counts[account]++
+	if err := f.saveToFile(); err != nil {
+		log.Printf("Error saving follow-back counts: %v", err)
+	}
+	return true
+}
+
+// TakeOrQueue counts a follow-back of the new follower, or queues the follower when today's limit is
+// used up or others are still waiting, so nobody jumps the queue once the limit resets
+func (f *FollowBackLimit) TakeOrQueue(c MastodonClient, accountID mastodon.ID) bool {
+	limit := config.Behavior.MaxFollowBacksPerDay
+	if limit <= 0 {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rollOver(time.Now())
+	account := accountUsername(c)
+	taken := len(f.Queued[account]) == 0 && f.Counts[account] < limit
+	if taken {
+		f.Counts[account]++
+	} else if slices.Contains(f.Queued[account], accountID) {
+		return false
+	} else {
+		f.Queued[account] = append(f.Queued[account], accountID)
+	}
+	if err := f.saveToFile(); err != nil {
+		log.Printf("Error saving follow-back limit: %v", err)
+	}
+	return taken
+}
+
+// first returns the longest waiting follower of the bot account, if any
+func (f *FollowBackLimit) first(c MastodonClient) (mastodon.ID, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	queued := f.Queued[accountUsername(c)]
+	if len(queued) == 0 {
+		return "", false
+	}
+	return queued[0], true
+}
+
+// Remove takes the follower out of the bot account's queue
+func (f *FollowBackLimit) Remove(c MastodonClient, accountID mastodon.ID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	account := accountUsername(c)
+	f.Queued[account] = slices.DeleteFunc(f.Queued[account], func(id mastodon.ID) bool { return id == accountID })
+	if err := f.saveToFile(); err != nil {
+		log.Printf("Error saving follow-back queue: %v", err)
+	}
+}
+
+// LoadFromFile loads the counts and queue persisted by a previous run
+func (f *FollowBackLimit) LoadFromFile(filePath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.filePath = filePath
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist. Start fresh.
+		}
+		return err
+	}
+	return json.Unmarshal(data, f)
+}
+
+func (f *FollowBackLimit) saveToFile() error {
+	if f.filePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.filePath, data, 0644)
+}
+
+// followQueuedFollowers follows back the queued followers, oldest first, as far as today's limit allows
+func followQueuedFollowers(c MastodonClient) {
+	for {
+		accountID, ok := followBackLimit.first(c)
+		if !ok {
+			return
+		}
+
+		account, err := c.GetAccount(ctx, accountID)
+		if err != nil {
+			log.Printf("Error fetching queued follower %s: %v", accountID, err)
+			// Deleted or suspended accounts are dropped, other errors are retried later
+			if isStatusGone(err) {
+				followBackLimit.Remove(c, accountID)
+				continue
+			}
+			return
+		}
+
+		// Whoever stopped following in the meantime isn't followed back anymore
+		if isStillFollower(c, account) {
+			if !followBackLimit.Take(c) {
+				return
+			}
+			followBack(c, account)
+		}
+		followBackLimit.Remove(c, accountID)
+	}
+}
+
+// startFollowBackQueue works off the queue of the bot account whenever the daily limit has reset
+func startFollowBackQueue(c MastodonClient) {
+	if !config.Behavior.FollowBack || config.Behavior.MaxFollowBacksPerDay <= 0 {
+		return
+	}
+	for {
+		followQueuedFollowers(c)
+		time.Sleep(10 * time.Minute)
+	}
+}
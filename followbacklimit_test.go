@@ -0,0 +1,100 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useFollowBackLimit allows limit follow-backs a day, starting with no counts and an empty queue
+func useFollowBackLimit(t *testing.T, limit int) {
+	t.Helper()
+	withConfig(t, func(c *Config) {
+		c.Behavior.FollowBack = true
+		c.Behavior.MaxFollowBacksPerDay = limit
+		c.Behavior.WelcomeMessage = false
+	})
+	reset := func() {
+		followBackLimit.mu.Lock()
+		defer followBackLimit.mu.Unlock()
+		followBackLimit.Day = ""
+		followBackLimit.Counts = make(map[string]int)
+		followBackLimit.Queued = make(map[string][]mastodon.ID)
+		followBackLimit.filePath = ""
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// newFollower sends a follow notification of the account to the client
+func newFollower(c *fakeClient, id mastodon.ID) {
+	account := &mastodon.Account{ID: id, Acct: string(id), CreatedAt: time.Now().AddDate(-1, 0, 0)}
+	c.accounts[id] = account
+	handleFollow(c, &mastodon.Notification{Type: "follow", Account: *account})
+}
+
+// nextDay makes the follow-back limit start a new day
+func nextDay() {
+	followBackLimit.mu.Lock()
+	defer followBackLimit.mu.Unlock()
+	followBackLimit.Day = time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+func TestFollowBackLimitQueuesFollowersBeyondTheCap(t *testing.T) {
+	useFollowBackLimit(t, 2)
+	c := newFakeClient()
+
+	for _, id := range []mastodon.ID{"first", "second", "third", "fourth"} {
+		newFollower(c, id)
+	}
+
+	if !slices.Equal(c.followed, []mastodon.ID{"first", "second"}) {
+		t.Errorf("followed %v, want first and second", c.followed)
+	}
+	if queued := followBackLimit.Queued[accountUsername(c)]; !slices.Equal(queued, []mastodon.ID{"third", "fourth"}) {
+		t.Errorf("queued %v, want third and fourth", queued)
+	}
+}
+
+func TestFollowBackQueueComesFirstAfterReset(t *testing.T) {
+	useFollowBackLimit(t, 1)
+	c := newFakeClient()
+	newFollower(c, "first")
+	newFollower(c, "waiting")
+
+	// After midnight the limit is free again, but the waiting follower is next in line
+	nextDay()
+	newFollower(c, "new")
+	if !slices.Equal(c.followed, []mastodon.ID{"first"}) {
+		t.Fatalf("followed %v, the new follower jumped the queue", c.followed)
+	}
+
+	followQueuedFollowers(c)
+	if !slices.Equal(c.followed, []mastodon.ID{"first", "waiting"}) {
+		t.Errorf("followed %v, want the waiting follower next", c.followed)
+	}
+
+	nextDay()
+	followQueuedFollowers(c)
+	if !slices.Equal(c.followed, []mastodon.ID{"first", "waiting", "new"}) {
+		t.Errorf("followed %v, want the new follower the day after", c.followed)
+	}
+	if queued := followBackLimit.Queued[accountUsername(c)]; len(queued) != 0 {
+		t.Errorf("still queued %v", queued)
+	}
+}
+
+func TestFollowBackWithoutLimitFollowsEveryone(t *testing.T) {
+	useFollowBackLimit(t, 0)
+	c := newFakeClient()
+
+	for _, id := range []mastodon.ID{"first", "second", "third"} {
+		newFollower(c, id)
+	}
+
+	if len(c.followed) != 3 {
+		t.Errorf("followed %v, want everyone", c.followed)
+	}
+}
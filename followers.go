@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// followerCheckInterval is how long a known follow relationship is trusted before checking again
+const followerCheckInterval = time.Hour
+
+type followerState struct {
+	FollowedBy bool
+	CheckedAt  time.Time
+}
+
+// followerCache remembers whether accounts still follow the bot, so updates don't cost an API call each.
+// Every bot account has its own followers, so the cache is keyed by accountKey.
+var followerCache = make(map[string]followerState)
+var followerCacheMutex sync.Mutex
+
+// isStillFollower reports whether the account still follows the bot. Accounts that unfollowed
+// (or blocked, which removes the follow) are optionally unfollowed back.
+func isStillFollower(c MastodonClient, account *mastodon.Account) bool {
+	followerCacheMutex.Lock()
+	key := accountKey(c, account.ID)
+	state, ok := followerCache[key]
+	followerCacheMutex.Unlock()
+
+	if ok && time.Since(state.CheckedAt) < followerCheckInterval {
+		return state.FollowedBy
+	}
+
+	relationships, err := c.GetAccountRelationships(ctx, []string{string(account.ID)})
+	if err != nil || len(relationships) == 0 {
+		log.Printf("Error fetching relationship with %s: %v", account.Acct, err)
+		// Don't stop describing because of a transient error
+		return true
+	}
+	followedBy := relationships[0].FollowedBy
+
+	followerCacheMutex.Lock()
+	followerCache[key] = followerState{FollowedBy: followedBy, CheckedAt: time.Now()}
+	followerCacheMutex.Unlock()
+
+	if !followedBy && relationships[0].Following && config.Behavior.UnfollowWhenUnfollowed {
+		if _, err := c.AccountUnfollow(ctx, account.ID); err != nil {
+			log.Printf("Error unfollowing %s: %v", account.Acct, err)
+		} else {
+			fmt.Printf("Unfollowed: %s\n", account.Acct)
+		}
+	}
+
+	return followedBy
+}
+
+// markFollower records a new follower so their posts are described without another lookup
+func markFollower(c MastodonClient, accountID mastodon.ID) {
+	followerCacheMutex.Lock()
+	followerCache[accountKey(c, accountID)] = followerState{FollowedBy: true, CheckedAt: time.Now()}
+	followerCacheMutex.Unlock()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// forgetFollowers removes the accounts from the follower cache when the test ends
+func forgetFollowers(t *testing.T, c MastodonClient, ids ...mastodon.ID) {
+	t.Cleanup(func() {
+		followerCacheMutex.Lock()
+		defer followerCacheMutex.Unlock()
+		for _, id := range ids {
+			delete(followerCache, accountKey(c, id))
+		}
+	})
+}
+
+// expireFollowerCheck makes the cached relationship old enough to be checked again
+func expireFollowerCheck(c MastodonClient, id mastodon.ID) {
+	followerCacheMutex.Lock()
+	defer followerCacheMutex.Unlock()
+	state := followerCache[accountKey(c, id)]
+	state.CheckedAt = time.Now().Add(-2 * followerCheckInterval)
+	followerCache[accountKey(c, id)] = state
+}
+
+func TestFollowerStateTransitions(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Behavior.UnfollowWhenUnfollowed = true })
+	c := newFakeClient()
+	account := &mastodon.Account{ID: "fickle", Acct: "fickle"}
+	forgetFollowers(t, c, account.ID)
+
+	// A new follower is trusted without a lookup
+	c.relationErr = errors.New("no lookups expected")
+	markFollower(c, account.ID)
+	if !isStillFollower(c, account) {
+		t.Fatal("a new follower isn't a follower")
+	}
+
+	// Unfollowing is only noticed once the cached state is old, and is answered with an unfollow
+	c.relationErr = nil
+	c.relationships[account.ID] = &mastodon.Relationship{ID: account.ID, Following: true}
+	if !isStillFollower(c, account) {
+		t.Error("the cached state was checked again before it expired")
+	}
+	expireFollowerCheck(c, account.ID)
+	if isStillFollower(c, account) {
+		t.Error("an account that unfollowed is still a follower")
+	}
+	if len(c.unfollowed) != 1 || c.unfollowed[0] != account.ID {
+		t.Errorf("unfollowed %v, want the account unfollowed back", c.unfollowed)
+	}
+
+	// Following again is picked up from the notification
+	markFollower(c, account.ID)
+	if !isStillFollower(c, account) {
+		t.Error("an account that followed again isn't a follower")
+	}
+}
+
+func TestUnfollowBackIsOptional(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Behavior.UnfollowWhenUnfollowed = false })
+	c := newFakeClient()
+	account := &mastodon.Account{ID: "gone", Acct: "gone"}
+	forgetFollowers(t, c, account.ID)
+	c.relationships[account.ID] = &mastodon.Relationship{ID: account.ID, Following: true}
+
+	if isStillFollower(c, account) {
+		t.Error("an account that unfollowed is still a follower")
+	}
+	if len(c.unfollowed) != 0 {
+		t.Errorf("unfollowed %v with unfollow_when_unfollowed off", c.unfollowed)
+	}
+}
+
+func TestFollowerLookupErrorsKeepDescribing(t *testing.T) {
+	c := newFakeClient()
+	account := &mastodon.Account{ID: "unknown", Acct: "unknown"}
+	forgetFollowers(t, c, account.ID)
+	c.relationErr = errors.New("503 Service Unavailable")
+
+	if !isStillFollower(c, account) {
+		t.Error("a failed lookup stopped describing the account's posts")
+	}
+
+	// The error isn't cached, so the next update looks again
+	c.relationErr = nil
+	if isStillFollower(c, account) {
+		t.Error("the account that doesn't follow was cached as a follower after the failed lookup")
+	}
+}
@@ -1,14 +1,16 @@
 module AltBot
 
-go 1.21.11
+go 1.22.2
 
 require (
 	github.com/BurntSushi/toml v0.3.1
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/google/generative-ai-go v0.18.0
 	github.com/mattn/go-mastodon v0.0.8
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	golang.org/x/image v0.20.0
 	google.golang.org/api v0.198.0
+	google.golang.org/grpc v1.66.2
 )
 
 require (
@@ -43,6 +45,5 @@ require (
 	golang.org/x/time v0.6.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240725223205-93522f1f2a9f // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
-	google.golang.org/grpc v1.66.2 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 )
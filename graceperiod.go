@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// pendingAutoDescribe holds the posts waiting for their grace period to end, by accountKey
+var pendingAutoDescribe = make(map[string]bool)
+var pendingAutoDescribeMutex sync.Mutex
+
+// scheduleAutoDescribe describes the post after the delay, giving its author time to add alt-text
+// themselves. The post is fetched again at that point and only described if media still lacks alt-text.
+func scheduleAutoDescribe(c MastodonClient, statusID mastodon.ID, delay time.Duration) {
+	key := accountKey(c, statusID)
+	pendingAutoDescribeMutex.Lock()
+	if pendingAutoDescribe[key] {
+		pendingAutoDescribeMutex.Unlock()
+		return
+	}
+	pendingAutoDescribe[key] = true
+	pendingAutoDescribeMutex.Unlock()
+
+	time.AfterFunc(delay, func() {
+		pendingAutoDescribeMutex.Lock()
+		delete(pendingAutoDescribe, key)
+		pendingAutoDescribeMutex.Unlock()
+
+		if isPaused() {
+			return
+		}
+
+		status, err := fetchStatus(ctx, c, statusID)
+		if err != nil {
+			if isStatusGone(err) {
+				log.Printf("Status %s was deleted during its grace period", statusID)
+				return
+			}
+			log.Printf("Error fetching status %s after its grace period: %v", statusID, err)
+			return
+		}
+
+		if !hasUndescribedMedia(status) {
+			log.Printf("Alt-text was added to status %s during its grace period", statusID)
+			LogEventWithUsername("human_written_alt_text", status.Account.Acct)
+			return
+		}
+
+		if isPostDNI(status) {
+			log.Printf("Status %s opted out with a DNI tag during its grace period", statusID)
+			return
+		}
+
+		if otherReply := findOtherBotReply(c, status); otherReply != nil {
+			log.Printf("Not describing post %s, @%s already answered", statusID, otherReply.Account.Acct)
+			return
+		}
+
+		generateAndPostAltText(c, status, status.ID, nil)
+	})
+}
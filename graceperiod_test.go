@@ -0,0 +1,95 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// waitUntil polls the condition until it holds or a second has passed
+func waitUntil(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the grace period to end")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// fetchedCount returns how often the status was fetched from the fake client
+func fetchedCount(c *fakeClient, id mastodon.ID) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, fetched := range c.fetched {
+		if fetched == id {
+			n++
+		}
+	}
+	return n
+}
+
+func gracePeriodPost(t *testing.T, id mastodon.ID) *mastodon.Status {
+	return &mastodon.Status{
+		ID:               id,
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Language:         "en",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+	}
+}
+
+func TestGracePeriodRespectsAltTextAddedByTheAuthor(t *testing.T) {
+	provider := &fakeProvider{response: "A white square."}
+	useProvider(t, provider)
+	status := gracePeriodPost(t, "graced")
+	c := newFakeClient(status)
+	forgetReplies(t, c, status.ID)
+
+	scheduleAutoDescribe(c, status.ID, 20*time.Millisecond)
+	c.mu.Lock()
+	status.MediaAttachments[0].Description = "My cat, asleep on the sofa."
+	c.mu.Unlock()
+
+	waitUntil(t, func() bool { return fetchedCount(c, status.ID) > 0 })
+	time.Sleep(50 * time.Millisecond)
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Errorf("posted %+v although the author added alt-text", posted)
+	}
+	if n := provider.calls(); n != 0 {
+		t.Errorf("the provider was called %d times, want 0", n)
+	}
+}
+
+func TestGracePeriodDescribesPostsStillMissingAltText(t *testing.T) {
+	useProvider(t, &fakeProvider{response: "A white square."})
+	status := gracePeriodPost(t, "ungraced")
+	c := newFakeClient(status)
+	forgetReplies(t, c, status.ID)
+
+	// Seeing the post again during the grace period doesn't schedule it twice
+	scheduleAutoDescribe(c, status.ID, 20*time.Millisecond)
+	scheduleAutoDescribe(c, status.ID, 20*time.Millisecond)
+
+	waitUntil(t, func() bool { return len(c.postedToots()) > 0 })
+	time.Sleep(50 * time.Millisecond)
+	if posted := c.postedToots(); len(posted) != 1 || posted[0].InReplyToID != status.ID {
+		t.Errorf("posted %+v, want one reply to the post", posted)
+	}
+}
+
+func TestGracePeriodSkipsDeletedPosts(t *testing.T) {
+	status := gracePeriodPost(t, "deleted-in-grace")
+	c := newFakeClient()
+
+	scheduleAutoDescribe(c, status.ID, time.Millisecond)
+
+	waitUntil(t, func() bool { return fetchedCount(c, status.ID) > 0 })
+	time.Sleep(20 * time.Millisecond)
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Errorf("posted %+v for a deleted post", posted)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// providerHealth is the result of the last provider health check
+var providerHealth struct {
+	mu        sync.Mutex
+	checked   bool
+	lastError error
+	checkedAt time.Time
+}
+
+// healthCheckImage returns a tiny checkerboard PNG to send to the provider
+func healthCheckImage() ([]byte, error) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if (x+y)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkProviderHealth sends the test image to every provider currently used for one of the described
+// media types and records whether they answered. Empty or blocked answers still prove that the
+// provider is reachable and the credentials work.
+func checkProviderHealth() error {
+	image, err := healthCheckImage()
+	if err == nil {
+		err = probeProviders(image)
+	}
+
+	providerHealth.mu.Lock()
+	providerHealth.checked = true
+	providerHealth.lastError = err
+	providerHealth.checkedAt = time.Now()
+	providerHealth.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Provider health check failed: %v", err)
+	}
+	return err
+}
+
+// probeProviders describes the image with the active provider of each described media type,
+// asking each provider only once
+func probeProviders(image []byte) error {
+	mediaTypes := []string{"image"}
+	if videoAudioProcessingCapability {
+		mediaTypes = append(mediaTypes, "video", "audio")
+	}
+	if pdfProcessingCapability {
+		mediaTypes = append(mediaTypes, "document")
+	}
+
+	var errs []error
+	probed := make(map[string]bool)
+	for _, mediaType := range mediaTypes {
+		provider, err := activeProvider(mediaType)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mediaType, err))
+			continue
+		}
+		if probed[provider.Name()] {
+			continue
+		}
+		probed[provider.Name()] = true
+
+		_, err = provider.DescribeImage("Describe this test image in one word.", image, "png")
+		if err != nil && !errors.Is(err, ErrEmptyResponse) && !errors.Is(err, ErrContentBlocked) {
+			errs = append(errs, fmt.Errorf("provider %s for %s: %w", provider.Name(), mediaType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startProviderHealthChecks repeats the provider health check at the given interval
+func startProviderHealthChecks(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		checkProviderHealth()
+	}
+}
+
+// handleHealthz reports the result of the last provider health check and when each account's stream
+// delivered its last event, served next to the dashboard. Streams idle past idle_timeout_seconds are unhealthy.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	providerHealth.mu.Lock()
+	checked, lastError, checkedAt := providerHealth.checked, providerHealth.lastError, providerHealth.checkedAt
+	providerHealth.mu.Unlock()
+
+	var problems []string
+	if checked && lastError != nil {
+		problems = append(problems, fmt.Sprintf("unhealthy since %s: %v", checkedAt.Format(time.RFC3339), lastError))
+	}
+	if timeout := config.Server.IdleTimeoutSeconds; timeout > 0 {
+		for _, account := range idleAccounts(time.Duration(timeout) * time.Second) {
+			problems = append(problems, fmt.Sprintf("no streaming events for %s since %s", account, watchdogFor(account).LastEvent().Format(time.RFC3339)))
+		}
+	}
+
+	events := lastEvents()
+	accounts := make([]string, 0, len(events))
+	for account := range events {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+	} else {
+		fmt.Fprintln(w, "ok")
+	}
+	for _, account := range accounts {
+		fmt.Fprintf(w, "last event for %s: %s\n", account, events[account].Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useVideoAudioCapability sets whether videos and audio are described for the duration of the test
+func useVideoAudioCapability(t *testing.T, enabled bool) {
+	t.Helper()
+	saved := videoAudioProcessingCapability
+	videoAudioProcessingCapability = enabled
+	t.Cleanup(func() { videoAudioProcessingCapability = saved })
+}
+
+func TestHealthCheckProbesMediaProviders(t *testing.T) {
+	t.Cleanup(func() {
+		providerHealth.mu.Lock()
+		providerHealth.checked, providerHealth.lastError = false, nil
+		providerHealth.mu.Unlock()
+	})
+	images := &fakeProvider{name: "images", response: "Squares"}
+	videos := &fakeProvider{name: "videos", err: errors.New("invalid API key")}
+	useProvider(t, images)
+	mediaProviders["video"] = videos
+
+	useVideoAudioCapability(t, false)
+	if err := checkProviderHealth(); err != nil {
+		t.Errorf("health check failed with videos disabled: %v", err)
+	}
+	if videos.calls() != 0 {
+		t.Error("probed the video provider with videos disabled")
+	}
+
+	useVideoAudioCapability(t, true)
+	err := checkProviderHealth()
+	if err == nil || !strings.Contains(err.Error(), "videos") {
+		t.Errorf("health check error = %v, want the video provider's", err)
+	}
+	if images.calls() != 2 {
+		t.Errorf("probed the image provider %d times in two checks, want 2", images.calls())
+	}
+
+	recorder := httptest.NewRecorder()
+	handleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz answered %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHealthCheckAcceptsEmptyAnswers(t *testing.T) {
+	useVideoAudioCapability(t, false)
+	useProvider(t, &fakeProvider{err: ErrEmptyResponse})
+
+	if err := probeProviders([]byte("image")); err != nil {
+		t.Errorf("probeProviders = %v for an empty answer", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// commonAspectRatios are named in the image details when an image matches one of them closely
+var commonAspectRatios = []struct {
+	width, height int
+}{
+	{21, 9}, {16, 9}, {3, 2}, {4, 3}, {1, 1}, {3, 4}, {2, 3}, {9, 16},
+}
+
+// aspectRatioName returns the common aspect ratio of the size, like "16:9", or "" if it matches none
+func aspectRatioName(width, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+	ratio := float64(width) / float64(height)
+	for _, common := range commonAspectRatios {
+		if math.Abs(ratio/(float64(common.width)/float64(common.height))-1) < 0.02 {
+			return fmt.Sprintf("%d:%d", common.width, common.height)
+		}
+	}
+	return ""
+}
+
+// orientationKey returns the localization key describing the shape of the size
+func orientationKey(width, height int) string {
+	ratio := float64(width) / float64(height)
+	switch {
+	case ratio >= 1.7:
+		return "shapeWide"
+	case ratio > 1.1:
+		return "shapeLandscape"
+	case ratio >= 0.9:
+		return "shapeSquare"
+	case ratio >= 0.6:
+		return "shapePortrait"
+	default:
+		return "shapeTall"
+	}
+}
+
+// sourceImageSize returns the size of the image as it was posted. Animated WebPs are decoded into a
+// montage of their frames, so their size is read from the canvas in the header instead.
+func sourceImageSize(data []byte, decoded image.Image) (int, int) {
+	if width, height, ok := animatedWebPSize(data); ok {
+		return width, height
+	}
+	bounds := decoded.Bounds()
+	return bounds.Dx(), bounds.Dy()
+}
+
+// describeImageShape returns a localized line with the orientation, aspect ratio and format of an image
+// of the size, e.g. "Image: wide, 16:9, JPEG."
+func describeImageShape(width, height int, format string, lang string) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+
+	details := []string{getLocalizedString(lang, orientationKey(width, height), "response")}
+	if ratio := aspectRatioName(width, height); ratio != "" {
+		details = append(details, ratio)
+	}
+	if format != "" {
+		details = append(details, strings.ToUpper(format))
+	}
+	return fmt.Sprintf(getLocalizedString(lang, "imageShape", "response"), strings.Join(details, ", "))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestImageShapeLabels(t *testing.T) {
+	tests := []struct {
+		width, height int
+		orientation   string
+		ratio         string
+	}{
+		{1920, 1080, "shapeWide", "16:9"},
+		{2560, 1080, "shapeWide", "21:9"},
+		{1500, 1000, "shapeLandscape", "3:2"},
+		{1024, 768, "shapeLandscape", "4:3"},
+		{1000, 1000, "shapeSquare", "1:1"},
+		{1010, 1000, "shapeSquare", "1:1"},
+		{1050, 1000, "shapeSquare", ""},
+		{768, 1024, "shapePortrait", "3:4"},
+		{1000, 1500, "shapePortrait", "2:3"},
+		{1080, 1920, "shapeTall", "9:16"},
+		{500, 2000, "shapeTall", ""},
+		{1234, 777, "shapeLandscape", ""},
+	}
+	for _, tt := range tests {
+		if got := orientationKey(tt.width, tt.height); got != tt.orientation {
+			t.Errorf("orientationKey(%d, %d) = %q, want %q", tt.width, tt.height, got, tt.orientation)
+		}
+		if got := aspectRatioName(tt.width, tt.height); got != tt.ratio {
+			t.Errorf("aspectRatioName(%d, %d) = %q, want %q", tt.width, tt.height, got, tt.ratio)
+		}
+	}
+}
+
+func TestDescribeImageShape(t *testing.T) {
+	if got, want := describeImageShape(1920, 1080, "jpeg", "en"), "Image: wide, 16:9, JPEG."; got != want {
+		t.Errorf("describeImageShape = %q, want %q", got, want)
+	}
+	if got := describeImageShape(0, 10, "png", "en"); got != "" {
+		t.Errorf("describeImageShape of an empty image = %q", got)
+	}
+}
+
+func TestAnimatedWebPShapeIsTheCanvas(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.ImageProcessing.DescribeShape = true
+		c.ImageProcessing.AnimationFrames = 4
+	})
+	useProvider(t, &fakeProvider{response: "A blinking light"})
+
+	// Four 16x9 frames make a square 2x2 montage, but the animation itself is wide
+	colors := []color.Color{color.White, color.Black, color.White, color.Black}
+	data := encodeAnimatedWebP(t, 16, 9, colors)
+	if width, height := sourceImageSize(data, nil); width != 16 || height != 9 {
+		t.Errorf("sourceImageSize = %dx%d, want 16x9", width, height)
+	}
+
+	altText, err := generateImageAltText(dataURI("image/webp", data), "", "en", "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Image: wide, 16:9, WEBP."; !strings.HasPrefix(altText, want) {
+		t.Errorf("alt-text = %q, want it to start with %q", altText, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image"
+)
+
+// imageTypeSamples is roughly how many pixels are looked at to classify an image
+const imageTypeSamples = 10000
+
+// imageTypeHints maps the image types to the prompt hint that is added for them
+var imageTypeHints = map[string]string{
+	"photo":      "imageHintPhoto",
+	"screenshot": "imageHintScreenshot",
+	"chart":      "imageHintChart",
+}
+
+// classifyImage guesses from its colors whether the image is a "photo", "screenshot" or "chart".
+// Photos have many different colors, screenshots and charts are mostly flat areas, charts with
+// very few colors on a large background. It returns "" when the image fits none of them well.
+func classifyImage(img image.Image) string {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return ""
+	}
+
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > imageTypeSamples {
+		step++
+	}
+
+	// Colors are reduced to 5 bits per channel, so compression noise doesn't count as a new color
+	counts := make(map[uint32]int)
+	samples := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			counts[(r>>11)<<10|(g>>11)<<5|b>>11]++
+			samples++
+		}
+	}
+
+	dominant := 0
+	for _, count := range counts {
+		dominant = max(dominant, count)
+	}
+	uniqueShare := float64(len(counts)) / float64(samples)
+	dominantShare := float64(dominant) / float64(samples)
+
+	return imageTypeFor(uniqueShare, dominantShare)
+}
+
+// imageTypeFor decides the image type from the share of distinct colors and of the most common color
+func imageTypeFor(uniqueShare, dominantShare float64) string {
+	switch {
+	case uniqueShare < 0.05 && dominantShare >= 0.4:
+		return "chart"
+	case uniqueShare < 0.4 && dominantShare >= 0.2:
+		return "screenshot"
+	case uniqueShare >= 0.4 && dominantShare < 0.05:
+		return "photo"
+	default:
+		return ""
+	}
+}
+
+// imageTypeHint returns the localized prompt hint for the image type, or "" if there is none
+func imageTypeHint(imageType, lang string) string {
+	key, ok := imageTypeHints[imageType]
+	if !ok {
+		return ""
+	}
+	return getLocalizedString(promptLanguage(lang), key, "prompt")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// noiseImage has random colors everywhere, like the fine detail of a photo
+func noiseImage(width, height int) image.Image {
+	random := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255})
+		}
+	}
+	return img
+}
+
+// barChartImage has three colored bars on a white background
+func barChartImage(width, height int) image.Image {
+	bars := []color.Color{color.RGBA{200, 40, 40, 255}, color.RGBA{40, 200, 40, 255}, color.RGBA{40, 40, 200, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.White)
+			if bar := x * 6 / width; bar%2 == 1 && y > height*(3-bar/2)/5 {
+				img.Set(x, y, bars[bar/2])
+			}
+		}
+	}
+	return img
+}
+
+func TestImageTypeFor(t *testing.T) {
+	tests := []struct {
+		uniqueShare, dominantShare float64
+		imageType                  string
+	}{
+		{0.01, 0.7, "chart"},
+		{0.2, 0.3, "screenshot"},
+		{0.03, 0.3, "screenshot"},
+		{0.9, 0.01, "photo"},
+		{0.3, 0.1, ""},
+		{0.6, 0.3, ""},
+	}
+	for _, tt := range tests {
+		if got := imageTypeFor(tt.uniqueShare, tt.dominantShare); got != tt.imageType {
+			t.Errorf("imageTypeFor(%v, %v) = %q, want %q", tt.uniqueShare, tt.dominantShare, got, tt.imageType)
+		}
+	}
+}
+
+func TestClassifyImage(t *testing.T) {
+	if got := classifyImage(noiseImage(200, 150)); got != "photo" {
+		t.Errorf("classifyImage(noise) = %q, want photo", got)
+	}
+	if got := classifyImage(barChartImage(300, 200)); got != "chart" {
+		t.Errorf("classifyImage(bar chart) = %q, want chart", got)
+	}
+	if got := classifyImage(image.NewRGBA(image.Rect(0, 0, 0, 0))); got != "" {
+		t.Errorf("classifyImage(empty) = %q", got)
+	}
+}
+
+func TestImageTypeHintIsAddedToThePrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    image.Image
+		classify bool
+		hint     string
+	}{
+		{"photo", noiseImage(64, 48), true, imageTypeHint("photo", "en")},
+		{"chart", barChartImage(120, 80), true, imageTypeHint("chart", "en")},
+		{"disabled", noiseImage(64, 48), false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.ImageProcessing.ClassifyImages = tt.classify })
+			provider := &fakeProvider{response: "An image"}
+			useProvider(t, provider)
+			var data bytes.Buffer
+			if err := png.Encode(&data, tt.image); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := generateImageAltText(dataURI("image/png", data.Bytes()), "", "en", "", false, nil); err != nil {
+				t.Fatal(err)
+			}
+			prompt := provider.prompts[0]
+			for imageType := range imageTypeHints {
+				hint := imageTypeHint(imageType, "en")
+				if strings.Contains(prompt, hint) != (hint == tt.hint) {
+					t.Errorf("prompt %q, want only the hint %q", prompt, tt.hint)
+				}
+			}
+		})
+	}
+}
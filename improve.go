@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// improvableAttachments returns the indexes of the selected images that already have alt-text
+func improvableAttachments(status *mastodon.Status, selection []int) []int {
+	var indexes []int
+	for i, attachment := range status.MediaAttachments {
+		if isAttachmentSelected(selection, i) && attachment.Type == "image" && attachment.Description != "" && isMediaTypeAllowed(attachment) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// suggestImprovements replies to an "improve" command with improved versions of the existing image alt-texts.
+// The suggestions are only posted, the alt-texts themselves stay as the author wrote them.
+func suggestImprovements(c MastodonClient, status *mastodon.Status, notification *mastodon.Notification, indexes []int) {
+	lang := accountLanguage(c, notification.Status.Language)
+	metricsManager.logRequest(string(notification.Account.ID))
+
+	var lines []string
+	mediaTypes := make([]string, len(indexes))
+	for i := range mediaTypes {
+		mediaTypes[i] = "image"
+	}
+
+	if !rateLimiter.IncrementAll(c, string(notification.Account.ID), mediaTypes) {
+		log.Printf("User @%s has exceeded their rate limit", notification.Account.Acct)
+		metricsManager.logRateLimitHit(string(notification.Account.ID))
+		if config.RateLimit.ExceededReply == "silent" {
+			return
+		}
+		lines = append(lines, getLocalizedString(lang, "rateLimitExceeded", "response"))
+	} else {
+		for _, index := range indexes {
+			attachment := status.MediaAttachments[index]
+			suggestion, err := withAttachmentTimeout(func() (string, error) {
+				return improveImageAltText(attachment.URL, attachment.RemoteURL, attachment.Description, lang)
+			})
+			if err == nil && suggestion == "" {
+				err = ErrEmptyResponse
+			}
+			if err != nil {
+				genErr := categorizeError(err)
+				log.Printf("Error improving alt-text of %s: %v", loggableURL(attachment.URL), genErr)
+				metricsManager.logFailedGeneration(string(notification.Account.ID), attachment.Type, genErr.Category)
+				continue
+			}
+			lines = append(lines, fmt.Sprintf(getLocalizedString(lang, "improvedAltText", "response"), index+1, suggestion))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, getLocalizedString(lang, "altTextError", "response"))
+		}
+	}
+
+	message := renderReplyTemplate(config.Behavior.ReplyTemplate, "@"+notification.Account.Acct, strings.Join(lines, "\n―\n"), providerFooter(c, lang))
+
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: notification.Status.ID,
+		Visibility:  mapReplyVisibility(settingsFor(c).ReplyVisibility, notification.Status.Visibility),
+		Language:    lang,
+	})
+	if err != nil {
+		log.Printf("Error posting alt-text suggestions: %v", err)
+	}
+}
+
+// improveImageAltText asks the provider to improve the existing alt-text of an image
+func improveImageAltText(imageURL, remoteURL, existing, lang string) (string, error) {
+	img, err := fetchMedia(imageURL, remoteURL)
+	if err != nil {
+		return "", err
+	}
+
+	if imageDenyList.Contains(img) {
+		return "", ErrDeniedImage
+	}
+
+	decoded, sourceFormat, decodeErr := decodeImage(img)
+	downscaledImg, format, err := downscaleOrOriginal(imageURL, img, decoded, sourceFormat, decodeErr)
+	if err != nil {
+		return "", err
+	}
+
+	LogEvent("alt_text_generated")
+
+	// The existing alt-text is quoted in the prompt, so it's formatted in after the language instruction
+	prompt := fmt.Sprintf(localizedPrompt(lang, "improveAltText"), existing)
+	return describeImage(prompt, downscaledImg, format)
+}
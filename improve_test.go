@@ -0,0 +1,63 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestImproveRequestNeedsConsent(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester mastodon.ID
+		consent   bool
+		suggested bool
+	}{
+		{"author", "poster", true, true},
+		{"other without consent setting", "requester", false, true},
+		{"other with consent setting", "requester", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.ImageProcessing.ImproveAltText = true
+				c.Behavior.AskForConsent = tt.consent
+				c.RateLimit.Enabled = false
+			})
+			provider := &fakeProvider{response: "A red square on white"}
+			useProvider(t, provider)
+
+			c := newFakeClient(&mastodon.Status{
+				ID:         "post",
+				Account:    mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility: "public",
+				MediaAttachments: []mastodon.Attachment{{
+					ID:          "a",
+					Type:        "image",
+					URL:         dataURI("image/png", testImage(t, 8, 8, color.RGBA{255, 0, 0, 255})),
+					Description: "red",
+				}},
+			})
+			handleMention(c, &mastodon.Notification{
+				Account: mastodon.Account{ID: tt.requester, Acct: string(tt.requester)},
+				Status: &mastodon.Status{
+					ID:          "mention",
+					InReplyToID: "post",
+					Content:     "<p>@altbot improve</p>",
+					Visibility:  "public",
+					Language:    "en",
+				},
+			})
+
+			posted := c.postedToots()
+			if suggested := len(posted) == 1 && strings.Contains(posted[0].Status, "A red square on white"); suggested != tt.suggested {
+				t.Errorf("suggested = %v, want %v (posted %d toots)", suggested, tt.suggested, len(posted))
+			}
+			if !tt.suggested && provider.calls() != 0 {
+				t.Errorf("the provider was asked %d times without consent", provider.calls())
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// handleInfoCommand answers mentions that consist of only "help" or "version".
+// It returns true if the mention was such a command.
+func handleInfoCommand(c MastodonClient, notification *mastodon.Notification) bool {
+	words := strings.Fields(strings.ToLower(mentionText(notification.Status.Content)))
+	if len(words) != 1 || (words[0] != "help" && words[0] != "version") {
+		return false
+	}
+
+	if !rateLimiter.Increment(c, string(notification.Account.ID), "command") {
+		log.Printf("User @%s has exceeded their rate limit, ignoring %s command", notification.Account.Acct, words[0])
+		return true
+	}
+
+	lang := notification.Status.Language
+	provider := cases.Title(language.AmericanEnglish).String(config.LLM.Provider)
+	message := fmt.Sprintf(getLocalizedString(lang, "versionMessage", "response"), Version, provider)
+	if words[0] == "help" {
+		message += "\n\n" + fmt.Sprintf(getLocalizedString(lang, "helpMessage", "response"), strings.Join(supportedMediaTypes(), ", "))
+	}
+
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:      fmt.Sprintf("@%s %s", notification.Account.Acct, message),
+		InReplyToID: notification.Status.ID,
+		Visibility:  mapReplyVisibility(settingsFor(c).ReplyVisibility, notification.Status.Visibility),
+		Language:    lang,
+	})
+	if err != nil {
+		log.Printf("Error posting %s reply: %v", words[0], err)
+	}
+
+	return true
+}
+
+// supportedMediaTypes lists the media types the bot can currently describe
+func supportedMediaTypes() []string {
+	types := []string{"image"}
+	if videoAudioProcessingCapability {
+		types = append(types, "video", "audio")
+	}
+	if pdfProcessingCapability {
+		types = append(types, "pdf")
+	}
+	return types
+}
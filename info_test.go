@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestInfoCommands(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.LLM.Provider = "gemini"
+		c.RateLimit.Enabled = false
+	})
+
+	tests := []struct {
+		content string
+		handled bool
+		help    bool
+	}{
+		{"<p>@altbot version</p>", true, false},
+		{"<p>@altbot HELP</p>", true, true},
+		{"<p>@altbot help me with this picture</p>", false, false},
+		{"<p>@altbot</p>", false, false},
+	}
+	for _, tt := range tests {
+		c := newFakeClient()
+		handled := handleInfoCommand(c, &mastodon.Notification{
+			Account: mastodon.Account{ID: "user", Acct: "user"},
+			Status:  &mastodon.Status{ID: "mention", Content: tt.content, Visibility: "public", Language: "en"},
+		})
+
+		if handled != tt.handled {
+			t.Errorf("%s: handled = %v, want %v", tt.content, handled, tt.handled)
+		}
+		posted := c.postedToots()
+		if !tt.handled {
+			if len(posted) != 0 {
+				t.Errorf("%s: posted %+v", tt.content, posted)
+			}
+			continue
+		}
+		if len(posted) != 1 || posted[0].InReplyToID != "mention" || !strings.HasPrefix(posted[0].Status, "@user ") {
+			t.Fatalf("%s: posted %+v, want one reply", tt.content, posted)
+		}
+		if !strings.Contains(posted[0].Status, Version) || !strings.Contains(posted[0].Status, "Gemini") {
+			t.Errorf("%s: reply %q doesn't name the version and provider", tt.content, posted[0].Status)
+		}
+		if help := strings.Contains(posted[0].Status, "image"); help != tt.help {
+			t.Errorf("%s: reply %q lists the media types: %v, want %v", tt.content, posted[0].Status, help, tt.help)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"golang.org/x/net/html"
+)
+
+// maxLinkedImages limits how many linked images of a single post are described
+const maxLinkedImages = 4
+
+// maxLinkedURLChecks limits how many links of a single post are checked for being images
+const maxLinkedURLChecks = 8
+
+// linkedImageCheckTimeout bounds the checks of all links of a post together
+var linkedImageCheckTimeout = 10 * time.Second
+
+// attachLinkedImages adds the images linked in the content of a post without media as attachments,
+// so they go through the regular description pipeline
+func attachLinkedImages(status *mastodon.Status) {
+	if !config.ImageProcessing.DescribeLinkedImages || len(status.MediaAttachments) > 0 {
+		return
+	}
+
+	status.MediaAttachments = linkedImageAttachments(status.Content)
+}
+
+// linkedImageAttachments returns attachments for the allowed image links in the HTML content.
+// The links are checked concurrently, so a post full of slow links can't hold up the reply for long.
+func linkedImageAttachments(content string) []mastodon.Attachment {
+	links := extractLinks(content)
+	isImage := make([]bool, len(links))
+
+	checkCtx, cancel := context.WithTimeout(context.Background(), linkedImageCheckTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	checks := 0
+	for i, link := range links {
+		// Embedded images need neither the host allowlist nor a request to check their type
+		if isDataURI(link) {
+			isImage[i] = isImageDataURI(link)
+			continue
+		}
+		if checks >= maxLinkedURLChecks || !isAllowedLinkedURL(link) {
+			continue
+		}
+		checks++
+
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+			isImage[i] = isImageURL(checkCtx, link)
+		}(i, link)
+	}
+	wg.Wait()
+
+	var attachments []mastodon.Attachment
+	for i, link := range links {
+		if isImage[i] && len(attachments) < maxLinkedImages {
+			attachments = append(attachments, mastodon.Attachment{
+				Type: "image",
+				URL:  link,
+			})
+		}
+	}
+	return attachments
+}
+
+// attachCardImage adds the image of the post's preview card as an attachment if the post has no media
+// otherwise. The card image is the instance's own copy, so it doesn't need the linked image checks.
+func attachCardImage(status *mastodon.Status) {
+	if !config.ImageProcessing.DescribeCards || len(status.MediaAttachments) > 0 {
+		return
+	}
+	if status.Card == nil || status.Card.Image == "" {
+		return
+	}
+
+	status.MediaAttachments = append(status.MediaAttachments, mastodon.Attachment{
+		Type: "image",
+		URL:  status.Card.Image,
+	})
+}
+
+// extractLinks returns the targets of the links in the HTML content, skipping mentions and hashtags,
+// and the sources of embedded images, which some federated software sends as data URIs
+func extractLinks(content string) []string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		log.Printf("Error parsing HTML: %v", err)
+		return nil
+	}
+
+	var links []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			var href, class string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "href":
+					href = attr.Val
+				case "class":
+					class = attr.Val
+				}
+			}
+			if href != "" && !strings.Contains(class, "mention") && !strings.Contains(class, "hashtag") {
+				links = append(links, href)
+			}
+		}
+		if n.Type == html.ElementNode && n.Data == "img" {
+			for _, attr := range n.Attr {
+				if attr.Key == "src" && attr.Val != "" {
+					links = append(links, attr.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return links
+}
+
+// isAllowedLinkedURL reports whether a linked URL uses HTTPS and, if an allowlist is configured,
+// points to one of the allowed hosts or their subdomains
+func isAllowedLinkedURL(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return false
+	}
+
+	if len(config.ImageProcessing.LinkedImageHosts) == 0 {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range config.ImageProcessing.LinkedImageHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isImageURL checks with a HEAD request whether the URL points to an image
+func isImageURL(ctx context.Context, link string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := mediaHTTPClient.Do(req)
+	if err != nil {
+		log.Printf("Error checking linked URL %s: %v", link, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "image/")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// linkServer serves PNG images below /img, HTML pages below /page and never answers /slow
+func linkServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	png := testImage(t, 4, 4, color.White)
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/img"):
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		case strings.HasPrefix(r.URL.Path, "/slow"):
+			<-r.Context().Done()
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// The test server is on loopback and has its own certificate, so it's reached without the address check
+	saved := mediaHTTPClient
+	mediaHTTPClient = server.Client()
+	t.Cleanup(func() { mediaHTTPClient = saved })
+	return server, &requests
+}
+
+func linksContent(base string, paths ...string) string {
+	var content strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&content, `<a href="%s%s">link</a> `, base, path)
+	}
+	return "<p>" + content.String() + "</p>"
+}
+
+func TestLinkedImageAttachmentsKeepsOrder(t *testing.T) {
+	server, _ := linkServer(t)
+
+	attachments := linkedImageAttachments(linksContent(server.URL, "/page", "/img1", "/page2", "/img2"))
+
+	if len(attachments) != 2 || attachments[0].URL != server.URL+"/img1" || attachments[1].URL != server.URL+"/img2" {
+		t.Errorf("attachments = %+v, want img1 and img2", attachments)
+	}
+}
+
+func TestLinkedImageAttachmentsCapsChecks(t *testing.T) {
+	server, requests := linkServer(t)
+
+	var paths []string
+	for i := 0; i < 3*maxLinkedURLChecks; i++ {
+		paths = append(paths, fmt.Sprintf("/page%d", i))
+	}
+	paths = append(paths, "/img")
+
+	if attachments := linkedImageAttachments(linksContent(server.URL, paths...)); len(attachments) != 0 {
+		t.Errorf("attachments = %+v, the image was beyond the checked links", attachments)
+	}
+	if n := requests.Load(); n != maxLinkedURLChecks {
+		t.Errorf("checked %d links, want %d", n, maxLinkedURLChecks)
+	}
+}
+
+func TestLinkedImageAttachmentsSharesOneDeadline(t *testing.T) {
+	server, _ := linkServer(t)
+	saved := linkedImageCheckTimeout
+	linkedImageCheckTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { linkedImageCheckTimeout = saved })
+
+	start := time.Now()
+	attachments := linkedImageAttachments(linksContent(server.URL, "/slow1", "/slow2", "/slow3", "/slow4", "/img"))
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("checking the links took %v", elapsed)
+	}
+	if len(attachments) != 1 || attachments[0].URL != server.URL+"/img" {
+		t.Errorf("attachments = %+v, want the image", attachments)
+	}
+}
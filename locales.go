@@ -2,7 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
 )
 
 // Localization holds the localized strings for different languages
@@ -13,6 +18,9 @@ type Localization struct {
 
 var localizations map[string]Localization
 
+// maxDescriptionLanguages caps how many languages a single description is generated in
+const maxDescriptionLanguages = 3
+
 func loadLocalizations() error {
 	data, err := os.ReadFile("localizations.json")
 	if err != nil {
@@ -45,3 +53,64 @@ func getLocalizedString(lang, key string, category string) string {
 	}
 	return ""
 }
+
+// descriptionLanguages returns the languages a description should be generated in: the language
+// of the post first, followed by any additional configured languages that have localizations
+func descriptionLanguages(primary string) []string {
+	if primary == "" {
+		primary = config.Localization.DefaultLanguage
+	}
+
+	languages := []string{primary}
+	for _, lang := range config.Localization.Languages {
+		if len(languages) >= maxDescriptionLanguages {
+			break
+		}
+
+		if _, ok := localizations[lang]; !ok || lang == primary {
+			continue
+		}
+
+		duplicate := false
+		for _, existing := range languages {
+			if existing == lang {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			languages = append(languages, lang)
+		}
+	}
+
+	return languages
+}
+
+// promptLanguage returns the language prompts for a description in lang are written in. With
+// llm.prompt_language set, every prompt uses that language and states the description language.
+func promptLanguage(lang string) string {
+	if _, ok := localizations[config.LLM.PromptLanguage]; ok {
+		return config.LLM.PromptLanguage
+	}
+	return lang
+}
+
+// localizedPrompt returns the prompt for a description in lang, written in the prompt language
+func localizedPrompt(lang, key string) string {
+	// Languages without localizations are described in the default language
+	if _, ok := localizations[lang]; !ok {
+		lang = config.Localization.DefaultLanguage
+	}
+
+	promptLang := promptLanguage(lang)
+	prompt := getLocalizedString(promptLang, key, "prompt")
+	if promptLang == lang {
+		return prompt
+	}
+
+	name := display.Tags(language.Make(promptLang)).Name(language.Make(lang))
+	if name == "" {
+		name = lang
+	}
+	return strings.TrimSpace(prompt) + " " + fmt.Sprintf(getLocalizedString(promptLang, "answerInLanguage", "prompt"), name)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestLocalizedPrompt(t *testing.T) {
+	english := getLocalizedString("en", "generateAltText", "prompt")
+	german := getLocalizedString("de", "generateAltText", "prompt")
+
+	tests := []struct {
+		name           string
+		promptLanguage string
+		lang           string
+		want           string
+	}{
+		{"reply language", "", "de", german},
+		{"same as prompt language", "en", "en", english},
+		{"unknown language", "", "nl", english},
+		{"unknown prompt language", "xx", "de", german},
+		{"prompt language", "en", "de", strings.TrimSpace(english) + " Important: write the description in German instead."},
+		{"prompt language with unknown language", "de", "nl", strings.TrimSpace(german) + " " + strings.Replace(getLocalizedString("de", "answerInLanguage", "prompt"), "%s", "Englisch", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.LLM.PromptLanguage = tt.promptLanguage })
+			if got := localizedPrompt(tt.lang, "generateAltText"); got != tt.want {
+				t.Errorf("localizedPrompt(%s) = %q, want %q", tt.lang, got, tt.want)
+			}
+		})
+	}
+}
+
+// useResponse replaces a localized response for the duration of the test
+func useResponse(t *testing.T, lang, key, value string) {
+	t.Helper()
+	responses := localizations[lang].Responses
+	saved, existed := responses[key]
+	responses[key] = value
+	t.Cleanup(func() {
+		if existed {
+			responses[key] = saved
+		} else {
+			delete(responses, key)
+		}
+	})
+}
+
+func TestFrameDescription(t *testing.T) {
+	useResponse(t, "de", "altTextPrefix", "Bild:")
+	useResponse(t, "fr", "altTextSuffix", "(description automatique)")
+	useResponse(t, "ja", "altTextPrefix", "画像：")
+	useResponse(t, "ja", "altTextSuffix", "。")
+
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"en", "A cat."},
+		{"de", "Bild: A cat."},
+		{"fr", "A cat. (description automatique)"},
+		{"ja", "画像： A cat. 。"},
+		{"nl", "A cat."},
+	}
+	for _, tt := range tests {
+		if got := frameDescription("A cat.", tt.lang); got != tt.want {
+			t.Errorf("frameDescription(%s) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestFramingCountsAgainstTheLengthLimit(t *testing.T) {
+	withConfig(t, func(c *Config) { c.LLM.MaxAltChars = 20 })
+	useResponse(t, "de", "altTextPrefix", "Bild:")
+	c := newFakeClient()
+	replyPost := &mastodon.Status{ID: "framed", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "de"}
+
+	altText, err := generateInLanguages(c, replyPost, mastodon.Attachment{Type: "image"}, func(mediaURL, remoteURL, lang string) (string, error) {
+		return "Eine Katze auf einem Sofa.", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(altText, "Bild: Eine Katze") || utf8.RuneCountInString(altText) > 20 {
+		t.Errorf("alt-text = %q, want the prefix kept within 20 characters", altText)
+	}
+}
+
+func TestDescriptionLanguages(t *testing.T) {
+	tests := []struct {
+		name      string
+		primary   string
+		languages []string
+		want      []string
+	}{
+		{"post language only", "de", nil, []string{"de"}},
+		{"default language", "", nil, []string{"en"}},
+		{"additional", "de", []string{"en", "fr"}, []string{"de", "en", "fr"}},
+		{"primary not repeated", "en", []string{"en", "de"}, []string{"en", "de"}},
+		{"duplicates and unknown skipped", "de", []string{"xx", "en", "en"}, []string{"de", "en"}},
+		{"at most three", "de", []string{"en", "fr", "es"}, []string{"de", "en", "fr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.Localization.Languages = tt.languages })
+			if got := descriptionLanguages(tt.primary); !slices.Equal(got, tt.want) {
+				t.Errorf("descriptionLanguages(%q) = %v, want %v", tt.primary, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateInLanguagesLabelsEverySection(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Localization.Languages = []string{"en", "fr", "es"}
+		c.RateLimit.Enabled = false
+	})
+	c := newFakeClient()
+	replyPost := &mastodon.Status{ID: "multilingual", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "de"}
+	descriptions := map[string]string{"de": "Eine Katze.", "en": "A cat.", "fr": "Un chat."}
+
+	var asked []string
+	altText, err := generateInLanguages(c, replyPost, mastodon.Attachment{Type: "image"}, func(mediaURL, remoteURL, lang string) (string, error) {
+		asked = append(asked, lang)
+		return descriptions[lang], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Deutsch: Eine Katze.\n\nEnglish: A cat.\n\nFrançais: Un chat."; altText != want {
+		t.Errorf("alt-text = %q, want %q", altText, want)
+	}
+	if !slices.Equal(asked, []string{"de", "en", "fr"}) {
+		t.Errorf("asked for %v, want de, en and fr", asked)
+	}
+}
+
+func TestGenerateInLanguagesFailures(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Localization.Languages = []string{"en", "fr"}
+		c.RateLimit.Enabled = false
+	})
+	c := newFakeClient()
+	replyPost := &mastodon.Status{ID: "partly", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "de"}
+	failing := errors.New("the model fell over")
+
+	// A failed additional language is left out
+	altText, err := generateInLanguages(c, replyPost, mastodon.Attachment{Type: "image"}, func(mediaURL, remoteURL, lang string) (string, error) {
+		if lang == "en" {
+			return "", failing
+		}
+		return "Text in " + lang + ".", nil
+	})
+	if err != nil || altText != "Deutsch: Text in de.\n\nFrançais: Text in fr." {
+		t.Errorf("generateInLanguages = %q, %v, want the German and French sections", altText, err)
+	}
+
+	// Without the post's own language there is no description at all
+	altText, err = generateInLanguages(c, replyPost, mastodon.Attachment{Type: "image"}, func(mediaURL, remoteURL, lang string) (string, error) {
+		if lang == "de" {
+			return "", failing
+		}
+		return "Text in " + lang + ".", nil
+	})
+	if !errors.Is(err, failing) || altText != "" {
+		t.Errorf("generateInLanguages = %q, %v, want the error of the post's language", altText, err)
+	}
+}
+
+func TestAdditionalLanguagesCountAgainstTheRateLimit(t *testing.T) {
+	useRateLimit(t, 2)
+	withConfig(t, func(c *Config) { c.Localization.Languages = []string{"en", "fr"} })
+	c := clientWithUser("polyglot")
+	replyPost := &mastodon.Status{ID: "limited", Account: mastodon.Account{ID: "polyglot", Acct: "polyglot"}, Language: "de"}
+	if !rateLimiter.Increment(c, "polyglot", "image") {
+		t.Fatal("the first request was refused")
+	}
+
+	altText, err := generateInLanguages(c, replyPost, mastodon.Attachment{Type: "image"}, func(mediaURL, remoteURL, lang string) (string, error) {
+		return "Text in " + lang + ".", nil
+	})
+	if err != nil || altText != "Deutsch: Text in de.\n\nEnglish: Text in en." {
+		t.Errorf("generateInLanguages = %q, %v, want French left out over the limit", altText, err)
+	}
+}
@@ -6,14 +6,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
-	"io"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"os/exec"
@@ -25,6 +26,7 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/HugoSmits86/nativewebp"
 	"golang.org/x/image/bmp"
 	"golang.org/x/image/tiff"
 	"golang.org/x/image/webp"
@@ -49,21 +51,45 @@ const AsciiArt = `    _   _ _   ___     _
  /_/ \_|_|\__|___\___/\__| `
 const Motto = "アクセシビリティロボット"
 
+// DefaultGeminiModel is used when no Gemini model is configured
+const DefaultGeminiModel = "gemini-1.5-flash"
+
 type Config struct {
 	Server struct {
-		MastodonServer string `toml:"mastodon_server"`
-		ClientSecret   string `toml:"client_secret"`
-		AccessToken    string `toml:"access_token"`
-		Username       string `toml:"username"`
+		MastodonServer     string `toml:"mastodon_server"`
+		ClientSecret       string `toml:"client_secret"`
+		AccessToken        string `toml:"access_token"`
+		Username           string `toml:"username"`
+		IdleTimeoutSeconds int    `toml:"idle_timeout_seconds"`
 	} `toml:"server"`
 	LLM struct {
-		Provider    string `toml:"provider"`
-		OllamaModel string `toml:"ollama_model"`
+		Provider                           string   `toml:"provider"`
+		OllamaModel                        string   `toml:"ollama_model"`
+		ImageProvider                      string   `toml:"image_provider"`
+		VideoProvider                      string   `toml:"video_provider"`
+		AudioProvider                      string   `toml:"audio_provider"`
+		MaxAltChars                        int      `toml:"max_alt_chars"`
+		TruncateAt                         string   `toml:"truncate_at"`
+		PromptLanguage                     string   `toml:"prompt_language"`
+		DescriptionTemplate                string   `toml:"description_template"`
+		RetryOnEmpty                       bool     `toml:"retry_on_empty"`
+		MinAltTextLength                   int      `toml:"min_alt_text_length"`
+		LowValuePhrases                    []string `toml:"low_value_phrases"`
+		RetryLowQuality                    bool     `toml:"retry_low_quality"`
+		MaxRetriesPerPost                  int      `toml:"max_retries_per_post"`
+		RefusalPhrases                     []string `toml:"refusal_phrases"`
+		PerAttachmentTimeoutSeconds        int      `toml:"per_attachment_timeout_seconds"`
+		EnableProviderHealthcheck          bool     `toml:"enable_provider_healthcheck"`
+		ProviderHealthcheckIntervalMinutes int      `toml:"provider_healthcheck_interval_minutes"`
 	} `toml:"llm"`
 	Gemini struct {
-		APIKey      string  `toml:"api_key"`
-		Temperature float32 `toml:"temperature"`
-		TopK        int32   `toml:"top_k"`
+		APIKey             string  `toml:"api_key"`
+		Model              string  `toml:"model"`
+		SystemInstruction  string  `toml:"system_instruction"`
+		StructuredOutput   bool    `toml:"structured_output"`
+		StructuredTemplate string  `toml:"structured_template"`
+		Temperature        float32 `toml:"temperature"`
+		TopK               int32   `toml:"top_k"`
 	} `toml:"gemini"`
 	SafetySettings struct {
 		HarassmentThreshold       string `toml:"harassment_threshold"`
@@ -72,27 +98,81 @@ type Config struct {
 		DangerousContentThreshold string `toml:"dangerous_content_threshold"`
 	} `toml:"safety_settings"`
 	Localization struct {
-		DefaultLanguage string `toml:"default_language"`
+		DefaultLanguage string   `toml:"default_language"`
+		Languages       []string `toml:"languages"`
 	} `toml:"localization"`
 	DNI struct {
 		Tags       []string `toml:"tags"`
 		IgnoreBots bool     `toml:"ignore_bots"`
+		PostTags   []string `toml:"post_tags"`
 	} `toml:"dni"`
 	ImageProcessing struct {
-		DownscaleWidth uint `toml:"downscale_width"`
-		MaxSizeMB      uint `toml:"max_size_mb"`
+		DownscaleWidth          uint              `toml:"downscale_width"`
+		UploadFormats           map[string]string `toml:"upload_formats"`
+		MaxSizeMB               uint              `toml:"max_size_mb"`
+		MaxSizeMBOverrides      map[string]uint   `toml:"max_size_mb_overrides"`
+		MaxTempStorageMB        uint              `toml:"max_temp_storage_mb"`
+		AnimationFrames         int               `toml:"animation_frames"`
+		AllowedMediaTypes       []string          `toml:"allowed_media_types"`
+		BlockedMediaTypes       []string          `toml:"blocked_media_types"`
+		DescribeLinkedImages    bool              `toml:"describe_linked_images"`
+		DescribeCards           bool              `toml:"describe_cards"`
+		DescribeDirectURLs      bool              `toml:"describe_direct_urls"`
+		ClassifyImages          bool              `toml:"classify_images"`
+		LinkedImageHosts        []string          `toml:"linked_image_hosts"`
+		AllowedInternalNetworks []string          `toml:"allowed_internal_networks"`
+		DescribeEmojis          bool              `toml:"describe_emojis"`
+		ImproveAltText          bool              `toml:"improve_alt_text"`
+		DescribePDFs            bool              `toml:"describe_pdfs"`
+		MaxPDFPages             int               `toml:"max_pdf_pages"`
+		DescribePalette         bool              `toml:"describe_palette"`
+		PaletteColors           int               `toml:"palette_colors"`
+		DescribeShape           bool              `toml:"describe_shape"`
 	} `toml:"image_processing"`
 	Behavior struct {
-		ReplyVisibility string `toml:"reply_visibility"`
-		FollowBack      bool   `toml:"follow_back"`
-		AskForConsent   bool   `toml:"ask_for_consent"`
+		ReplyVisibility             string   `toml:"reply_visibility"`
+		FollowBack                  bool     `toml:"follow_back"`
+		AskForConsent               bool     `toml:"ask_for_consent"`
+		PauseFile                   string   `toml:"pause_file"`
+		ReplyDelayMin               int      `toml:"reply_delay_min_seconds"`
+		ReplyDelayMax               int      `toml:"reply_delay_max_seconds"`
+		ReplyOnError                bool     `toml:"reply_on_error"`
+		DeliveryMode                string   `toml:"delivery_mode"`
+		OneReplyPerImage            bool     `toml:"one_reply_per_image"`
+		AcknowledgeConsentDenial    bool     `toml:"acknowledge_consent_denial"`
+		FollowBackSkipBots          bool     `toml:"follow_back_skip_bots"`
+		FollowBackMinAccountAgeDays int      `toml:"follow_back_min_account_age_days"`
+		FollowBackSkipDNI           bool     `toml:"follow_back_skip_dni"`
+		MaxFollowBacksPerDay        int      `toml:"max_follow_backs_per_day"`
+		WelcomeMessage              bool     `toml:"welcome_message"`
+		UnfollowWhenUnfollowed      bool     `toml:"unfollow_when_unfollowed"`
+		ReplyTemplate               string   `toml:"reply_template"`
+		TriggerHashtags             []string `toml:"trigger_hashtags"`
+		ExcludeHashtags             []string `toml:"exclude_hashtags"`
+		MaxReplyDepth               int      `toml:"max_reply_depth"`
+		SkipBelowOwnReplies         int      `toml:"skip_below_own_replies"`
+		MaxPostAgeMinutes           int      `toml:"max_post_age_minutes"`
+		EnableAdaptiveSkip          bool     `toml:"enable_adaptive_skip"`
+		AdaptiveSkipThreshold       float64  `toml:"adaptive_skip_threshold"`
+		AdaptiveSkipMinMedia        int      `toml:"adaptive_skip_min_media"`
+		AutoDescribeDelaySeconds    int      `toml:"auto_describe_delay_seconds"`
+		KnownAltTextBots            []string `toml:"known_alt_text_bots"`
+		CatchUpOnStartup            bool     `toml:"catch_up_on_startup"`
+		CatchUpMaxAgeMinutes        int      `toml:"catch_up_max_age_minutes"`
+		SkipBlockingAccounts        bool     `toml:"skip_blocking_accounts"`
+		DeadLetters                 bool     `toml:"dead_letters"`
 	} `toml:"behavior"`
 	WeeklySummary struct {
-		Enabled         bool     `toml:"enabled"`
-		PostDay         string   `toml:"post_day"`
-		PostTime        string   `toml:"post_time"`
-		MessageTemplate string   `toml:"message_template"`
-		Tips            []string `toml:"tips"`
+		Enabled             bool     `toml:"enabled"`
+		PostDay             string   `toml:"post_day"`
+		PostTime            string   `toml:"post_time"`
+		MessageTemplate     string   `toml:"message_template"`
+		Analytics           string   `toml:"analytics"`
+		TipSelection        string   `toml:"tip_selection"`
+		StatsConcurrency    int      `toml:"stats_concurrency"`
+		StatsTimeoutSeconds int      `toml:"stats_timeout_seconds"`
+		AttachChart         bool     `toml:"attach_chart"`
+		Tips                []string `toml:"tips"`
 	} `toml:"weekly_summary"`
 	Metrics struct {
 		Enabled          bool `toml:"enabled"`
@@ -100,19 +180,54 @@ type Config struct {
 		DashboardPort    int  `toml:"dashboard_port"`
 	} `toml:"metrics"`
 	RateLimit struct {
-		Enabled                        bool   `toml:"enabled"`
-		MaxRequestsPerMinute           int    `toml:"max_requests_per_user_per_minute"`
-		MaxRequestsPerHour             int    `toml:"max_requests_per_user_per_hour"`
-		NewAccountMaxRequestsPerMinute int    `toml:"new_account_max_requests_per_minute"`
-		NewAccountMaxRequestsPerHour   int    `toml:"new_account_max_requests_per_hour"`
-		NewAccountPeriodDays           int    `toml:"new_account_period_days"`
-		ShadowBanThreshold             int    `toml:"shadow_ban_threshold"`
-		AdminContactHandle             string `toml:"admin_contact_handle"`
+		Enabled                        bool                      `toml:"enabled"`
+		MaxRequestsPerMinute           int                       `toml:"max_requests_per_user_per_minute"`
+		MaxRequestsPerHour             int                       `toml:"max_requests_per_user_per_hour"`
+		NewAccountMaxRequestsPerMinute int                       `toml:"new_account_max_requests_per_minute"`
+		NewAccountMaxRequestsPerHour   int                       `toml:"new_account_max_requests_per_hour"`
+		NewAccountPeriodDays           int                       `toml:"new_account_period_days"`
+		ShadowBanThreshold             int                       `toml:"shadow_ban_threshold"`
+		AdminContactHandle             string                    `toml:"admin_contact_handle"`
+		MediaLimits                    map[string]MediaRateLimit `toml:"media_limits"`
+		ExceededReply                  string                    `toml:"exceeded_reply"`
 	} `toml:"rate_limit"`
+	PromptStyles map[string]string `toml:"prompt_styles"`
+	Cache        struct {
+		Enabled            bool `toml:"enabled"`
+		MaxHammingDistance int  `toml:"max_hamming_distance"`
+		MaxEntries         int  `toml:"max_entries"`
+		TTLHours           int  `toml:"ttl_hours"`
+	} `toml:"cache"`
+	Moderation struct {
+		DeniedImageHashes     []string `toml:"denied_image_hashes"`
+		DeniedImageHashesFile string   `toml:"denied_image_hashes_file"`
+	} `toml:"moderation"`
+	Corrections struct {
+		Enabled  bool   `toml:"enabled"`
+		FilePath string `toml:"file_path"`
+	} `toml:"corrections"`
+	Budget struct {
+		Enabled                    bool    `toml:"enabled"`
+		InputCostPerMillionTokens  float64 `toml:"input_cost_per_million_tokens"`
+		OutputCostPerMillionTokens float64 `toml:"output_cost_per_million_tokens"`
+		DailyLimit                 float64 `toml:"daily_limit"`
+		MonthlyLimit               float64 `toml:"monthly_limit"`
+		FallbackProvider           string  `toml:"fallback_provider"`
+	} `toml:"budget"`
 	AltTextReminders struct {
 		Enabled      bool `toml:"enabled"`
 		ReminderTime int  `toml:"reminder_time"`
 	} `toml:"alt_text_reminders"`
+	Accounts []AccountConfig `toml:"accounts"`
+	Patrol   struct {
+		Enabled             bool   `toml:"enabled"`
+		IntervalMinutes     int    `toml:"interval_minutes"`
+		Scope               string `toml:"scope"`
+		Tag                 string `toml:"tag"`
+		MaxAgeMinutes       int    `toml:"max_age_minutes"`
+		MaxPostsPerRun      int    `toml:"max_posts_per_run"`
+		OfferToNonFollowers bool   `toml:"offer_to_non_followers"`
+	} `toml:"patrol"`
 }
 
 const (
@@ -133,7 +248,10 @@ var model *genai.GenerativeModel
 var client *genai.Client
 var ctx context.Context
 
-var consentRequests = make(map[mastodon.ID]ConsentRequest)
+// consentRequests holds the open consent requests by the accountKey of the post they are about. It is
+// shared by the streams of all accounts and the patrol, so every access holds consentMutex.
+var consentRequests = make(map[string]ConsentRequest)
+var consentMutex sync.Mutex
 
 var videoAudioProcessingCapability = true
 
@@ -141,8 +259,14 @@ var rateLimiter *RateLimiter
 
 var metricsManager *MetricsManager
 
+// botAccountIDs holds the verified IDs of the bot's own accounts by instanceKey, used to recognise their posts
+var botAccountIDs = make(map[string]bool)
+
 func main() {
 	setupFlag := flag.Bool("setup", false, "Run the setup wizard")
+	registerFlag := flag.Bool("register", false, "Register the bot with the Mastodon server and save the credentials")
+	registerEmail := flag.String("register-email", "", "Register non-interactively by logging in with this email, the password is read from ALTBOT_PASSWORD")
+	previewSummaryFlag := flag.Bool("preview-summary", false, "Print the next weekly summary with the current statistics without posting it")
 	flag.Parse()
 
 	// Load default configuration from example.config.toml
@@ -164,10 +288,21 @@ func main() {
 		runSetupWizard("config.toml")
 	}
 
+	if *registerFlag || *registerEmail != "" {
+		runRegistration("config.toml", *registerEmail)
+	}
+
 	// Load configuration from config.toml
-	if _, err := toml.DecodeFile("config.toml", &config); err != nil {
+	md, err := toml.DecodeFile("config.toml", &config)
+	if err != nil {
 		log.Fatalf("Error loading config.toml: %v", err)
 	}
+	applyConfigDefaults(md)
+
+	if *previewSummaryFlag {
+		fmt.Println(previewWeeklySummary(context.Background()))
+		return
+	}
 
 	// Compare config with defaultConfig and print warnings or custom settings
 	customSettingsCount := compareConfigs(defaultConfig, config)
@@ -176,16 +311,39 @@ func main() {
 		log.Fatal("Please configure the Mastodon server in config.toml")
 	}
 
-	if config.LLM.Provider == "ollama" {
+	llmProvider, err = newProvider(config.LLM.Provider)
+	if err != nil {
+		log.Fatalf("Error selecting LLM provider: %v", err)
+	}
+
+	if err := loadMediaProviders(); err != nil {
+		log.Fatalf("Error selecting media type providers: %v", err)
+	}
+
+	if err := loadAllowedInternalNetworks(config.ImageProcessing.AllowedInternalNetworks); err != nil {
+		log.Fatalf("Error loading allowed internal networks: %v", err)
+	}
+
+	if config.Budget.Enabled && config.Budget.FallbackProvider != "" {
+		fallbackProvider, err = newProvider(config.Budget.FallbackProvider)
+		if err != nil {
+			log.Fatalf("Error selecting fallback provider: %v", err)
+		}
+	}
+
+	if usesProvider("ollama") || fallbackProvider != nil && fallbackProvider.Name() == "ollama" {
 		err := checkOllamaModel()
 		if err != nil {
 			log.Fatalf("Error checking Ollama model: %v", err)
 		}
-
-		videoAudioProcessingCapability = false
 	}
 
-	err := loadLocalizations()
+	videoAudioProcessingCapability = providerFor("video").SupportsMedia("video") && providerFor("audio").SupportsMedia("audio")
+
+	// Only Gemini can read PDFs
+	pdfProcessingCapability = config.ImageProcessing.DescribePDFs && providerFor("document").SupportsMedia("document")
+
+	err = loadLocalizations()
 	if err != nil {
 		log.Fatalf("Error loading localizations: %v", err)
 	}
@@ -199,18 +357,27 @@ func main() {
 	ctx, cancel = context.WithCancel(context.Background())
 	defer cancel()
 
-	c := mastodon.NewClient(&mastodon.Config{
-		Server:       config.Server.MastodonServer,
-		ClientSecret: config.Server.ClientSecret,
-		AccessToken:  config.Server.AccessToken,
-	})
-
-	// Fetch and verify the bot account ID
-	_, err = fetchAndVerifyBotAccountID(c)
+	// Connect the main account and verify its account ID
+	c, err := connectAccount(AccountConfig{
+		MastodonServer: config.Server.MastodonServer,
+		ClientSecret:   config.Server.ClientSecret,
+		AccessToken:    config.Server.AccessToken,
+		Username:       config.Server.Username,
+	}, true)
 	if err != nil {
 		log.Fatalf("Error fetching bot account ID: %v", err)
 	}
 
+	// Additional accounts share everything but their credentials with the main account
+	var additionalAccounts []*botAccount
+	for _, accountConfig := range config.Accounts {
+		account, err := connectAccount(accountConfig, false)
+		if err != nil {
+			log.Fatalf("Error connecting account %s on %s: %v", accountConfig.Username, accountConfig.MastodonServer, err)
+		}
+		additionalAccounts = append(additionalAccounts, account)
+	}
+
 	fmt.Printf("%s %d Custom settings loaded\n\n", getStatusSymbol(customSettingsCount > 0), customSettingsCount)
 
 	fmt.Printf("%s Mastodon Connection: %s\n", getStatusSymbol(true), config.Server.MastodonServer)
@@ -223,13 +390,17 @@ func main() {
 	}
 
 	// Connect to Mastodon streaming API
-	ws := c.NewWSClient()
+	ws := c.live.NewWSClient()
 
 	events, err := ws.StreamingWSUser(ctx)
 	if err != nil {
 		log.Fatalf("Error connecting to streaming API: %v", err)
 	}
 
+	if config.Server.IdleTimeoutSeconds > 0 {
+		go startIdleWatchdog(time.Duration(config.Server.IdleTimeoutSeconds) * time.Second)
+	}
+
 	if config.WeeklySummary.Enabled {
 		go startWeeklySummaryScheduler(c)
 		fmt.Printf("%s Weekly Summary: %vs %v\n", getStatusSymbol(config.WeeklySummary.Enabled), config.WeeklySummary.PostDay, config.WeeklySummary.PostTime)
@@ -237,6 +408,11 @@ func main() {
 		fmt.Printf("%s Weekly Summary: %v\n", getStatusSymbol(config.WeeklySummary.Enabled), config.WeeklySummary.Enabled)
 	}
 
+	if config.Patrol.Enabled && config.Patrol.IntervalMinutes > 0 {
+		go startPatrol(c, time.Duration(config.Patrol.IntervalMinutes)*time.Minute)
+	}
+	fmt.Printf("%s Patrol: %v\n", getStatusSymbol(config.Patrol.Enabled), config.Patrol.Enabled)
+
 	if config.AltTextReminders.Enabled {
 		go checkAltTextPeriodically(c, 1*time.Minute, time.Duration(config.AltTextReminders.ReminderTime)*time.Minute)
 		fmt.Printf("%s Alt Text Reminders: %v mins\n", getStatusSymbol(config.AltTextReminders.Enabled), config.AltTextReminders.ReminderTime)
@@ -278,6 +454,30 @@ func main() {
 		log.Fatalf("Error loading consent requests: %v", err)
 	}
 
+	if err := stylePreferences.LoadFromFile("style_preferences.json"); err != nil {
+		log.Fatalf("Error loading style preferences: %v", err)
+	}
+
+	if err := budgetUsage.LoadFromFile("budget_usage.json"); err != nil {
+		log.Fatalf("Error loading budget usage: %v", err)
+	}
+
+	if err := altTextStats.LoadFromFile("alt_text_stats.json"); err != nil {
+		log.Fatalf("Error loading alt-text stats: %v", err)
+	}
+
+	if err := notificationCursors.LoadFromFile("notification_cursors.json"); err != nil {
+		log.Fatalf("Error loading notification cursors: %v", err)
+	}
+
+	if err := blockedBy.LoadFromFile("blocked_by.json"); err != nil {
+		log.Fatalf("Error loading blocked accounts: %v", err)
+	}
+
+	if err := followBackLimit.LoadFromFile("follow_backs.json"); err != nil {
+		log.Fatalf("Error loading follow-back counts: %v", err)
+	}
+
 	go func() {
 		for {
 			time.Sleep(1 * time.Hour)
@@ -293,7 +493,16 @@ func main() {
 
 	fmt.Printf("%s Metrics Collection: %v\n", getStatusSymbol(config.Metrics.Enabled), config.Metrics.Enabled)
 
+	if config.LLM.EnableProviderHealthcheck {
+		err := checkProviderHealth()
+		fmt.Printf("%s Provider Health Check: %v\n", getStatusSymbol(err == nil), err == nil)
+		if interval := config.LLM.ProviderHealthcheckIntervalMinutes; interval > 0 {
+			go startProviderHealthChecks(time.Duration(interval) * time.Minute)
+		}
+	}
+
 	if config.Metrics.DashboardEnabled {
+		http.HandleFunc("/healthz", handleHealthz)
 		dashboard.StartDashboard("metrics.json", config.Metrics.DashboardPort)
 		fmt.Printf("%s Metrics Dashboard: %s\n", getStatusSymbol(true), "http://localhost:"+strconv.Itoa(config.Metrics.DashboardPort))
 	} else {
@@ -302,82 +511,125 @@ func main() {
 
 	fmt.Println("\n-----------------------------------")
 
+	for _, account := range additionalAccounts {
+		go streamAccount(account)
+		go startFollowBackQueue(account)
+	}
+	fmt.Printf("%s Additional Accounts: %d\n", getStatusSymbol(len(additionalAccounts) > 0), len(additionalAccounts))
+
+	go startFollowBackQueue(c)
+
 	fmt.Println("Connected to streaming API. All systems operational. Waiting for mentions and follows...")
 
-	// Main event loop
+	// The stream is already connected, so nothing arriving during the catch-up is lost
+	if config.Behavior.CatchUpOnStartup {
+		catchUpNotifications(c)
+	}
+	handleEvents(c, events)
+}
+
+// handleEvents dispatches the streaming events of one account to the handlers, using that account's client
+func handleEvents(c MastodonClient, events <-chan mastodon.Event) {
 	for event := range events {
+		watchdogFor(accountUsername(c)).Touch()
+
 		switch e := event.(type) {
 		case *mastodon.NotificationEvent:
-			switch e.Notification.Type {
-			case "mention": // Get the ID of the status being replied to
-				if "@"+e.Notification.Account.Acct == config.RateLimit.AdminContactHandle {
-					handleAdminReply(c, e.Notification.Status, rateLimiter)
-				}
+			handleNotification(c, e.Notification)
+		case *mastodon.UpdateEvent:
+			handleUpdate(c, e.Status)
+		case *mastodon.ErrorEvent:
+			log.Printf("Error event: %v", e.Error())
+		case *mastodon.DeleteEvent:
+			handleDeleteEvent(c, e.ID)
+		}
+	}
+}
 
-				if parentStatusRef := e.Notification.Status.InReplyToID; parentStatusRef != nil {
-					var parentStatusID mastodon.ID
+// handleNotification dispatches a notification to the handlers, skipping ones that were already handled
+func handleNotification(c MastodonClient, notification *mastodon.Notification) {
+	account := accountUsername(c)
+	if !notificationCursors.Claim(account, notification.ID) {
+		return
+	}
+	defer notificationCursors.MarkHandled(account, notification.ID)
 
-					// Convert the parent status ID to the correct type
-					switch typedID := parentStatusRef.(type) {
-					case string:
-						parentStatusID = mastodon.ID(typedID)
-					case mastodon.ID:
-						parentStatusID = typedID
-					}
+	switch notification.Type {
+	case "mention": // Get the ID of the status being replied to
+		if isSelf(c, &notification.Account) {
+			// Never react to our own posts, which could otherwise start a reply loop
+			break
+		}
 
-					// Fetch the parent status
-					parentStatus, err := c.GetStatus(ctx, parentStatusID)
+		if "@"+notification.Account.Acct == config.RateLimit.AdminContactHandle {
+			handleAdminReply(c, notification.Status, rateLimiter)
+		}
 
-					if parentStatus == nil {
-						log.Printf("Error fetching parent status: %v", err)
-						break
-					}
+		if parentStatusRef := notification.Status.InReplyToID; parentStatusRef != nil {
+			var parentStatusID mastodon.ID
 
-					if err != nil {
-						handleMention(c, e.Notification)
-					}
+			// Convert the parent status ID to the correct type
+			switch typedID := parentStatusRef.(type) {
+			case string:
+				parentStatusID = mastodon.ID(typedID)
+			case mastodon.ID:
+				parentStatusID = typedID
+			}
 
-					// Get the grandparent status ID (the status that the parent was replying to)
-					grandparentStatusRef := parentStatus.InReplyToID
+			// Fetch the parent status
+			// Without the parent there is nothing to describe or answer, so stop here
+			parentStatus, err := fetchStatus(ctx, c, parentStatusID)
+			if err != nil {
+				log.Printf("Error fetching parent status %s: %v", parentStatusID, err)
+				break
+			}
 
-					var grandparentStatusID mastodon.ID
-					// Convert the grandparent status ID to the correct type
-					switch typedID := grandparentStatusRef.(type) {
-					case string:
-						grandparentStatusID = mastodon.ID(typedID)
-					case mastodon.ID:
-						grandparentStatusID = typedID
-					}
+			// Get the grandparent status ID (the status that the parent was replying to)
+			grandparentStatusRef := parentStatus.InReplyToID
 
-					// Check if this is a response to a consent request
-					if _, isConsentRequest := consentRequests[grandparentStatusID]; isConsentRequest {
-						handleConsentResponse(c, grandparentStatusID, e.Notification.Status)
-					} else {
-						handleMention(c, e.Notification)
-					}
+			var grandparentStatusID mastodon.ID
+			// Convert the grandparent status ID to the correct type
+			switch typedID := grandparentStatusRef.(type) {
+			case string:
+				grandparentStatusID = mastodon.ID(typedID)
+			case mastodon.ID:
+				grandparentStatusID = typedID
+			}
+
+			// Check if this is a response to a consent request or to one of the bot's descriptions
+			consentMutex.Lock()
+			_, isConsentRequest := consentRequests[accountKey(c, grandparentStatusID)]
+			consentMutex.Unlock()
+
+			if isConsentRequest {
+				handleConsentResponse(c, grandparentStatusID, notification.Status)
+			} else if originalID, isBotReply := findOriginalForReply(c, parentStatusID); isBotReply {
+				if hint, isRedo := parseRedoCommand(notification.Status.Content); isRedo {
+					handleRedo(c, originalID, notification, hint)
 				} else {
-					handleMention(c, e.Notification)
+					handleCorrection(c, originalID, parentStatus, notification.Status)
 				}
-			case "follow":
-				handleFollow(c, e.Notification)
+			} else {
+				handleMention(c, notification)
 			}
-		case *mastodon.UpdateEvent:
-			handleUpdate(c, e.Status)
-		case *mastodon.ErrorEvent:
-			log.Printf("Error event: %v", e.Error())
-		case *mastodon.DeleteEvent:
-			handleDeleteEvent(c, e.ID)
+		} else {
+			handleMention(c, notification)
 		}
+	case "follow":
+		handleFollow(c, notification)
 	}
 }
 
 // fetchAndVerifyBotAccountID fetches and prints the bot account details to verify the account ID
-func fetchAndVerifyBotAccountID(c *mastodon.Client) (mastodon.ID, error) {
+func fetchAndVerifyBotAccountID(c MastodonClient, username string) (mastodon.ID, error) {
 	acct, err := c.GetAccountCurrentUser(ctx)
 	if err != nil {
 		return "", err
 	}
 	fmt.Printf("Bot Account ID: %s, Username: %s\n\n", acct.ID, acct.Acct)
+	if !strings.EqualFold(acct.Username, username) {
+		log.Printf("Configured username %q does not match the authenticated account %q; self-detection uses the account ID", username, acct.Username)
+	}
 	return acct.ID, nil
 }
 
@@ -391,7 +643,11 @@ func Setup(apiKey string) error {
 		return err
 	}
 
-	model = client.GenerativeModel("gemini-1.5-flash")
+	model = client.GenerativeModel(geminiModelName())
+
+	if config.Gemini.SystemInstruction != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(config.Gemini.SystemInstruction))
+	}
 
 	model.SetTemperature(config.Gemini.Temperature)
 	model.SetTopK(config.Gemini.TopK)
@@ -415,9 +671,23 @@ func Setup(apiKey string) error {
 		},
 	}
 
+	if config.Gemini.StructuredOutput {
+		setupStructuredModel(model)
+	}
+
 	return nil
 }
 
+// geminiModelName returns the configured Gemini model, or the default one if none is configured
+func geminiModelName() string {
+	modelName := strings.TrimSpace(config.Gemini.Model)
+	if modelName == "" {
+		log.Printf("No Gemini model configured, using %s", DefaultGeminiModel)
+		return DefaultGeminiModel
+	}
+	return modelName
+}
+
 // mapHarmBlock maps the TOML string values to the genai package constants
 func mapHarmBlock(threshold string) genai.HarmBlockThreshold {
 	switch threshold {
@@ -435,13 +705,31 @@ func mapHarmBlock(threshold string) genai.HarmBlockThreshold {
 }
 
 // handleMention processes incoming mentions and generates alt-text descriptions
-func handleMention(c *mastodon.Client, notification *mastodon.Notification) {
-	if isDNI(&notification.Account) {
+func handleMention(c MastodonClient, notification *mastodon.Notification) {
+	if isPaused() {
+		return
+	}
+
+	if isDNI(c, &notification.Account) || blockedBy.Contains(c, notification.Account.ID) {
+		return
+	}
+
+	if handleInfoCommand(c, notification) {
+		return
+	}
+
+	if handleStyleCommand(c, notification) {
+		return
+	}
+
+	if maxDepth := config.Behavior.MaxReplyDepth; maxDepth > 0 && replyDepth(c, notification.Status, maxDepth) > maxDepth {
+		log.Printf("Ignoring mention by %s in a thread deeper than %d replies", notification.Account.Acct, maxDepth)
 		return
 	}
 
 	originalStatus := notification.Status.InReplyToID
 	if originalStatus == nil {
+		handleDirectImageURL(c, notification)
 		return
 	}
 
@@ -456,34 +744,93 @@ func handleMention(c *mastodon.Client, notification *mastodon.Notification) {
 		log.Printf("Unexpected type for InReplyToID: %T", originalStatus)
 	}
 
-	status, err := c.GetStatus(ctx, originalStatusID)
+	status, err := fetchStatus(ctx, c, originalStatusID)
 	if err != nil {
+		if isStatusGone(err) {
+			log.Printf("Original status %s is no longer available: %v", originalStatusID, err)
+			return
+		}
 		log.Printf("Error fetching original status: %v", err)
 		return
 	}
 
+	// A post tagged to opt out is only described when its author asks for it
+	if status.Account.ID != notification.Account.ID && isPostDNI(status) {
+		log.Printf("Ignoring mention by %s, post %s opted out with a DNI tag", notification.Account.Acct, status.ID)
+		return
+	}
+
+	attachLinkedImages(status)
+	attachCardImage(status)
+
+	if config.ImageProcessing.DescribeEmojis && hasCommandWord(notification.Status.Content, "emoji") {
+		describeEmojis(c, status, notification)
+		return
+	}
+
 	//Check if the original status has any media attachments
 	if len(status.MediaAttachments) == 0 {
 		return
 	}
 
+	// Check if only specific attachments should be described
+	selection, ok := parseAttachmentSelection(notification.Status.Content)
+	if !ok || !validateAttachmentSelection(selection, len(status.MediaAttachments)) {
+		message := fmt.Sprintf("@%s "+getLocalizedString(notification.Status.Language, "invalidAttachmentSelection", "response"), notification.Account.Acct, len(status.MediaAttachments))
+		_, err := postStatus(ctx, c, &mastodon.Toot{
+			Status:      message,
+			InReplyToID: notification.Status.ID,
+			Visibility:  notification.Status.Visibility,
+			Language:    notification.Status.Language,
+		})
+		if err != nil {
+			log.Printf("Error posting invalid attachment selection reply: %v", err)
+		}
+		return
+	}
+
+	// Existing alt-texts are normally left alone, on request an improved version is suggested in a reply.
+	// Like a description, a suggestion for someone else's post needs the consent of its author.
+	if config.ImageProcessing.ImproveAltText && hasCommandWord(notification.Status.Content, "improve") {
+		if indexes := improvableAttachments(status, selection); len(indexes) > 0 {
+			if status.Account.ID != notification.Account.ID && settingsFor(c).AskForConsent {
+				log.Printf("Ignoring improve request by %s for a post of %s, who didn't consent", notification.Account.Acct, status.Account.Acct)
+				return
+			}
+			suggestImprovements(c, status, notification, indexes)
+			return
+		}
+	}
+
+	// Point further requesters to the existing description instead of describing the post again
+	if len(selection) == 0 && replyWithExistingDescription(c, status.ID, notification) {
+		return
+	}
+
+	// Another alt-text bot, e.g. on a different instance, may have answered already
+	if len(selection) == 0 {
+		if otherReply := findOtherBotReply(c, status); otherReply != nil && pointToDescription(c, otherReply.URL, notification) {
+			return
+		}
+	}
+
 	// Check if the person who mentioned the bot is the OP
 	if status.Account.ID == notification.Account.ID {
-		generateAndPostAltText(c, status, notification.Status.ID)
-	} else if !config.Behavior.AskForConsent {
-		generateAndPostAltText(c, status, notification.Status.ID)
+		generateAndPostAltText(c, status, notification.Status.ID, selection)
+	} else if !settingsFor(c).AskForConsent {
+		generateAndPostAltText(c, status, notification.Status.ID, selection)
 	} else {
-		requestConsent(c, status, notification)
+		requestConsent(c, status, notification, selection)
 	}
 }
 
 // requestConsent asks the original poster for consent to generate alt text
-func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *mastodon.Notification) {
+func requestConsent(c MastodonClient, status *mastodon.Status, notification *mastodon.Notification, selection []int) {
 	// Check if every image in the post already has a Alt text
 	hasAltText := true
 
 	for _, attachment := range status.MediaAttachments {
-		if attachment.Description == "" && (attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" || attachment.Type == "audio") && videoAudioProcessingCapability)) {
+		if attachment.Description == "" && isMediaTypeAllowed(attachment) && (attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" || attachment.Type == "audio") && videoAudioProcessingCapability) || isDescribablePDF(attachment)) {
 			hasAltText = false
 		}
 	}
@@ -493,17 +840,25 @@ func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *m
 	}
 
 	// Check if the original poster has already been asked for consent
-	if _, ok := consentRequests[status.ID]; ok {
-		return
+	consentMutex.Lock()
+	_, asked := consentRequests[accountKey(c, status.ID)]
+	if !asked {
+		consentRequests[accountKey(c, status.ID)] = ConsentRequest{
+			RequestID: notification.Status.ID,
+			PosterID:  status.Account.ID,
+			Timestamp: time.Now(),
+			Selection: selection,
+		}
 	}
+	consentMutex.Unlock()
 
-	consentRequests[status.ID] = ConsentRequest{
-		RequestID: notification.Status.ID,
-		Timestamp: time.Now(),
+	if asked {
+		return
 	}
+	metricsManager.logConsentRequested(string(status.Account.ID))
 
 	message := fmt.Sprintf("@%s "+getLocalizedString(notification.Status.Language, "consentRequest", "response"), status.Account.Acct, notification.Account.Acct)
-	_, err := c.PostStatus(ctx, &mastodon.Toot{
+	_, err := postStatus(ctx, c, &mastodon.Toot{
 		Status:      message,
 		InReplyToID: status.ID,
 		Visibility:  status.Visibility,
@@ -519,21 +874,28 @@ func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *m
 }
 
 // handleConsentResponse processes the consent response from the original poster
-func handleConsentResponse(c *mastodon.Client, ID mastodon.ID, consentStatus *mastodon.Status) {
+func handleConsentResponse(c MastodonClient, ID mastodon.ID, consentStatus *mastodon.Status) {
+	if isPaused() {
+		return
+	}
+
 	originalStatusID := ID
-	status, err := c.GetStatus(ctx, originalStatusID)
+	status, err := fetchStatus(ctx, c, originalStatusID)
 	if err != nil {
 		log.Printf("Error fetching original status for ID %s: %v", originalStatusID, err)
 		return
 	}
 
-	if consentStatus.Account.Acct != status.Account.Acct {
+	if consentStatus.Account.ID != status.Account.ID {
 		log.Printf("Unauthorized consent response from: %s, expected: %s", consentStatus.Account.Acct, status.Account.Acct)
 		return
 	}
 
+	attachLinkedImages(status)
+	attachCardImage(status)
+
 	// Clean up HTML content to extract plain text
-	plainTextContent := stripHTMLTags(consentStatus.Content)
+	plainTextContent := mentionText(consentStatus.Content)
 	log.Printf("Cleaned consent content: %q from user: %s", plainTextContent, consentStatus.Account.Acct)
 
 	if plainTextContent == "" {
@@ -547,31 +909,66 @@ func handleConsentResponse(c *mastodon.Client, ID mastodon.ID, consentStatus *ma
 		log.Printf("Empty content after stripping HTML.")
 		return
 	}
-	lastWord := strings.ToLower(consentResponse[len(consentResponse)-1])
+	lastWord := strings.ToLower(strings.Trim(consentResponse[len(consentResponse)-1], ".,!?¡¿\"'"))
 	log.Printf("Extracted last word: %q from cleaned content", lastWord)
 
+	// Take the request out first, so a second answer arriving meanwhile isn't acted on as well
+	consentMutex.Lock()
+	request, pending := consentRequests[accountKey(c, originalStatusID)]
+	delete(consentRequests, accountKey(c, originalStatusID))
+	consentMutex.Unlock()
+
+	if !pending {
+		return
+	}
+	log.Printf("Removed consent request for ID %s", originalStatusID)
+
 	if lastWord == "y" || lastWord == "yes" {
 		log.Printf("Consent granted by the original poster: %s", consentStatus.Account.Acct)
-		generateAndPostAltText(c, status, consentStatus.ID)
+		generateAndPostAltText(c, status, consentStatus.ID, request.Selection)
 		metricsManager.logConsentRequest(string(status.Account.ID), true)
 	} else {
 		log.Printf("Consent denied based on last word: %q from user: %s", lastWord, consentStatus.Account.Acct)
 		metricsManager.logConsentRequest(string(status.Account.ID), false)
-	}
 
-	delete(consentRequests, originalStatusID)
-	log.Printf("Removed consent request for ID %s after processing", originalStatusID)
+		if config.Behavior.AcknowledgeConsentDenial {
+			acknowledgeConsentDenial(c, consentStatus, request)
+		}
+	}
 
 	if err := saveConsentRequestsToFile("consent_requests.json"); err != nil {
 		log.Printf("Error saving consent requests: %v", err)
 	}
 }
 
+// acknowledgeConsentDenial lets the original poster and the requester know that the decision was respected
+func acknowledgeConsentDenial(c MastodonClient, consentStatus *mastodon.Status, request ConsentRequest) {
+	mentions := "@" + consentStatus.Account.Acct
+
+	requestStatus, err := fetchStatus(ctx, c, request.RequestID)
+	if err != nil {
+		log.Printf("Error fetching consent request status %s: %v", request.RequestID, err)
+	} else if requestStatus.Account.ID != consentStatus.Account.ID && !isDNI(c, &requestStatus.Account) {
+		mentions += " @" + requestStatus.Account.Acct
+	}
+
+	message := fmt.Sprintf("%s %s", mentions, getLocalizedString(consentStatus.Language, "consentDenied", "response"))
+	_, err = postStatus(ctx, c, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: consentStatus.ID,
+		Visibility:  consentStatus.Visibility,
+		Language:    consentStatus.Language,
+	})
+	if err != nil {
+		log.Printf("Error posting consent denial acknowledgement: %v", err)
+	}
+}
+
 // isDNI checks if an account meets the Do Not Interact (DNI) conditions
-func isDNI(account *mastodon.Account) bool {
+func isDNI(c MastodonClient, account *mastodon.Account) bool {
 	dniList := config.DNI.Tags
 
-	if account.Acct == config.Server.Username {
+	if isSelf(c, account) {
 		return true
 	} else if account.Bot && config.DNI.IgnoreBots {
 		return true
@@ -586,30 +983,182 @@ func isDNI(account *mastodon.Account) bool {
 	return false
 }
 
+// isPostDNI reports whether the post opted out of descriptions with one of the dni.post_tags,
+// either as a hashtag or anywhere in its text
+func isPostDNI(status *mastodon.Status) bool {
+	if len(config.DNI.PostTags) == 0 {
+		return false
+	}
+
+	content := strings.ToLower(stripHTMLTags(status.Content))
+	for _, tag := range config.DNI.PostTags {
+		name := strings.TrimPrefix(tag, "#")
+		for _, statusTag := range status.Tags {
+			if strings.EqualFold(statusTag.Name, name) {
+				return true
+			}
+		}
+		for _, word := range strings.Fields(content) {
+			if strings.TrimRight(word, ".,!?:;") == "#"+strings.ToLower(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isSelf reports whether the account, as seen by the client's instance, is one of the bot's own accounts
+func isSelf(c MastodonClient, account *mastodon.Account) bool {
+	return botAccountIDs[instanceKey(c, account.ID)]
+}
+
 // handleFollow processes new follows and follows back
-func handleFollow(c *mastodon.Client, notification *mastodon.Notification) {
-	if config.Behavior.FollowBack {
-		_, err := c.AccountFollow(ctx, notification.Account.ID)
-		if err != nil {
-			log.Printf("Error following back: %v", err)
+func handleFollow(c MastodonClient, notification *mastodon.Notification) {
+	markFollower(c, notification.Account.ID)
+
+	if config.Behavior.FollowBack && shouldFollowBack(c, &notification.Account) {
+		if !followBackLimit.TakeOrQueue(c, notification.Account.ID) {
+			log.Printf("Daily follow-back limit reached or followers waiting, queueing %s", notification.Account.Acct)
 			return
 		}
-		LogEvent("new_follower")
-		metricsManager.logFollow(string(notification.Account.ID))
-		fmt.Printf("Followed back: %s\n", notification.Account.Acct)
+		followBack(c, &notification.Account)
+	}
+}
+
+// followBack follows the account and welcomes it
+func followBack(c MastodonClient, account *mastodon.Account) {
+	_, err := c.AccountFollow(ctx, account.ID)
+	if err != nil {
+		log.Printf("Error following back: %v", err)
+		return
+	}
+	LogEvent("new_follower")
+	metricsManager.logFollow(string(account.ID))
+	fmt.Printf("Followed back: %s\n", account.Acct)
+
+	if config.Behavior.WelcomeMessage {
+		sendWelcomeMessage(c, account)
 	}
 }
 
+// welcomedAccounts remembers who already received the welcome message, by accountKey
+var welcomedAccounts = make(map[string]bool)
+var welcomedMutex sync.Mutex
+
+// sendWelcomeMessage sends a direct message explaining how to use the bot, once per account
+func sendWelcomeMessage(c MastodonClient, account *mastodon.Account) {
+	if isDNI(c, account) || isPaused() {
+		return
+	}
+
+	key := accountKey(c, account.ID)
+	welcomedMutex.Lock()
+	if welcomedAccounts[key] {
+		welcomedMutex.Unlock()
+		return
+	}
+	welcomedAccounts[key] = true
+	welcomedMutex.Unlock()
+
+	lang := settingsFor(c).DefaultLanguage
+	message := fmt.Sprintf("@%s %s", account.Acct, getLocalizedString(lang, "welcomeMessage", "response"))
+	if len(config.DNI.Tags) > 0 {
+		message += " " + fmt.Sprintf(getLocalizedString(lang, "welcomeOptOut", "response"), strings.Join(config.DNI.Tags, ", "))
+	}
+
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:     message,
+		Visibility: "direct",
+	})
+	if err != nil {
+		log.Printf("Error sending welcome message to %s: %v", account.Acct, err)
+	}
+}
+
+// shouldFollowBack applies the configured follow-back criteria to a new follower
+func shouldFollowBack(c MastodonClient, account *mastodon.Account) bool {
+	if config.Behavior.FollowBackSkipBots && account.Bot {
+		return false
+	}
+
+	if config.Behavior.FollowBackSkipDNI && isDNI(c, account) {
+		return false
+	}
+
+	if minAge := config.Behavior.FollowBackMinAccountAgeDays; minAge > 0 && time.Since(account.CreatedAt) < time.Duration(minAge)*24*time.Hour {
+		return false
+	}
+
+	return true
+}
+
 // handleUpdate processes new posts and generates alt-text descriptions if missing
-func handleUpdate(c *mastodon.Client, status *mastodon.Status) {
-	if status.Account.Acct == config.Server.Username {
+func handleUpdate(c MastodonClient, status *mastodon.Status) {
+	if isPaused() {
+		return
+	}
+
+	// A boost, including one by the bot itself, carries the media of someone else's post,
+	// so it's never described automatically
+	if status.Reblog != nil {
+		if isSelf(c, &status.Account) {
+			log.Printf("Ignoring update for the bot's own boost %s", status.ID)
+		}
 		return
 	}
 
+	if isSelf(c, &status.Account) || !matchesHashtagFilters(status) || blockedBy.Contains(c, status.Account.ID) {
+		return
+	}
+
+	if isPostDNI(status) {
+		log.Printf("Not describing post %s, it opted out with a DNI tag", status.ID)
+		return
+	}
+
+	// Edits and federation backfill can bring up old posts, which shouldn't be described out of the blue
+	if maxAge := config.Behavior.MaxPostAgeMinutes; maxAge > 0 && time.Since(status.CreatedAt) > time.Duration(maxAge)*time.Minute {
+		log.Printf("Not describing post %s, it is older than %d minutes", status.ID, maxAge)
+		return
+	}
+
+	// Replies below the bot's own posts are only described when someone asks for it
+	if hops := config.Behavior.SkipBelowOwnReplies; hops > 0 && isBelowOwnReply(c, status, hops) {
+		log.Printf("Not describing post %s, it is in a thread below one of the bot's replies", status.ID)
+		return
+	}
+
+	// Accounts that almost always write their own alt-text are left alone when they forget it once
+	if config.Behavior.EnableAdaptiveSkip {
+		diligent := altTextStats.IsDiligent(string(status.Account.ID))
+		human, missing := countAltTexts(status)
+		if err := altTextStats.Record(string(status.Account.ID), human, missing); err != nil {
+			log.Printf("Error saving alt-text stats: %v", err)
+		}
+		if diligent && missing > 0 {
+			log.Printf("Not describing post %s, @%s usually writes their own alt-text", status.ID, status.Account.Acct)
+			return
+		}
+	}
+
 	for _, attachment := range status.MediaAttachments {
-		if attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" || attachment.Type == "audio") && videoAudioProcessingCapability) {
+		if !isMediaTypeAllowed(attachment) {
+			continue
+		}
+
+		if attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" || attachment.Type == "audio") && videoAudioProcessingCapability) || isDescribablePDF(attachment) {
 			if attachment.Description == "" {
-				generateAndPostAltText(c, status, status.ID)
+				// Only describe posts of accounts that still follow the bot
+				if isStillFollower(c, &status.Account) {
+					if delay := config.Behavior.AutoDescribeDelaySeconds; delay > 0 {
+						scheduleAutoDescribe(c, status.ID, time.Duration(delay)*time.Second)
+					} else if otherReply := findOtherBotReply(c, status); otherReply != nil {
+						log.Printf("Not describing post %s, @%s already answered", status.ID, otherReply.Account.Acct)
+					} else {
+						generateAndPostAltText(c, status, status.ID, nil)
+					}
+				}
 				break
 			} else {
 				LogEventWithUsername("human_written_alt_text", status.Account.Acct)
@@ -618,23 +1167,80 @@ func handleUpdate(c *mastodon.Client, status *mastodon.Status) {
 	}
 }
 
-// generateAndPostAltText generates alt-text for images and posts it as a reply
-func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyToID mastodon.ID) {
-	replyPost, err := c.GetStatus(ctx, replyToID)
+// matchesHashtagFilters applies the trigger and exclude hashtags to posts that are described automatically
+func matchesHashtagFilters(status *mastodon.Status) bool {
+	hasTag := func(tags []string) bool {
+		for _, statusTag := range status.Tags {
+			for _, tag := range tags {
+				if strings.EqualFold(statusTag.Name, strings.TrimPrefix(tag, "#")) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if hasTag(config.Behavior.ExcludeHashtags) {
+		return false
+	}
+	return len(config.Behavior.TriggerHashtags) == 0 || hasTag(config.Behavior.TriggerHashtags)
+}
+
+// generateAndPostAltText generates alt-text for images and posts it as a reply.
+// If selection is non-empty, only the attachments at those 1-based indices are described.
+func generateAndPostAltText(c MastodonClient, status *mastodon.Status, replyToID mastodon.ID, selection []int) {
+	generateAndPostAltTextWithHint(c, status, replyToID, selection, "")
+}
+
+// generateAndPostAltTextWithHint works like generateAndPostAltText, adding the hint to the prompt of every image
+func generateAndPostAltTextWithHint(c MastodonClient, status *mastodon.Status, replyToID mastodon.ID, selection []int, hint string) {
+	replyPost, err := fetchStatus(ctx, c, replyToID)
 	if err != nil {
+		if isStatusGone(err) {
+			log.Printf("Status %s was deleted before it could be described: %v", replyToID, err)
+			return
+		}
 		log.Printf("Error fetching reply status: %v", err)
 		return
 	}
 
 	metricsManager.logRequest(string(replyPost.Account.ID))
 
+	// Posts in languages without localizations are answered in the account's default language
+	replyPost.Language = accountLanguage(c, replyPost.Language)
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var responses []string
+	retries := newRetryBudget()
 	altTextGenerated := false
 	altTextAlreadyExists := false
 
-	for _, attachment := range status.MediaAttachments {
+	// Every described attachment counts against the rate limit. The whole post is reserved up front,
+	// so it's either described completely or answered with a single rate limit message.
+	attachments := status.MediaAttachments
+	var mediaTypes []string
+	for i, attachment := range attachments {
+		if isAttachmentSelected(selection, i) && isMediaTypeAllowed(attachment) && needsGeneration(attachment) {
+			mediaTypes = append(mediaTypes, attachment.Type)
+		}
+	}
+
+	if len(mediaTypes) > 0 && !rateLimiter.IncrementAll(c, string(replyPost.Account.ID), mediaTypes) {
+		log.Printf("User @%s has exceeded their rate limit", replyPost.Account.Acct)
+		metricsManager.logRateLimitHit(string(replyPost.Account.ID))
+		if config.RateLimit.ExceededReply == "silent" {
+			return
+		}
+		responses = append(responses, getLocalizedString(replyPost.Language, "rateLimitExceeded", "response"))
+		attachments = nil
+	}
+
+	for i, attachment := range attachments {
+		if !isAttachmentSelected(selection, i) || !isMediaTypeAllowed(attachment) {
+			continue
+		}
+
 		wg.Add(1)
 		go func(attachment mastodon.Attachment) {
 			defer wg.Done()
@@ -643,22 +1249,32 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 
 			start := time.Now()
 
-			// Check if the user has exceeded their rate limit
-			if !rateLimiter.Increment(c, string(replyPost.Account.ID)) {
-				log.Printf("User @%s has exceeded their rate limit", replyPost.Account.Acct)
-				metricsManager.logRateLimitHit(string(replyPost.Account.ID))
-				mu.Lock()
-				responses = append(responses, getLocalizedString(replyPost.Language, "altTextError", "response"))
-				mu.Unlock()
-				return
-			}
-
 			if attachment.Type == "image" && attachment.Description == "" {
-				altText, err = generateImageAltText(attachment.URL, replyPost.Language)
+				style := stylePreferences.Get(string(status.Account.ID))
+				if hint != "" {
+					style = strings.TrimSpace(style + " " + hint)
+				}
+				altText, err = withAttachmentTimeout(func() (string, error) {
+					return generateInLanguages(c, replyPost, attachment, func(imageURL, remoteURL, lang string) (string, error) {
+						return generateImageAltText(imageURL, remoteURL, lang, style, config.ImageProcessing.DescribePalette, retries)
+					})
+				})
 			} else if (attachment.Type == "video" || attachment.Type == "gifv") && videoAudioProcessingCapability && attachment.Description == "" {
-				altText, err = generateVideoAltText(attachment.URL, replyPost.Language)
+				generate := generateVideoAltText
+				if attachment.Type == "gifv" {
+					generate = generateAnimationAltText
+				}
+				altText, err = withAttachmentTimeout(func() (string, error) {
+					return generateInLanguages(c, replyPost, attachment, generate)
+				})
 			} else if attachment.Type == "audio" && videoAudioProcessingCapability && attachment.Description == "" {
-				altText, err = generateAudioAltText(attachment.URL, replyPost.Language)
+				altText, err = withAttachmentTimeout(func() (string, error) {
+					return generateInLanguages(c, replyPost, attachment, generateAudioAltText)
+				})
+			} else if isDescribablePDF(attachment) && attachment.Description == "" {
+				altText, err = withAttachmentTimeout(func() (string, error) {
+					return generateInLanguages(c, replyPost, attachment, generatePDFAltText)
+				})
 			} else if attachment.Description != "" {
 				if !altTextGenerated && !altTextAlreadyExists {
 					mu.Lock()
@@ -674,240 +1290,542 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 				return
 			}
 
-			if err != nil {
-				log.Printf("Error generating alt-text: %v", err)
-				altText = getLocalizedString(replyPost.Language, "altTextError", "response")
-			} else if altText == "" {
-				log.Printf("Error generating alt-text: Empty response")
-				altText = getLocalizedString(replyPost.Language, "altTextError", "response")
-			}
+			if errors.Is(err, ErrDeniedImage) {
+				log.Printf("Skipping denied image: %s", loggableURL(attachment.URL))
+				return
+			}
+
+			elapsed := time.Since(start).Milliseconds()
+
+			if err == nil && altText == "" {
+				err = ErrEmptyResponse
+			}
+			if err != nil {
+				genErr := categorizeError(err)
+				log.Printf("Error generating alt-text for %s: %v", loggableURL(attachment.URL), genErr)
+				metricsManager.logFailedGeneration(string(replyPost.Account.ID), attachment.Type, genErr.Category)
+				recordDeadLetter(c, status, replyToID, attachment, genErr)
+				if !config.Behavior.ReplyOnError {
+					return
+				}
+				altText = getLocalizedString(replyPost.Language, genErr.messageKey(attachment.Type), "response")
+			} else {
+				metricsManager.logSuccessfulGeneration(string(replyPost.Account.ID), attachment.Type, elapsed)
+			}
+
+			mu.Lock()
+			responses = append(responses, altText)
+			mu.Unlock()
+			altTextGenerated = true
+		}(attachment)
+	}
+
+	wg.Wait()
+
+	// Nothing to reply with, e.g. when every attachment was skipped
+	if len(responses) == 0 {
+		return
+	}
+
+	// Combine all responses with a separator, or give every description its own reply
+	parts := []string{strings.Join(responses, "\n―\n")}
+	if config.Behavior.OneReplyPerImage {
+		parts = responses
+	}
+
+	// Prepare the content warning for the reply
+	contentWarning := status.SpoilerText
+	if contentWarning != "" && !strings.HasPrefix(contentWarning, "re:") {
+		contentWarning = "re: " + contentWarning
+	}
+
+	// In standalone mode the description is posted as a new status that links to the original post
+	standalone := strings.ToLower(config.Behavior.DeliveryMode) == "standalone"
+	if standalone {
+		parts[0] = fmt.Sprintf(getLocalizedString(replyPost.Language, "standaloneDescription", "response"), status.URL) + "\n\n" + parts[0]
+	}
+
+	visibility := mapReplyVisibility(settingsFor(c).ReplyVisibility, replyPost.Visibility)
+
+	// Wait a little before replying so the bot doesn't look spammy
+	if !waitBeforeReply(ctx) {
+		log.Printf("Context cancelled while waiting to reply to %s", replyToID)
+		return
+	}
+
+	// Every reply after the first is threaded below the previous one
+	var firstReply *mastodon.Status
+	inReplyToID := replyToID
+	for _, part := range parts {
+		part = renderReplyTemplate(config.Behavior.ReplyTemplate, "@"+replyPost.Account.Acct, part, providerFooter(c, replyPost.Language))
+		if part == "" {
+			continue
+		}
+
+		toot := &mastodon.Toot{
+			Status:      part,
+			InReplyToID: inReplyToID,
+			Visibility:  visibility,
+			Language:    replyPost.Language,
+			SpoilerText: contentWarning,
+		}
+
+		// Standalone posts stay out of the original thread and never show up on the public timeline
+		if standalone {
+			if firstReply == nil {
+				toot.InReplyToID = ""
+			}
+			if toot.Visibility == "public" {
+				toot.Visibility = "unlisted"
+			}
+		}
+
+		reply, err := postStatus(ctx, c, toot)
+		if err != nil {
+			if isForbidden(err) {
+				log.Printf("Not allowed to reply to @%s, the bot may be blocked or limited: %v", replyPost.Account.Acct, err)
+				if config.Behavior.SkipBlockingAccounts {
+					if err := blockedBy.Add(c, replyPost.Account.ID); err != nil {
+						log.Printf("Error saving blocked accounts: %v", err)
+					}
+				}
+			} else {
+				log.Printf("Error posting reply: %v", err)
+			}
+			break
+		}
+
+		if firstReply == nil {
+			firstReply = reply
+		}
+		inReplyToID = reply.ID
+	}
+
+	if firstReply == nil {
+		return
+	}
+
+	// The reminders are sent by the primary account, which can't see the statuses of other instances by ID
+	if config.AltTextReminders.Enabled && isPrimaryAccount(c) {
+		queuePostForAltTextCheck(status, string(replyPost.Account.ID))
+	}
+
+	// Track the reply with a timestamp
+	mapMutex.Lock()
+	replyMap[accountKey(c, status.ID)] = ReplyInfo{OriginalID: status.ID, ReplyID: firstReply.ID, ReplyURL: firstReply.URL, RequesterID: replyPost.Account.ID, Timestamp: time.Now()}
+	mapMutex.Unlock()
+}
+
+// defaultReplyTemplate is used when no reply_template is configured
+const defaultReplyTemplate = "{{mention}} {{descriptions}}\n\n{{footer}}"
+
+// providerFooter returns the localized footer naming the account that replies and the LLM provider
+func providerFooter(c MastodonClient, lang string) string {
+	providerMessage := getLocalizedString(lang, "providedByMessage", "response")
+	return fmt.Sprintf(providerMessage, accountUsername(c), cases.Title(language.AmericanEnglish).String(config.LLM.Provider))
+}
+
+// renderReplyTemplate assembles the reply from the template's placeholders
+func renderReplyTemplate(template, mention, descriptions, footer string) string {
+	if strings.TrimSpace(template) == "" {
+		template = defaultReplyTemplate
+	}
+
+	replacer := strings.NewReplacer(
+		"{{mention}}", mention,
+		"{{descriptions}}", descriptions,
+		"{{footer}}", footer,
+	)
+	return strings.TrimSpace(replacer.Replace(template))
+}
+
+// needsGeneration reports whether a description has to be generated for the attachment
+func needsGeneration(attachment mastodon.Attachment) bool {
+	if attachment.Description != "" {
+		return false
+	}
+	return attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" || attachment.Type == "audio") && videoAudioProcessingCapability) || isDescribablePDF(attachment)
+}
+
+// ErrAttachmentTimeout is returned when describing a single attachment takes longer than configured
+var ErrAttachmentTimeout = errors.New("attachment processing timed out")
+
+// withAttachmentTimeout runs the download and description of one attachment, giving up after the
+// configured per-attachment timeout. Provider calls don't take a context, so a generation that
+// times out finishes in the background and its result is discarded.
+func withAttachmentTimeout(generate func() (string, error)) (string, error) {
+	timeout := time.Duration(config.LLM.PerAttachmentTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		return generate()
+	}
+
+	type result struct {
+		altText string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		altText, err := generate()
+		done <- result{altText, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.altText, r.err
+	case <-time.After(timeout):
+		return "", ErrAttachmentTimeout
+	}
+}
 
-			elapsed := time.Since(start).Milliseconds()
+// generateInLanguages runs the generator for the language of the post and every additionally configured language.
+// When more than one description is produced, each is labelled with the name of its language.
+func generateInLanguages(c MastodonClient, replyPost *mastodon.Status, attachment mastodon.Attachment, generate func(mediaURL, remoteURL, lang string) (string, error)) (string, error) {
+	languages := descriptionLanguages(replyPost.Language)
+	if len(languages) == 1 {
+		altText, err := generate(attachment.URL, attachment.RemoteURL, languages[0])
+		if err != nil || altText == "" {
+			return altText, err
+		}
+		return truncateAltText(frameDescription(altText, languages[0]), config.LLM.MaxAltChars), nil
+	}
 
-			mu.Lock()
-			responses = append(responses, altText)
-			mu.Unlock()
-			altTextGenerated = true
+	var sections []string
+	for i, lang := range languages {
+		// Every additional language is another model call, so it counts against the rate limit
+		if i > 0 && !rateLimiter.Increment(c, string(replyPost.Account.ID), attachment.Type) {
+			log.Printf("User @%s has exceeded their rate limit, skipping remaining languages", replyPost.Account.Acct)
+			break
+		}
 
-			metricsManager.logSuccessfulGeneration(string(replyPost.Account.ID), attachment.Type, elapsed)
-		}(attachment)
+		altText, err := generate(attachment.URL, attachment.RemoteURL, lang)
+		if err != nil || altText == "" {
+			if i == 0 {
+				return altText, err
+			}
+			log.Printf("Error generating alt-text in %s: %v", lang, err)
+			continue
+		}
+
+		section := getLocalizedString(lang, "languageName", "response") + ": " + frameDescription(altText, lang)
+		sections = append(sections, truncateAltText(section, config.LLM.MaxAltChars))
 	}
 
-	wg.Wait()
+	return strings.Join(sections, "\n\n"), nil
+}
 
-	// Combine all responses with a separator
-	combinedResponse := strings.Join(responses, "\n―\n")
+// frameDescription wraps a description with the prefix and suffix of its language, e.g. a leading "Bild:" in German.
+// Descriptions are truncated only after framing, so the prefix and suffix count against the length limit.
+func frameDescription(altText, lang string) string {
+	prefix := getLocalizedString(lang, "altTextPrefix", "response")
+	suffix := getLocalizedString(lang, "altTextSuffix", "response")
+	return strings.TrimSpace(prefix + " " + altText + " " + suffix)
+}
 
-	// Prepare the content warning for the reply
-	contentWarning := status.SpoilerText
-	if contentWarning != "" && !strings.HasPrefix(contentWarning, "re:") {
-		contentWarning = "re: " + contentWarning
+// altTextErrorKey returns the localization key of the fallback message for a failed generation of the given media type
+func altTextErrorKey(mediaType string) string {
+	switch mediaType {
+	case "video", "gifv":
+		return "videoAltTextError"
+	case "audio":
+		return "audioAltTextError"
+	default:
+		return "altTextError"
 	}
+}
 
-	// Add mention to the original poster at the start
-	combinedResponse = fmt.Sprintf("@%s %s", replyPost.Account.Acct, combinedResponse)
-
-	providerMessage := getLocalizedString(replyPost.Language, "providedByMessage", "response")
-	combinedResponse = fmt.Sprintf("%s\n\n%s", combinedResponse, fmt.Sprintf(providerMessage, config.Server.Username, cases.Title(language.AmericanEnglish).String(config.LLM.Provider)))
-
-	// Post the combined response
-	if combinedResponse != "" {
-		visibility := replyPost.Visibility
-
-		// Map the visibility of the reply based on the original post and the bot's settings
-		switch strings.ToLower(config.Behavior.ReplyVisibility + "," + replyPost.Visibility) {
-		case "public,public":
-			visibility = "public"
-		case "public,unlisted":
-			visibility = "unlisted"
-		case "public,private":
-			visibility = "private"
-		case "public,direct":
-			visibility = "direct"
-		case "unlisted,public":
-			visibility = "unlisted"
-		case "unlisted,unlisted":
-			visibility = "unlisted"
-		case "unlisted,private":
-			visibility = "private"
-		case "unlisted,direct":
-			visibility = "direct"
-		case "private,public":
-			visibility = "private"
-		case "private,unlisted":
-			visibility = "private"
-		case "private,private":
-			visibility = "private"
-		case "private,direct":
-			visibility = "direct"
-		case "direct,public":
-			visibility = "direct"
-		case "direct,unlisted":
-			visibility = "direct"
-		case "direct,private":
-			visibility = "direct"
-		case "direct,direct":
-			visibility = "direct"
-		}
-
-		reply, err := c.PostStatus(ctx, &mastodon.Toot{
-			Status:      combinedResponse,
-			InReplyToID: replyToID,
-			Visibility:  visibility,
-			Language:    replyPost.Language,
-			SpoilerText: contentWarning,
-		})
+// replyJitter returns a random number in [0, n), replaceable to make the reply delay predictable
+var replyJitter = rand.Int63n
 
-		if err != nil {
-			log.Printf("Error posting reply: %v", err)
-		}
+// replyDelay returns a random delay between the configured minimum and maximum reply delay
+func replyDelay() time.Duration {
+	minDelay := config.Behavior.ReplyDelayMin
+	maxDelay := config.Behavior.ReplyDelayMax
+	if minDelay < 0 {
+		minDelay = 0
+	}
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
 
-		if config.AltTextReminders.Enabled {
-			queuePostForAltTextCheck(status, string(replyPost.Account.ID))
-		}
+	delay := time.Duration(minDelay) * time.Second
+	if maxDelay > minDelay {
+		delay += time.Duration(replyJitter(int64(time.Duration(maxDelay-minDelay) * time.Second)))
+	}
+	return delay
+}
 
-		// Track the reply with a timestamp
-		mapMutex.Lock()
-		replyMap[status.ID] = ReplyInfo{ReplyID: reply.ID, Timestamp: time.Now()}
-		mapMutex.Unlock()
+// waitBeforeReply sleeps for a jittered reply delay, returning false if the context is cancelled first
+func waitBeforeReply(ctx context.Context) bool {
+	delay := replyDelay()
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
-// downloadToTempFile downloads a file from a given URL and saves it to a temporary file.
+// downloadToTempFile downloads a file from a given URL, falling back to remoteURL, and saves it to a temporary file.
 // It returns the path to the temporary file.
-func downloadToTempFile(fileURL, prefix, extension string) (string, error) {
+func downloadToTempFile(fileURL, remoteURL, prefix, extension string) (string, error) {
 	// Download the file from the remote URL
-	resp, err := http.Get(fileURL)
+	fileData, err := fetchMedia(fileURL, remoteURL)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	// Check the Content-Length header
-	contentLength := resp.Header.Get("Content-Length")
-	if contentLength != "" {
-		size, err := strconv.ParseInt(contentLength, 10, 64)
-		if err == nil && size > int64(config.ImageProcessing.MaxSizeMB*1024*1024) {
-			return "", fmt.Errorf("file size exceeds maximum limit of %d MB", config.ImageProcessing.MaxSizeMB)
-		}
-	}
+	// Save the content to a temporary file, respecting the temporary storage limit
+	return createTrackedTempFile(prefix+"-*."+extension, fileData)
+}
 
-	// Read the file content
-	fileData, err := io.ReadAll(resp.Body)
+// generateImageAltText generates alt-text for an image using Gemini AI or Ollama.
+// Retries are taken from the retry budget of the post, a nil budget doesn't limit them.
+func generateImageAltText(imageURL string, remoteURL string, lang string, style string, withPalette bool, retries *retryBudget) (string, error) {
+	img, err := fetchMedia(imageURL, remoteURL)
 	if err != nil {
 		return "", err
 	}
 
-	// Create a temporary file to save the content
-	tmpFile, err := os.CreateTemp("", prefix+"-*."+extension)
-	if err != nil {
-		return "", err
+	// Refuse to describe known bad images
+	if imageDenyList.Contains(img) {
+		return "", ErrDeniedImage
 	}
-	defer tmpFile.Close()
 
-	// Write the file data to the temporary file
-	if _, err := tmpFile.Write(fileData); err != nil {
-		return "", err
+	// Images our decoders can't read may still be sent to the provider as they are
+	decoded, sourceFormat, decodeErr := decodeImage(img)
+	if decodeErr != nil {
+		if _, ok := originalImageFormat(img); !ok {
+			return "", decodeErr
+		}
 	}
 
-	return tmpFile.Name(), nil
-}
+	// The dominant colors are added below the description, for palettes and artwork
+	addPalette := func(altText string) string {
+		if !withPalette || altText == "" || decoded == nil {
+			return altText
+		}
+		if palette := describePalette(decoded, lang); palette != "" {
+			return altText + "\n\n" + palette
+		}
+		return altText
+	}
+
+	// The orientation, aspect ratio and format go in front of the description
+	addShape := func(altText string) string {
+		if !config.ImageProcessing.DescribeShape || altText == "" || decoded == nil {
+			return altText
+		}
+		width, height := sourceImageSize(img, decoded)
+		return describeImageShape(width, height, sourceFormat, lang) + " " + altText
+	}
+
+	// Reuse the description of a visually identical image if there is one
+	var imageHash uint64
+	cacheable := false
+	if config.Cache.Enabled {
+		imageHash, err = perceptualHash(img)
+		if err == nil {
+			cacheable = true
+			if altText, ok := descriptionCache.Get(imageHash, lang+"/"+style); ok {
+				log.Printf("Using cached alt-text for image: %s", loggableURL(imageURL))
+				return addShape(addPalette(flagLowQualityAltText(altText, lang))), nil
+			}
+		}
+	}
 
-// generateImageAltText generates alt-text for an image using Gemini AI or Ollama
-func generateImageAltText(imageURL string, lang string) (string, error) {
-	resp, err := http.Get(imageURL)
+	// Downscale the image to a smaller width using config settings, in the format the provider prefers
+	downscaledImg, format, err := downscaleOrOriginal(imageURL, img, decoded, sourceFormat, decodeErr)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	contentLength := resp.Header.Get("Content-Length")
-	if contentLength != "" {
-		size, err := strconv.ParseInt(contentLength, 10, 64)
-		if err == nil && size > int64(config.ImageProcessing.MaxSizeMB*1024*1024) {
-			return "", fmt.Errorf("file size exceeds maximum limit of %d MB", config.ImageProcessing.MaxSizeMB)
+	LogEvent("alt_text_generated")
+
+	// The description template asks for labeled parts, which are formatted right away
+	promptKey := "generateAltText"
+	if descriptionTemplateMode() != "" {
+		promptKey = "generateTemplatedAltText"
+	}
+	describe := func(prompt string) (string, error) {
+		altText, err := describeImage(prompt, downscaledImg, format)
+		return applyDescriptionTemplate(altText, lang), err
+	}
+
+	// Photos, screenshots and charts each get a hint on what matters in them
+	promptStyle := style
+	if config.ImageProcessing.ClassifyImages && decoded != nil {
+		if imageType := classifyImage(decoded); imageType != "" {
+			log.Printf("Image %s looks like a %s", loggableURL(imageURL), imageType)
+			promptStyle = strings.TrimSpace(style + " " + imageTypeHint(imageType, lang))
 		}
 	}
 
-	img, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	prompt := applyPromptStyle(localizedPrompt(lang, promptKey), promptStyle)
+
+	fmt.Println("Processing image: " + loggableURL(imageURL))
+
+	altText, err := describe(prompt)
+
+	// Empty or blocked responses often succeed on a second try with a simpler prompt
+	if config.LLM.RetryOnEmpty && isEmptyOrBlockedResponse(altText, err) && retries.take() {
+		log.Printf("Empty or blocked response for image %s, retrying with a simpler prompt", loggableURL(imageURL))
+		altText, err = describe(localizedPrompt(lang, "generateAltTextSimple"))
 	}
 
-	// Downscale the image to a smaller width using config settings
-	downscaledImg, format, err := downscaleImage(img, config.ImageProcessing.DownscaleWidth)
-	if err != nil {
-		return "", err
+	// Uselessly short descriptions get one more try, asking the model to look again
+	if config.LLM.RetryLowQuality && err == nil && isLowQualityAltText(altText) && retries.take() {
+		log.Printf("Low quality response for image %s, retrying", loggableURL(imageURL))
+		retryStyle := strings.TrimSpace(promptStyle + " " + getLocalizedString(promptLanguage(lang), "redoHint", "prompt"))
+		if retryText, retryErr := describe(applyPromptStyle(localizedPrompt(lang, promptKey), retryStyle)); retryErr == nil && retryText != "" {
+			altText = retryText
+		}
 	}
 
-	LogEvent("alt_text_generated")
+	if err == nil && altText != "" && !isLowQualityAltText(altText) && cacheable {
+		descriptionCache.Put(imageHash, lang+"/"+style, altText)
+	}
 
-	prompt := getLocalizedString(lang, "generateAltText", "prompt")
+	return addShape(addPalette(flagLowQualityAltText(altText, lang))), err
+}
 
-	fmt.Println("Processing image: " + imageURL)
+// describeImage sends the image to the configured LLM provider
+func describeImage(prompt string, image []byte, format string) (string, error) {
+	provider, err := activeProvider("image")
+	if err != nil {
+		return "", err
+	}
+	return timeProviderCall(provider.Name(), "image", func() (string, error) {
+		return provider.DescribeImage(prompt, image, format)
+	})
+}
 
-	switch config.LLM.Provider {
-	case "gemini":
-		return GenerateImageAltWithGemini(prompt, downscaledImg, format)
-	case "ollama":
-		return GenerateImageAltWithOllama(prompt, downscaledImg, format)
-	default:
-		return "", fmt.Errorf("unsupported LLM provider: %s", config.LLM.Provider)
+// isEmptyOrBlockedResponse reports whether the provider returned nothing usable or blocked the request
+func isEmptyOrBlockedResponse(altText string, err error) bool {
+	if err != nil {
+		return errors.Is(err, ErrEmptyResponse) || errors.Is(err, ErrContentBlocked)
 	}
+	return strings.TrimSpace(altText) == ""
+}
+
+// generateVideoAltText generates alt-text for a video using the configured provider
+func generateVideoAltText(videoURL string, remoteURL string, lang string) (string, error) {
+	return describeVideoWithPrompt(videoURL, remoteURL, lang, "generateVideoAltText")
+}
+
+// generateAnimationAltText generates alt-text for a gifv attachment. Mastodon serves those
+// as silent, looping MP4 videos, which are described as animations instead of videos.
+func generateAnimationAltText(videoURL string, remoteURL string, lang string) (string, error) {
+	return describeVideoWithPrompt(videoURL, remoteURL, lang, "generateAnimationAltText")
 }
 
-// generateVideoAltText generates alt-text for a video using Gemini AI
-func generateVideoAltText(videoURL string, lang string) (string, error) {
-	prompt := getLocalizedString(lang, "generateVideoAltText", "prompt")
+// describeVideoWithPrompt downloads the video and describes it with the localized prompt
+func describeVideoWithPrompt(videoURL string, remoteURL string, lang string, promptKey string) (string, error) {
+	prompt := localizedPrompt(lang, promptKey)
 
-	fmt.Println("Processing video: " + videoURL)
+	fmt.Println("Processing video: " + loggableURL(videoURL))
 
 	// Use the helper function to download the video
-	videoFilePath, err := downloadToTempFile(videoURL, "video", "mp4")
+	videoFilePath, err := downloadToTempFile(videoURL, remoteURL, "video", "mp4")
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(videoFilePath) // Clean up the file afterwards
+	defer removeTempFile(videoFilePath) // Clean up the file afterwards
 
 	LogEvent("video_alt_text_generated")
 
-	// Pass the local temporary file path to GenerateVideoAltWithGemini
-	return GenerateVideoAltWithGemini(prompt, videoFilePath)
+	provider, err := activeProvider("video")
+	if err != nil {
+		return "", err
+	}
+	altText, err := timeProviderCall(provider.Name(), "video", func() (string, error) {
+		return provider.DescribeVideo(prompt, videoFilePath)
+	})
+	return flagLowQualityAltText(altText, lang), err
 }
 
-// generateAudioAltText generates alt-text for an audio file using Gemini AI
-func generateAudioAltText(audioURL string, lang string) (string, error) {
-	prompt := getLocalizedString(lang, "generateAudioAltText", "prompt")
+// generateAudioAltText generates alt-text for an audio file using the configured provider
+func generateAudioAltText(audioURL string, remoteURL string, lang string) (string, error) {
+	prompt := localizedPrompt(lang, "generateAudioAltText")
 
-	fmt.Println("Processing audio: " + audioURL)
+	fmt.Println("Processing audio: " + loggableURL(audioURL))
 
 	// Use the helper function to download the audio
-	audioFilePath, err := downloadToTempFile(audioURL, "audio", "mp3")
+	audioFilePath, err := downloadToTempFile(audioURL, remoteURL, "audio", "mp3")
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(audioFilePath) // Clean up the file afterwards
+	defer removeTempFile(audioFilePath) // Clean up the file afterwards
 
 	LogEvent("audio_alt_text_generated")
 
-	// Pass the local temporary file path to GenerateAudioAltWithGemini
-	return GenerateAudioAltWithGemini(prompt, audioFilePath)
+	provider, err := activeProvider("audio")
+	if err != nil {
+		return "", err
+	}
+	altText, err := timeProviderCall(provider.Name(), "audio", func() (string, error) {
+		return provider.DescribeAudio(prompt, audioFilePath)
+	})
+	return flagLowQualityAltText(altText, lang), err
+}
+
+// timeProviderCall runs a single LLM provider call, logging and recording how long it took
+func timeProviderCall(provider, mediaType string, call func() (string, error)) (string, error) {
+	start := time.Now()
+	altText, err := call()
+	elapsed := time.Since(start)
+
+	// Every provider's output is cleaned up the same way
+	if err == nil {
+		altText = postProcessAltText(altText)
+	}
+
+	// Refusals are failures, not descriptions
+	if err == nil && isRefusal(altText) {
+		err = fmt.Errorf("%w: the model refused with %q", ErrContentBlocked, altText)
+		altText = ""
+	}
+
+	log.Printf("%s took %v to describe %s (success: %v)", provider, elapsed, mediaType, err == nil)
+	metricsManager.logProviderLatency(provider, mediaType, elapsed.Milliseconds(), err == nil)
+
+	return altText, err
 }
 
 // Generate creates a response using the Gemini AI model
 func GenerateImageAltWithGemini(strPrompt string, image []byte, fileExtension string) (string, error) {
+	format, err := geminiImageFormat(fileExtension)
+	if err != nil {
+		return "", err
+	}
+
 	var parts []genai.Part
 
 	parts = append(parts, genai.Text(strPrompt))
-	parts = append(parts, genai.ImageData(fileExtension, image))
+	parts = append(parts, genai.ImageData(format, image))
 
 	fmt.Println("Generating content...")
 
-	resp, err := model.GenerateContent(ctx, parts...)
-	if err != nil {
-		return "", err
+	if config.Gemini.StructuredOutput {
+		text, err := geminiResult(structuredModel.GenerateContent(ctx, parts...))
+		if err != nil {
+			return "", err
+		}
+
+		description, err := parseStructuredDescription(text)
+		if err != nil {
+			return "", fmt.Errorf("error parsing structured response: %w", err)
+		}
+		return formatStructuredDescription(description, config.Gemini.StructuredTemplate), nil
 	}
-	return postProcessAltText(getResponse(resp)), nil
+
+	return geminiResult(model.GenerateContent(ctx, parts...))
 }
 
 // GenerateVideoAltWithGemini generates alt-text for a video using the Gemini AI model
@@ -942,13 +1860,7 @@ func GenerateVideoAltWithGemini(strPrompt string, videoFilePath string) (string,
 	}
 
 	// Generate content using the prompt
-	resp, err := model.GenerateContent(ctx, prompt...)
-	if err != nil {
-		return "", err
-	}
-
-	// Handle the response of generated text
-	return postProcessAltText(getResponse(resp)), nil
+	return geminiResult(model.GenerateContent(ctx, prompt...))
 }
 
 // GenerateAudioAltWithGemini generates alt-text for an audio file using the Gemini AI model
@@ -983,33 +1895,20 @@ func GenerateAudioAltWithGemini(strPrompt string, audioFilePath string) (string,
 	}
 
 	// Generate content using the prompt
-	resp, err := model.GenerateContent(ctx, prompt...)
-	if err != nil {
-		return "", err
-	}
-
-	// Handle the response of generated text
-	return postProcessAltText(getResponse(resp)), nil
+	return geminiResult(model.GenerateContent(ctx, prompt...))
 }
 
 // GenerateImageAltWithOllama generates alt-text using the Ollama model
 func GenerateImageAltWithOllama(strPrompt string, image []byte, fileExtension string) (string, error) {
 	// Save the image temporarily
-	tmpFile, err := os.CreateTemp("", "image.*."+fileExtension)
+	imagePath, err := createTrackedTempFile("image.*."+fileExtension, image)
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(tmpFile.Name())
-
-	if _, err := tmpFile.Write(image); err != nil {
-		return "", err
-	}
-	if err := tmpFile.Close(); err != nil {
-		return "", err
-	}
+	defer removeTempFile(imagePath)
 
 	// Run the Ollama command
-	return runOllamaCommand(strPrompt, tmpFile.Name(), config.LLM.OllamaModel)
+	return runOllamaCommand(strPrompt, imagePath, config.LLM.OllamaModel)
 }
 
 // runOllamaCommand runs the Ollama command to generate alt-text for an image
@@ -1024,44 +1923,45 @@ func runOllamaCommand(prompt, imagePath, model string) (string, error) {
 		return "", err
 	}
 
+	if strings.TrimSpace(out.String()) == "" {
+		return "", ErrEmptyResponse
+	}
 	return out.String(), nil
 }
 
 // downscaleImage resizes the image to the specified width while maintaining the aspect ratio
 // and converts it to PNG or JPEG if it is in a different format.
-func downscaleImage(imgData []byte, width uint) ([]byte, string, error) {
-	img, format, err := decodeImage(imgData)
-	if err != nil {
-		return nil, "", err
-	}
+func downscaleImage(img image.Image, format string, width uint, uploadFormat string) ([]byte, string, error) {
+	var err error
 
 	// Resize the image to the specified width while maintaining the aspect ratio
 	resizedImg := resize.Resize(width, 0, img, resize.Lanczos3)
 
-	// Convert the image to PNG or JPEG if it is in a different format
-	var buf bytes.Buffer
 	switch format {
+	case "jpeg", "png", "gif", "bmp", "tiff", "webp":
+	default:
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	// Without a preferred upload format, JPEG stays JPEG and everything else is converted to PNG
+	if uploadFormat != "jpeg" && uploadFormat != "png" && uploadFormat != "webp" {
+		uploadFormat = "png"
+		if format == "jpeg" {
+			uploadFormat = "jpeg"
+		}
+	}
+
+	var buf bytes.Buffer
+	switch uploadFormat {
 	case "jpeg":
 		err = jpeg.Encode(&buf, resizedImg, nil)
-		format = "jpeg"
-	case "png":
-		err = png.Encode(&buf, resizedImg)
-		format = "png"
-	case "gif":
-		err = png.Encode(&buf, resizedImg)
-		format = "png"
-	case "bmp":
-		err = png.Encode(&buf, resizedImg)
-		format = "png"
-	case "tiff":
-		err = png.Encode(&buf, resizedImg)
-		format = "png"
 	case "webp":
-		err = png.Encode(&buf, resizedImg)
-		format = "png"
+		// Lossless WebP keeps the quality of PNG at a smaller size
+		err = nativewebp.Encode(&buf, resizedImg, nil)
 	default:
-		return nil, "", fmt.Errorf("unsupported image format: %s", format)
+		err = png.Encode(&buf, resizedImg)
 	}
+	format = uploadFormat
 
 	if err != nil {
 		return nil, "", err
@@ -1072,6 +1972,15 @@ func downscaleImage(imgData []byte, width uint) ([]byte, string, error) {
 
 // decodeImage decodes an image from bytes and returns the image and its format
 func decodeImage(imgData []byte) (image.Image, string, error) {
+	// Sample the frames of animated WebPs into a montage so the whole animation is described
+	if isAnimatedWebP(imgData) {
+		frames, err := decodeAnimatedWebP(imgData, config.ImageProcessing.AnimationFrames)
+		if err == nil {
+			return buildMontage(frames), "webp", nil
+		}
+		log.Printf("Error decoding animated WebP, falling back to a single frame: %v", err)
+	}
+
 	img, format, err := image.Decode(bytes.NewReader(imgData))
 	if err == nil {
 		return img, format, nil
@@ -1101,7 +2010,49 @@ func decodeImage(imgData []byte) (image.Image, string, error) {
 		return img, "gif", nil
 	}
 
-	return nil, "", fmt.Errorf("unsupported image format: %v", err)
+	return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+}
+
+// geminiImageFormats maps image formats and file extensions to the image/* MIME subtypes Gemini accepts
+var geminiImageFormats = map[string]string{
+	"jpeg": "jpeg",
+	"jpg":  "jpeg",
+	"png":  "png",
+	"webp": "webp",
+	"heic": "heic",
+	"heif": "heif",
+}
+
+// geminiImageFormat returns the format string for genai.ImageData, which Gemini would otherwise reject silently
+func geminiImageFormat(format string) (string, error) {
+	if geminiFormat, ok := geminiImageFormats[strings.ToLower(strings.TrimPrefix(format, "."))]; ok {
+		return geminiFormat, nil
+	}
+	return "", fmt.Errorf("%w: not supported by Gemini: %s", ErrUnsupportedFormat, format)
+}
+
+// geminiResult turns the result of GenerateContent into the response text, recording the token usage.
+// Blocked requests return ErrContentBlocked and responses without any text ErrEmptyResponse.
+func geminiResult(resp *genai.GenerateContentResponse, err error) (string, error) {
+	var blockedErr *genai.BlockedError
+	if errors.As(err, &blockedErr) {
+		return "", fmt.Errorf("%w: %v", ErrContentBlocked, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	recordGeminiUsage(resp)
+
+	text := getResponse(resp)
+	if strings.TrimSpace(text) == "" {
+		for _, cand := range resp.Candidates {
+			if cand.FinishReason == genai.FinishReasonSafety || cand.FinishReason == genai.FinishReasonRecitation {
+				return "", ErrContentBlocked
+			}
+		}
+		return "", ErrEmptyResponse
+	}
+	return text, nil
 }
 
 // getResponse extracts the text response from the AI model's output
@@ -1134,9 +2085,7 @@ func postProcessAltText(altText string) string {
 	altText = strings.ReplaceAll(altText, "@", "[@]")
 
 	// Remove any leading or trailing whitespace
-	altText = strings.TrimSpace(altText)
-
-	return altText
+	return strings.TrimSpace(altText)
 }
 
 // checkOllamaModel checks if the Ollama model is available and working
@@ -1160,25 +2109,60 @@ func checkOllamaModel() error {
 
 // Struct to store reply information with a timestamp
 type ReplyInfo struct {
-	ReplyID   mastodon.ID
-	Timestamp time.Time
+	OriginalID  mastodon.ID
+	ReplyID     mastodon.ID
+	ReplyURL    string
+	RequesterID mastodon.ID
+	Timestamp   time.Time
 }
 
-var replyMap = make(map[mastodon.ID]ReplyInfo)
+// replyMap holds the bot's recent replies by the accountKey of the post they describe
+var replyMap = make(map[string]ReplyInfo)
 var mapMutex sync.Mutex
 
-func handleDeleteEvent(c *mastodon.Client, originalID mastodon.ID) {
+// replyWithExistingDescription answers the mention with a link to the bot's earlier description
+// of the status, returning false if the status hasn't been described recently
+func replyWithExistingDescription(c MastodonClient, statusID mastodon.ID, notification *mastodon.Notification) bool {
+	mapMutex.Lock()
+	replyInfo, exists := replyMap[accountKey(c, statusID)]
+	mapMutex.Unlock()
+
+	if !exists || replyInfo.ReplyURL == "" {
+		return false
+	}
+
+	return pointToDescription(c, replyInfo.ReplyURL, notification)
+}
+
+// pointToDescription answers the mention with a link to a description that was already posted
+func pointToDescription(c MastodonClient, descriptionURL string, notification *mastodon.Notification) bool {
+	message := fmt.Sprintf("@%s "+getLocalizedString(notification.Status.Language, "alreadyDescribed", "response"), notification.Account.Acct, descriptionURL)
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: notification.Status.ID,
+		Visibility:  mapReplyVisibility(settingsFor(c).ReplyVisibility, notification.Status.Visibility),
+		Language:    notification.Status.Language,
+	})
+	if err != nil {
+		log.Printf("Error pointing to existing description: %v", err)
+		return false
+	}
+	return true
+}
+
+func handleDeleteEvent(c MastodonClient, originalID mastodon.ID) {
 	mapMutex.Lock()
 	defer mapMutex.Unlock()
 
-	if replyInfo, exists := replyMap[originalID]; exists {
+	key := accountKey(c, originalID)
+	if replyInfo, exists := replyMap[key]; exists {
 		// Delete AltBot's reply
 		err := c.DeleteStatus(ctx, replyInfo.ReplyID)
 		if err != nil {
 			log.Printf("Error deleting reply: %v", err)
 		} else {
 			log.Printf("Deleted reply for original post ID: %v", originalID)
-			delete(replyMap, originalID)
+			delete(replyMap, key)
 		}
 	}
 }
@@ -1188,9 +2172,9 @@ func cleanupOldEntries() {
 		time.Sleep(10 * time.Minute) // Run cleanup every 10 minutes
 
 		mapMutex.Lock()
-		for originalID, replyInfo := range replyMap {
+		for key, replyInfo := range replyMap {
 			if time.Since(replyInfo.Timestamp) > time.Hour {
-				delete(replyMap, originalID)
+				delete(replyMap, key)
 			}
 		}
 		mapMutex.Unlock()
@@ -1205,6 +2189,15 @@ type RateLimiter struct {
 	ExceededCounts map[string]int  `json:"exceeded_counts"`
 	ShadowBanned   map[string]bool `json:"shadow_banned"`
 	Whitelist      map[string]bool `json:"whitelist"`
+	// Per media type counts, keyed by "userID/mediaType"
+	MediaMinuteCounts map[string]int `json:"media_minute_counts"`
+	MediaHourCounts   map[string]int `json:"media_hour_counts"`
+}
+
+// MediaRateLimit limits how often a user can request descriptions of a single media type
+type MediaRateLimit struct {
+	MaxPerMinute int `toml:"max_requests_per_user_per_minute"`
+	MaxPerHour   int `toml:"max_requests_per_user_per_hour"`
 }
 
 // NewRateLimiter creates a new RateLimiter
@@ -1216,12 +2209,16 @@ func NewRateLimiter() *RateLimiter {
 		ExceededCounts: make(map[string]int),
 		ShadowBanned:   make(map[string]bool),
 		Whitelist:      make(map[string]bool),
+
+		MediaMinuteCounts: make(map[string]int),
+		MediaHourCounts:   make(map[string]int),
 	}
 }
 
 // IsNewAccount checks if the user account age is within the new account period
-func (rl *RateLimiter) IsNewAccount(c *mastodon.Client, userID string) bool {
-	creationDate, exists := rl.AccountAges[userID]
+func (rl *RateLimiter) IsNewAccount(c MastodonClient, userID string) bool {
+	key := instanceKey(c, mastodon.ID(userID))
+	creationDate, exists := rl.AccountAges[key]
 	if !exists {
 		// Fetch the account creation date if it doesn't exist
 		account, err := c.GetAccount(ctx, mastodon.ID(userID))
@@ -1231,14 +2228,21 @@ func (rl *RateLimiter) IsNewAccount(c *mastodon.Client, userID string) bool {
 		}
 
 		creationDate = account.CreatedAt
-		rl.AccountAges[userID] = creationDate
+		rl.AccountAges[key] = creationDate
 	}
 	log.Printf("Account creation date: %v", creationDate)
 	return time.Since(creationDate).Hours() < 24*float64(config.RateLimit.NewAccountPeriodDays)
 }
 
-// Increment increments the request count for a user and checks limits
-func (rl *RateLimiter) Increment(c *mastodon.Client, userID string) bool {
+// Increment increments the request count for a user and checks limits, including
+// the limits configured for the media type being described
+func (rl *RateLimiter) Increment(c MastodonClient, userID string, mediaType string) bool {
+	return rl.IncrementAll(c, userID, []string{mediaType})
+}
+
+// IncrementAll counts one request per media type, either all of them or none if any limit would be exceeded.
+// The counts are kept by the instanceKey of the user, as the accounts may be on different servers.
+func (rl *RateLimiter) IncrementAll(c MastodonClient, userID string, mediaTypes []string) bool {
 	if !config.RateLimit.Enabled {
 		return true
 	}
@@ -1246,9 +2250,11 @@ func (rl *RateLimiter) Increment(c *mastodon.Client, userID string) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	isBanned := rl.IsShadowBanned(userID)
+	key := instanceKey(c, mastodon.ID(userID))
+
+	isBanned := rl.IsShadowBanned(key)
 	if isBanned {
-		log.Printf("User %s is shadow banned: %v", userID, isBanned)
+		log.Printf("User %s is shadow banned: %v", key, isBanned)
 		return false
 	}
 
@@ -1261,8 +2267,8 @@ func (rl *RateLimiter) Increment(c *mastodon.Client, userID string) bool {
 	isNew := rl.IsNewAccount(c, userID)
 
 	if isNew {
-		log.Printf("Sussy baka New account!!1!1!! feds get his ass: %s", userID)
-		metricsManager.logNewAccountActivity(string(userID))
+		log.Printf("Sussy baka New account!!1!1!! feds get his ass: %s", key)
+		metricsManager.logNewAccountActivity(key)
 	}
 
 	// Determine limits based on account age
@@ -1273,37 +2279,71 @@ func (rl *RateLimiter) Increment(c *mastodon.Client, userID string) bool {
 		maxPerHour = config.RateLimit.NewAccountMaxRequestsPerHour
 	}
 
-	// Check per-minute limit
-	if rl.MinuteCounts[userID] >= maxPerMinute {
-		rl.ExceededCounts[userID]++
-		if rl.ExceededCounts[userID] >= config.RateLimit.ShadowBanThreshold {
-			rl.ShadowBanUser(c, userID)
+	requests := len(mediaTypes)
+
+	// Check the per-minute and per-hour limits. A post with more attachments than a limit allows is
+	// refused however long the user waits, so only rejections waiting would avoid count towards a shadow ban.
+	if rl.MinuteCounts[key]+requests > maxPerMinute || rl.HourCounts[key]+requests > maxPerHour {
+		if requests <= maxPerMinute && requests <= maxPerHour {
+			rl.ExceededCounts[key]++
+			if rl.ExceededCounts[key] >= config.RateLimit.ShadowBanThreshold {
+				rl.ShadowBanUser(c, userID)
+			}
 		}
 		return false
 	}
 
-	// Check per-hour limit
-	if rl.HourCounts[userID] >= maxPerHour {
-		rl.ExceededCounts[userID]++
-		if rl.ExceededCounts[userID] >= config.RateLimit.ShadowBanThreshold {
-			rl.ShadowBanUser(c, userID)
+	// Check the limits of the media types, these don't count towards a shadow ban
+	perType := make(map[string]int)
+	for _, mediaType := range mediaTypes {
+		perType[mediaLimitType(mediaType)]++
+	}
+	for mediaType, count := range perType {
+		mediaLimit, ok := config.RateLimit.MediaLimits[mediaType]
+		if !ok {
+			continue
+		}
+		mediaKey := key + "/" + mediaType
+		if mediaLimit.MaxPerMinute > 0 && rl.MediaMinuteCounts[mediaKey]+count > mediaLimit.MaxPerMinute {
+			return false
+		}
+		if mediaLimit.MaxPerHour > 0 && rl.MediaHourCounts[mediaKey]+count > mediaLimit.MaxPerHour {
+			return false
 		}
-		return false
 	}
 
-	rl.MinuteCounts[userID]++
-	rl.HourCounts[userID]++
+	rl.MinuteCounts[key] += requests
+	rl.HourCounts[key] += requests
+	if rl.MediaMinuteCounts == nil {
+		rl.MediaMinuteCounts = make(map[string]int)
+		rl.MediaHourCounts = make(map[string]int)
+	}
+	for mediaType, count := range perType {
+		if _, ok := config.RateLimit.MediaLimits[mediaType]; ok {
+			rl.MediaMinuteCounts[key+"/"+mediaType] += count
+			rl.MediaHourCounts[key+"/"+mediaType] += count
+		}
+	}
 	return true
 }
 
-func (rl *RateLimiter) ShadowBanUser(c *mastodon.Client, userID string) {
-	if rl.Whitelist[userID] {
+// mediaLimitType maps an attachment type to the key used in the media rate limits
+func mediaLimitType(mediaType string) string {
+	if mediaType == "gifv" {
+		return "video"
+	}
+	return mediaType
+}
+
+func (rl *RateLimiter) ShadowBanUser(c MastodonClient, userID string) {
+	key := instanceKey(c, mastodon.ID(userID))
+	if rl.Whitelist[key] {
 		return
 	}
 
-	log.Printf("Get shadow banned noob %s", userID)
-	rl.ShadowBanned[userID] = true
-	metricsManager.logShadowBan(string(userID))
+	log.Printf("Get shadow banned noob %s", key)
+	rl.ShadowBanned[key] = true
+	metricsManager.logShadowBan(key)
 	rl.notifyAdmin(c, userID)
 }
 
@@ -1311,7 +2351,7 @@ func (rl *RateLimiter) IsShadowBanned(userID string) bool {
 	return rl.ShadowBanned[userID]
 }
 
-func (rl *RateLimiter) notifyAdmin(c *mastodon.Client, userID string) {
+func (rl *RateLimiter) notifyAdmin(c MastodonClient, userID string) {
 	account, err := c.GetAccount(ctx, mastodon.ID(userID))
 	if err != nil {
 		log.Printf("Error fetching account: %v", err)
@@ -1319,8 +2359,8 @@ func (rl *RateLimiter) notifyAdmin(c *mastodon.Client, userID string) {
 	}
 	name := account.Acct
 
-	message := fmt.Sprintf("%s User %s has been shadow banned for exceeding rate limits.\nTo unban, reply with 'unban %s'.", config.RateLimit.AdminContactHandle, name, userID)
-	_, err = c.PostStatus(ctx, &mastodon.Toot{
+	message := fmt.Sprintf("%s User %s has been shadow banned for exceeding rate limits.\nTo unban, reply with 'unban %s'.", config.RateLimit.AdminContactHandle, name, instanceKey(c, mastodon.ID(userID)))
+	_, err = postStatus(ctx, c, &mastodon.Toot{
 		Status:     message,
 		Visibility: "direct",
 	})
@@ -1343,13 +2383,22 @@ func (rl *RateLimiter) UnbanAndWhitelistUser(userID string) {
 	}
 }
 
-func handleAdminReply(c *mastodon.Client, reply *mastodon.Status, rl *RateLimiter) {
-	content := stripHTMLTags(reply.Content)
-	content = strings.ToLower(content)
+// handleAdminReply carries out the commands the admin replies with. Its answers are posted even
+// while paused, so pausing and resuming can be confirmed.
+func handleAdminReply(c MastodonClient, reply *mastodon.Status, rl *RateLimiter) {
+	content := strings.ToLower(mentionText(reply.Content))
 
 	parts := strings.Fields(content)
-	if len(parts) == 3 && parts[1] == "unban" {
-		userID := parts[2]
+	if len(parts) < 1 {
+		return
+	}
+
+	switch parts[0] {
+	case "unban":
+		if len(parts) != 2 {
+			return
+		}
+		userID := parts[1]
 		rl.UnbanAndWhitelistUser(userID)
 		log.Printf("Admin unbanned user %s based on reply.", userID)
 		metricsManager.logUnBan(string(userID))
@@ -1361,6 +2410,46 @@ func handleAdminReply(c *mastodon.Client, reply *mastodon.Status, rl *RateLimite
 		if err != nil {
 			log.Printf("Error sending confirmation of unban: %v", err)
 		}
+	case "pause":
+		setPaused(true)
+		log.Printf("Admin paused AltBot.")
+		_, err := c.PostStatus(ctx, &mastodon.Toot{
+			Status:      fmt.Sprintf("%s AltBot has been paused and will not post until resumed.\nTo resume, reply with 'resume'.", config.RateLimit.AdminContactHandle),
+			Visibility:  "direct",
+			InReplyToID: reply.ID,
+		})
+		if err != nil {
+			log.Printf("Error sending confirmation of pause: %v", err)
+		}
+	case "reprocess":
+		// Describing everything again takes a while, so it doesn't hold up the admin's stream
+		go func() {
+			count, err := reprocessDeadLetters()
+			message := fmt.Sprintf("%s Tried %d failed descriptions again.", config.RateLimit.AdminContactHandle, count)
+			if err != nil {
+				log.Printf("Error reprocessing dead letters: %v", err)
+				message = fmt.Sprintf("%s Failed descriptions couldn't be tried again: %v", config.RateLimit.AdminContactHandle, err)
+			}
+			_, err = c.PostStatus(ctx, &mastodon.Toot{
+				Status:      message,
+				Visibility:  "direct",
+				InReplyToID: reply.ID,
+			})
+			if err != nil {
+				log.Printf("Error sending confirmation of reprocessing: %v", err)
+			}
+		}()
+	case "resume":
+		setPaused(false)
+		log.Printf("Admin resumed AltBot.")
+		_, err := c.PostStatus(ctx, &mastodon.Toot{
+			Status:      fmt.Sprintf("%s AltBot has been resumed.", config.RateLimit.AdminContactHandle),
+			Visibility:  "direct",
+			InReplyToID: reply.ID,
+		})
+		if err != nil {
+			log.Printf("Error sending confirmation of resume: %v", err)
+		}
 	}
 }
 
@@ -1372,6 +2461,10 @@ func (rl *RateLimiter) ResetMinuteCounts() {
 	for userID := range rl.MinuteCounts {
 		rl.MinuteCounts[userID] = 0
 	}
+
+	for key := range rl.MediaMinuteCounts {
+		rl.MediaMinuteCounts[key] = 0
+	}
 }
 
 // ResetHourCounts resets the per-hour request counts for all users
@@ -1383,6 +2476,10 @@ func (rl *RateLimiter) ResetHourCounts() {
 		rl.HourCounts[userID] = 0
 	}
 
+	for key := range rl.MediaHourCounts {
+		rl.MediaHourCounts[key] = 0
+	}
+
 	for userID := range rl.ExceededCounts {
 		rl.ExceededCounts[userID] = 0
 	}
@@ -1410,11 +2507,15 @@ func (rl *RateLimiter) SaveToFile(filePath string) error {
 // ConsentRequest struct to store consent requests
 type ConsentRequest struct {
 	RequestID mastodon.ID
+	PosterID  mastodon.ID
 	Timestamp time.Time
+	Selection []int
 }
 
 func saveConsentRequestsToFile(filePath string) error {
+	consentMutex.Lock()
 	data, err := json.Marshal(consentRequests)
+	consentMutex.Unlock()
 	if err != nil {
 		return err
 	}
@@ -1423,11 +2524,14 @@ func saveConsentRequestsToFile(filePath string) error {
 }
 
 func loadConsentRequestsFromFile(filePath string) error {
+	consentMutex.Lock()
+	defer consentMutex.Unlock()
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// File doesn't exist, so initialize an empty map
-			consentRequests = make(map[mastodon.ID]ConsentRequest)
+			consentRequests = make(map[string]ConsentRequest)
 			return nil
 		}
 		return err
@@ -1441,35 +2545,66 @@ func loadConsentRequestsFromFile(filePath string) error {
 }
 
 func cleanupOldConsentRequests() {
+	consentMutex.Lock()
+	defer consentMutex.Unlock()
+
 	for id, request := range consentRequests {
 		if time.Since(request.Timestamp) > 30*24*time.Hour { // 30 days
+			log.Printf("Consent request for %s expired without an answer", id)
+			metricsManager.logConsentExpired(string(request.PosterID))
 			delete(consentRequests, id)
 		}
 	}
 }
 
-// stripHTMLTags extracts and returns plain text from HTML content
+// stripHTMLTags converts the HTML content of a status to plain text, keeping lines and paragraphs apart
 func stripHTMLTags(htmlContent string) string {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		log.Printf("Error parsing HTML: %v", err)
 		return htmlContent // Return unchanged if parsing fails
 	}
-	return extractText(doc)
+	return strings.TrimSpace(extractText(doc, false))
 }
 
-// extractText recursively extracts text from an HTML node
-func extractText(n *html.Node) string {
+// extractText recursively extracts text from an HTML node, leaving out mention links if skipMentions is set
+func extractText(n *html.Node, skipMentions bool) string {
 	if n.Type == html.TextNode {
 		return n.Data
 	}
+
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "br":
+			return "\n"
+		case "a":
+			if skipMentions && isMentionLink(n) {
+				return ""
+			}
+		}
+	}
+
 	var text string
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		text += extractText(c)
+		text += extractText(c, skipMentions)
+	}
+
+	if n.Type == html.ElementNode && n.Data == "p" {
+		text += "\n"
 	}
 	return text
 }
 
+// isMentionLink reports whether the node is a link to a mentioned account, hashtags don't count
+func isMentionLink(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == "class" {
+			return strings.Contains(attr.Val, "mention") && !strings.Contains(attr.Val, "hashtag")
+		}
+	}
+	return false
+}
+
 func getStatusSymbol(enabled bool) string {
 	if enabled {
 		return Green + "✓" + Reset
@@ -1593,17 +2728,26 @@ func queuePostForAltTextCheck(post *mastodon.Status, userID string) {
 	}
 }
 
-func checkAltTextPeriodically(c *mastodon.Client, interval time.Duration, checkTime time.Duration) {
+func checkAltTextPeriodically(c MastodonClient, interval time.Duration, checkTime time.Duration) {
 	for {
 		time.Sleep(interval)
+		// The checks are kept until the bot is resumed
+		if isPaused() {
+			continue
+		}
 		now := time.Now()
 
 		for postID, check := range altTextChecks {
 			// Check if time has passed
 			if now.Sub(check.Timestamp) >= checkTime {
 				// Fetch post details
-				post, err := c.GetStatus(ctx, check.PostID)
+				post, err := fetchStatus(ctx, c, check.PostID)
 				if err != nil {
+					if isStatusGone(err) {
+						// The post was deleted or hidden, so there is nothing left to check
+						delete(altTextChecks, postID)
+						continue
+					}
 					log.Printf("Error fetching post %s during alt-text check: %v", check.PostID, err)
 					continue
 				}
@@ -1633,10 +2777,10 @@ func checkAltTextPeriodically(c *mastodon.Client, interval time.Duration, checkT
 	}
 }
 
-func notifyUserOfMissingAltText(c *mastodon.Client, post *mastodon.Status, userID string) {
+func notifyUserOfMissingAltText(c MastodonClient, post *mastodon.Status, userID string) {
 	message := fmt.Sprintf(getLocalizedString(post.Language, "altTextReminder", "response"), userID)
 
-	_, err := c.PostStatus(ctx, &mastodon.Toot{
+	_, err := postStatus(ctx, c, &mastodon.Toot{
 		Status:      message,
 		InReplyToID: post.ID,
 		Visibility:  "direct",
@@ -1693,6 +2837,18 @@ func copyConfig(src, dest string, skipLines int) error {
 	return nil
 }
 
+// applyConfigDefaults fills in the settings that aren't off when left out, for config files
+// written before these settings existed
+func applyConfigDefaults(md toml.MetaData) {
+	if !md.IsDefined("behavior", "reply_on_error") {
+		config.Behavior.ReplyOnError = true
+	}
+	// Without a template, structured descriptions are just the caption
+	if strings.TrimSpace(config.Gemini.StructuredTemplate) == "" {
+		config.Gemini.StructuredTemplate = defaultStructuredTemplate
+	}
+}
+
 func compareConfigs(defaultConfig, userConfig Config) int {
 	customCount := 0
 	warnings := []string{}
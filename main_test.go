@@ -0,0 +1,935 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/mattn/go-mastodon"
+	"golang.org/x/image/webp"
+)
+
+// sampleImage is a smooth gradient like the skies and shadows of photos
+func sampleImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8((x + y) / 2), 255})
+		}
+	}
+	return img
+}
+
+func TestDownscaleImageWebPIsSmallerThanPNG(t *testing.T) {
+	img := sampleImage()
+
+	pngData, pngFormat, err := downscaleImage(img, "png", 256, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webpData, webpFormat, err := downscaleImage(img, "png", 256, "webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pngFormat != "png" || webpFormat != "webp" {
+		t.Fatalf("formats = %s and %s, want png and webp", pngFormat, webpFormat)
+	}
+	if len(webpData) >= len(pngData) {
+		t.Errorf("WebP is %d bytes, not smaller than PNG with %d bytes", len(webpData), len(pngData))
+	}
+	t.Logf("PNG %d bytes, WebP %d bytes", len(pngData), len(webpData))
+
+	// Lossless WebP keeps every pixel of the PNG
+	decoded, err := webp.Decode(bytes.NewReader(webpData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, point := range []image.Point{{0, 0}, {100, 200}, {255, 255}} {
+		r1, g1, b1, _ := img.At(point.X, point.Y).RGBA()
+		r2, g2, b2, _ := decoded.At(point.X, point.Y).RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 {
+			t.Errorf("pixel at %v changed", point)
+		}
+	}
+}
+
+func TestDownscaleImageKeepsJPEGWithoutUploadFormat(t *testing.T) {
+	tests := []struct {
+		format, uploadFormat, want string
+	}{
+		{"jpeg", "", "jpeg"},
+		{"gif", "", "png"},
+		{"webp", "", "png"},
+		{"jpeg", "png", "png"},
+		{"png", "webp", "webp"},
+	}
+	for _, tt := range tests {
+		if _, got, err := downscaleImage(sampleImage(), tt.format, 64, tt.uploadFormat); err != nil || got != tt.want {
+			t.Errorf("downscaleImage(%s, %q) = %s, %v, want %s", tt.format, tt.uploadFormat, got, err, tt.want)
+		}
+	}
+	if _, _, err := downscaleImage(sampleImage(), "svg", 64, ""); err == nil {
+		t.Error("downscaleImage accepted an unsupported format")
+	}
+}
+
+func TestIsPostDNI(t *testing.T) {
+	withConfig(t, func(c *Config) { c.DNI.PostTags = []string{"#NoAI", "NoBot"} })
+
+	tests := []struct {
+		name   string
+		status mastodon.Status
+		want   bool
+	}{
+		{"hashtag", mastodon.Status{Tags: []mastodon.Tag{{Name: "noai"}}}, true},
+		{"in the text", mastodon.Status{Content: "<p>My drawing #NoBot.</p>"}, true},
+		{"other hashtag", mastodon.Status{Tags: []mastodon.Tag{{Name: "art"}}, Content: "<p>#NoAIArt</p>"}, false},
+		{"without hash", mastodon.Status{Content: "<p>No AI was used, noai</p>"}, false},
+	}
+	for _, tt := range tests {
+		if got := isPostDNI(&tt.status); got != tt.want {
+			t.Errorf("isPostDNI(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOptedOutPostIsOnlyDescribedForItsAuthor(t *testing.T) {
+	for _, requester := range []mastodon.ID{"poster", "requester"} {
+		t.Run(string(requester), func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.DNI.PostTags = []string{"#NoAI"}
+				c.Behavior.AskForConsent = false
+				c.RateLimit.Enabled = false
+			})
+			provider := &fakeProvider{response: "A red square"}
+			useProvider(t, provider)
+
+			mention := &mastodon.Status{
+				ID:          "mention",
+				InReplyToID: "sketch",
+				Account:     mastodon.Account{ID: requester, Acct: string(requester)},
+				Content:     "<p>@altbot</p>",
+				Visibility:  "public",
+				Language:    "en",
+			}
+			c := newFakeClient(mention, &mastodon.Status{
+				ID:               "sketch",
+				Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+				Content:          "<p>Sketch #NoAI</p>",
+				Tags:             []mastodon.Tag{{Name: "NoAI"}},
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 8, 8, color.White))}},
+			})
+			forgetReplies(t, c, "sketch")
+			handleMention(c, &mastodon.Notification{Account: mention.Account, Status: mention})
+
+			if described := provider.calls() > 0; described != (requester == "poster") {
+				t.Errorf("described = %v for a mention by %s", described, requester)
+			}
+		})
+	}
+}
+
+func TestOldPostsAreNotDescribedAutomatically(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxAge    int
+		age       time.Duration
+		described bool
+	}{
+		{"recent", 60, 5 * time.Minute, true},
+		{"old", 60, 2 * time.Hour, false},
+		{"no limit", 0, 48 * time.Hour, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Behavior.MaxPostAgeMinutes = tt.maxAge
+				c.Behavior.AutoDescribeDelaySeconds = 0
+				c.RateLimit.Enabled = false
+			})
+			provider := &fakeProvider{response: "A white square"}
+			useProvider(t, provider)
+
+			c := newFakeClient()
+			c.relationships["follower"] = &mastodon.Relationship{ID: "follower", FollowedBy: true}
+			status := &mastodon.Status{
+				ID:               mastodon.ID(fmt.Sprintf("aged-%d", i)),
+				Account:          mastodon.Account{ID: "follower", Acct: "follower"},
+				CreatedAt:        time.Now().Add(-tt.age),
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 8, 8, color.White))}},
+			}
+			c.statuses[status.ID] = status
+			forgetReplies(t, c, status.ID)
+
+			handleUpdate(c, status)
+
+			if described := provider.calls() > 0; described != tt.described {
+				t.Errorf("described = %v, want %v", described, tt.described)
+			}
+		})
+	}
+}
+
+func TestGifvIsDescribedAsAnimation(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		prompt    string
+	}{
+		{"gifv", "generateAnimationAltText"},
+		{"video", "generateVideoAltText"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+			saved := videoAudioProcessingCapability
+			videoAudioProcessingCapability = true
+			t.Cleanup(func() { videoAudioProcessingCapability = saved })
+			provider := &fakeProvider{response: "A cat jumping", media: map[string]bool{"video": true}}
+			useProvider(t, provider)
+
+			status := &mastodon.Status{
+				ID:               mastodon.ID("clip-" + tt.mediaType),
+				Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: tt.mediaType, URL: dataURI("video/mp4", []byte("not really a video"))}},
+			}
+			c := newFakeClient(status, &mastodon.Status{ID: "mention", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "en"})
+			forgetReplies(t, c, status.ID)
+
+			generateAndPostAltText(c, status, "mention", nil)
+
+			if provider.calls() != 1 || provider.prompts[0] != localizedPrompt("en", tt.prompt) {
+				t.Errorf("prompts = %q, want the %s prompt", provider.prompts, tt.prompt)
+			}
+		})
+	}
+}
+
+func TestFailedGenerationRepliesWithTheMediaTypesFallback(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		mime      string
+		key       string
+	}{
+		{"image", "image/png", "altTextError"},
+		{"video", "video/mp4", "videoAltTextError"},
+		{"gifv", "video/mp4", "videoAltTextError"},
+		{"audio", "audio/mpeg", "audioAltTextError"},
+	}
+	for _, tt := range tests {
+		for _, lang := range []string{"en", "de"} {
+			t.Run(tt.mediaType+"/"+lang, func(t *testing.T) {
+				withConfig(t, func(c *Config) {
+					c.RateLimit.Enabled = false
+					c.Behavior.ReplyOnError = true
+				})
+				useVideoAudioCapability(t, true)
+				useProvider(t, &fakeProvider{err: errors.New("the model fell over"), media: map[string]bool{"video": true, "audio": true}})
+
+				data := []byte("not really media")
+				if tt.mediaType == "image" {
+					data = testImage(t, 4, 4, color.White)
+				}
+				status := &mastodon.Status{
+					ID:               mastodon.ID("failing-" + tt.mediaType + "-" + lang),
+					Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+					Visibility:       "public",
+					MediaAttachments: []mastodon.Attachment{{ID: "a", Type: tt.mediaType, URL: dataURI(tt.mime, data)}},
+				}
+				c := newFakeClient(status, &mastodon.Status{ID: "mention", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: lang})
+				forgetReplies(t, c, status.ID)
+
+				generateAndPostAltText(c, status, "mention", nil)
+
+				want := getLocalizedString(lang, tt.key, "response")
+				posted := c.postedToots()
+				if want == "" || len(posted) != 1 || !strings.Contains(posted[0].Status, want) {
+					t.Errorf("posted %+v, want the %s message %q", posted, tt.key, want)
+				}
+			})
+		}
+	}
+}
+
+func TestEmptyResponseIsRetriedOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   bool
+		first   []string
+		calls   int
+		success bool
+	}{
+		{"retried", true, []string{""}, 2, true},
+		{"whitespace retried", true, []string{"  \n"}, 2, true},
+		{"only once", true, []string{"", ""}, 2, false},
+		{"disabled", false, []string{""}, 1, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.LLM.RetryOnEmpty = tt.retry })
+			provider := &fakeProvider{first: tt.first, response: "A red square."}
+			useProvider(t, provider)
+			image := dataURI("image/png", testImage(t, 5, 5+i, color.RGBA{R: 200, A: 255}))
+
+			altText, _ := generateImageAltText(image, "", "en", "", false, nil)
+
+			if provider.calls() != tt.calls {
+				t.Errorf("the provider was called %d times, want %d", provider.calls(), tt.calls)
+			}
+			if success := strings.Contains(altText, "A red square."); success != tt.success {
+				t.Errorf("alt-text = %q, want the description %v", altText, tt.success)
+			}
+			if tt.calls == 2 && provider.prompts[1] != localizedPrompt("en", "generateAltTextSimple") {
+				t.Errorf("retried with %q, want the simpler prompt", provider.prompts[1])
+			}
+		})
+	}
+}
+
+// useGeminiSetup restores the Gemini client and models replaced by Setup when the test ends
+func useGeminiSetup(t *testing.T) {
+	t.Helper()
+	savedClient, savedModel, savedStructured, savedCtx := client, model, structuredModel, ctx
+	t.Cleanup(func() {
+		if client != nil && client != savedClient {
+			client.Close()
+		}
+		client, model, structuredModel, ctx = savedClient, savedModel, savedStructured, savedCtx
+	})
+}
+
+// modelName returns the full name of the model, which genai doesn't export
+func modelName(m *genai.GenerativeModel) string {
+	return reflect.ValueOf(m).Elem().FieldByName("fullName").String()
+}
+
+func TestSetupUsesTheConfiguredModel(t *testing.T) {
+	tests := []struct {
+		configured string
+		want       string
+	}{
+		{"gemini-2.0-flash", "models/gemini-2.0-flash"},
+		{" gemini-2.5-pro ", "models/gemini-2.5-pro"},
+		{"", "models/" + DefaultGeminiModel},
+		{"  ", "models/" + DefaultGeminiModel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.configured, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Gemini.Model = tt.configured
+				c.Gemini.StructuredOutput = true
+			})
+			useGeminiSetup(t)
+
+			if err := Setup("test-key"); err != nil {
+				t.Fatal(err)
+			}
+			if got := modelName(model); got != tt.want {
+				t.Errorf("model = %s, want %s", got, tt.want)
+			}
+			if got := modelName(structuredModel); got != tt.want {
+				t.Errorf("structured model = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupAppliesTheSystemInstruction(t *testing.T) {
+	instruction := "You write alt-text for people who can't see the image."
+	tests := []struct {
+		name        string
+		instruction string
+		want        []genai.Part
+	}{
+		{"configured", instruction, []genai.Part{genai.Text(instruction)}},
+		{"not configured", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Gemini.SystemInstruction = tt.instruction
+				c.Gemini.StructuredOutput = true
+			})
+			useGeminiSetup(t)
+
+			if err := Setup("test-key"); err != nil {
+				t.Fatal(err)
+			}
+			for name, m := range map[string]*genai.GenerativeModel{"model": model, "structured model": structuredModel} {
+				var got []genai.Part
+				if m.SystemInstruction != nil {
+					got = m.SystemInstruction.Parts
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s system instruction = %v, want %v", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// halfFetchedClient answers lookups of the broken status with the status and an error at once
+type halfFetchedClient struct {
+	*fakeClient
+	broken mastodon.ID
+}
+
+func (h *halfFetchedClient) GetStatus(ctx context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	status, _ := h.fakeClient.GetStatus(ctx, id)
+	if id == h.broken {
+		return status, &mastodon.APIError{StatusCode: 503}
+	}
+	return status, nil
+}
+
+func TestMentionsAreHandledOnce(t *testing.T) {
+	requester := mastodon.Account{ID: "requester", Acct: "requester"}
+	poster := mastodon.Account{ID: "routed-poster", Acct: "routed-poster"}
+
+	tests := []struct {
+		name   string
+		parent mastodon.ID
+		broken bool
+		posts  int
+	}{
+		{"reply to a post", "routed-post", false, 1},
+		{"parent lookup failed", "routed-post", true, 0},
+		{"answer to a consent request", "consent-question", false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+			useNotificationCursors(t)
+			provider := &fakeProvider{response: "A white square"}
+			useProvider(t, provider)
+
+			post := &mastodon.Status{
+				ID:               "routed-post",
+				Account:          poster,
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+			}
+			question := &mastodon.Status{ID: "consent-question", Account: mastodon.Account{ID: "bot", Acct: "altbot"}, InReplyToID: "routed-post"}
+			author := requester
+			if tt.parent == "consent-question" {
+				author = poster
+			}
+			mention := &mastodon.Status{ID: "routed-mention", Account: author, InReplyToID: tt.parent, Content: "<p>@altbot yes</p>", Visibility: "public", Language: "en"}
+			fake := newFakeClient(post, question, mention)
+			forgetReplies(t, fake, post.ID)
+			var c MastodonClient = fake
+			if tt.broken {
+				c = &halfFetchedClient{fakeClient: fake, broken: tt.parent}
+			}
+			if tt.parent == "consent-question" {
+				useConsentRequest(t, c, post.ID, ConsentRequest{RequestID: "earlier-mention", PosterID: poster.ID})
+			}
+
+			handleNotification(c, &mastodon.Notification{ID: "routed", Type: "mention", Account: author, Status: mention})
+
+			if posted := fake.postedToots(); len(posted) != tt.posts {
+				t.Errorf("posted %d replies, want %d: %+v", len(posted), tt.posts, posted)
+			}
+			if provider.calls() != tt.posts {
+				t.Errorf("the provider was called %d times, want %d", provider.calls(), tt.posts)
+			}
+		})
+	}
+}
+
+// useBotAccountID makes the ID one of the bot's verified account IDs on the client's instance
+func useBotAccountID(t *testing.T, c MastodonClient, id mastodon.ID) {
+	t.Helper()
+	key := instanceKey(c, id)
+	botAccountIDs[key] = true
+	t.Cleanup(func() { delete(botAccountIDs, key) })
+}
+
+func TestOwnPostsAreRecognisedByAccountID(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Server.Username = "altbot"
+		c.Behavior.AutoDescribeDelaySeconds = 0
+		c.RateLimit.Enabled = false
+	})
+	tests := []struct {
+		name      string
+		account   mastodon.Account
+		described bool
+	}{
+		{"own post after a rename", mastodon.Account{ID: "bot", Username: "describer", Acct: "describer"}, false},
+		{"namesake on another server", mastodon.Account{ID: "namesake", Username: "altbot", Acct: "altbot@other.example"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{response: "A white square"}
+			useProvider(t, provider)
+			c := newFakeClient()
+			useBotAccountID(t, c, "bot")
+			c.relationships[tt.account.ID] = &mastodon.Relationship{ID: tt.account.ID, FollowedBy: true}
+			status := &mastodon.Status{
+				ID:               mastodon.ID("own-" + tt.account.ID),
+				Account:          tt.account,
+				CreatedAt:        time.Now(),
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+			}
+			c.statuses[status.ID] = status
+			forgetReplies(t, c, status.ID)
+
+			handleUpdate(c, status)
+
+			if described := provider.calls() > 0; described != tt.described {
+				t.Errorf("described = %v, want %v", described, tt.described)
+			}
+			if dni := isDNI(c, &tt.account); dni == tt.described {
+				t.Errorf("isDNI = %v, want %v", dni, !tt.described)
+			}
+		})
+	}
+}
+
+func TestOwnMentionsAreIgnored(t *testing.T) {
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+	useNotificationCursors(t)
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+	bot := mastodon.Account{ID: "bot", Acct: "altbot"}
+	post := &mastodon.Status{
+		ID:               "looped-post",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+	}
+	reply := &mastodon.Status{ID: "own-reply", Account: bot, InReplyToID: "looped-post", Content: "<p>@poster @altbot A white square</p>", Visibility: "public"}
+	c := newFakeClient(post, reply)
+	useBotAccountID(t, c, "bot")
+	forgetReplies(t, c, post.ID)
+
+	handleNotification(c, &mastodon.Notification{ID: "own-mention", Type: "mention", Account: bot, Status: reply})
+
+	if posted := c.postedToots(); len(posted) != 0 || provider.calls() != 0 {
+		t.Errorf("answered its own mention with %+v", posted)
+	}
+}
+
+func TestRenderReplyTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"default", "", "@poster A cat.\n\nby @altbot"},
+		{"blank", " \n ", "@poster A cat.\n\nby @altbot"},
+		{"custom", "{{mention}}\n🖼️ {{descriptions}}\n— {{footer}}", "@poster\n🖼️ A cat.\n— by @altbot"},
+		{"no footer", "{{mention}} {{descriptions}}", "@poster A cat."},
+		{"repeated", "{{descriptions}} / {{descriptions}}", "A cat. / A cat."},
+		{"unknown placeholder", "{{mention}} {{weather}} {{descriptions}}", "@poster {{weather}} A cat."},
+		{"trimmed", "\n{{descriptions}}\n\n{{footer}}\n", "A cat.\n\nby @altbot"},
+	}
+	for _, tt := range tests {
+		if got := renderReplyTemplate(tt.template, "@poster", "A cat.", "by @altbot"); got != tt.want {
+			t.Errorf("%s: renderReplyTemplate = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplyTemplateIsUsedForReplies(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.RateLimit.Enabled = false
+		c.Behavior.ReplyTemplate = "{{mention}}\n[{{descriptions}}]"
+	})
+	useProvider(t, &fakeProvider{response: "A white square."})
+	status := &mastodon.Status{
+		ID:               "templated-post",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "templated-mention", Account: mastodon.Account{ID: "asker", Acct: "asker"}, Language: "en"})
+	forgetReplies(t, c, status.ID)
+
+	generateAndPostAltText(c, status, "templated-mention", nil)
+
+	if posted := c.postedToots(); len(posted) != 1 || posted[0].Status != "@asker\n[A white square.]" {
+		t.Errorf("posted %+v, want the reply rendered from the template", posted)
+	}
+}
+
+func TestMatchesHashtagFilters(t *testing.T) {
+	tags := func(names ...string) []mastodon.Tag {
+		var tags []mastodon.Tag
+		for _, name := range names {
+			tags = append(tags, mastodon.Tag{Name: name})
+		}
+		return tags
+	}
+	tests := []struct {
+		name    string
+		trigger []string
+		exclude []string
+		tags    []mastodon.Tag
+		want    bool
+	}{
+		{"no filters", nil, nil, tags("cats"), true},
+		{"no filters, no tags", nil, nil, nil, true},
+		{"trigger", []string{"#AltTextPlease"}, nil, tags("alttextplease"), true},
+		{"trigger without hash", []string{"describe"}, nil, tags("Describe"), true},
+		{"trigger missing", []string{"#AltTextPlease"}, nil, tags("cats"), false},
+		{"trigger missing, no tags", []string{"#AltTextPlease"}, nil, nil, false},
+		{"excluded", nil, []string{"#NoAltBot"}, tags("cats", "noaltbot"), false},
+		{"other tag than excluded", nil, []string{"#NoAltBot"}, tags("cats"), true},
+		{"excluded wins over trigger", []string{"#describe"}, []string{"#NoAltBot"}, tags("describe", "NoAltBot"), false},
+		{"partial name", []string{"#alt"}, nil, tags("alttext"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Behavior.TriggerHashtags = tt.trigger
+				c.Behavior.ExcludeHashtags = tt.exclude
+			})
+			if got := matchesHashtagFilters(&mastodon.Status{Tags: tt.tags}); got != tt.want {
+				t.Errorf("matchesHashtagFilters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtagFiltersOnlyApplyToAutomaticDescriptions(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.ExcludeHashtags = []string{"#NoAltBot"}
+		c.Behavior.AutoDescribeDelaySeconds = 0
+		c.RateLimit.Enabled = false
+	})
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+	status := &mastodon.Status{
+		ID:               "excluded-post",
+		Account:          mastodon.Account{ID: "tagger", Acct: "tagger"},
+		CreatedAt:        time.Now(),
+		Visibility:       "public",
+		Tags:             []mastodon.Tag{{Name: "noaltbot"}},
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "excluded-mention", Account: status.Account, InReplyToID: "excluded-post", Content: "<p>@altbot</p>", Language: "en"})
+	c.relationships["tagger"] = &mastodon.Relationship{ID: "tagger", FollowedBy: true}
+	forgetReplies(t, c, status.ID)
+
+	handleUpdate(c, status)
+	if provider.calls() != 0 {
+		t.Fatal("an excluded post was described automatically")
+	}
+
+	handleMention(c, &mastodon.Notification{Type: "mention", Account: status.Account, Status: c.statuses["excluded-mention"]})
+	if provider.calls() != 1 {
+		t.Errorf("the provider was called %d times, want the author's request answered", provider.calls())
+	}
+}
+
+func TestOneReplyPerImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		perImage bool
+		replies  int
+	}{
+		{"combined", false, 1},
+		{"per image", true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.RateLimit.Enabled = false
+				c.Behavior.OneReplyPerImage = tt.perImage
+			})
+			useProvider(t, &fakeProvider{response: "A white square."})
+			image := dataURI("image/png", testImage(t, 4, 4, color.White))
+			status := &mastodon.Status{
+				ID:         mastodon.ID("gallery-" + tt.name),
+				Account:    mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility: "public",
+				MediaAttachments: []mastodon.Attachment{
+					{ID: "1", Type: "image", URL: image}, {ID: "2", Type: "image", URL: image}, {ID: "3", Type: "image", URL: image},
+				},
+			}
+			c := newFakeClient(status, &mastodon.Status{ID: "gallery-mention", Account: mastodon.Account{ID: "asker", Acct: "asker"}, Language: "en"})
+			forgetReplies(t, c, status.ID)
+
+			generateAndPostAltText(c, status, "gallery-mention", nil)
+
+			posted := c.postedToots()
+			if len(posted) != tt.replies {
+				t.Fatalf("posted %d replies, want %d", len(posted), tt.replies)
+			}
+			for i, toot := range posted {
+				if n := strings.Count(toot.Status, "A white square."); n != 3/tt.replies {
+					t.Errorf("reply %d has %d descriptions, want %d", i, n, 3/tt.replies)
+				}
+				if !strings.HasPrefix(toot.Status, "@asker ") {
+					t.Errorf("reply %d = %q, want it to mention the asker", i, toot.Status)
+				}
+			}
+
+			// Every reply after the first continues the thread, which is remembered by its first reply
+			inReplyTo := mastodon.ID("gallery-mention")
+			for i, toot := range posted {
+				if toot.InReplyToID != inReplyTo {
+					t.Errorf("reply %d answers %v, want %s", i, toot.InReplyToID, inReplyTo)
+				}
+				inReplyTo = mastodon.ID(fmt.Sprintf("posted-%d", i+1))
+			}
+			mapMutex.Lock()
+			reply := replyMap[accountKey(c, status.ID)]
+			mapMutex.Unlock()
+			if reply.ReplyID != "posted-1" {
+				t.Errorf("remembered reply %s, want the first one", reply.ReplyID)
+			}
+		})
+	}
+}
+
+func TestStandaloneDelivery(t *testing.T) {
+	tests := []struct {
+		name       string
+		visibility string
+		want       string
+	}{
+		{"public", "public", "unlisted"},
+		{"direct", "direct", "direct"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.RateLimit.Enabled = false
+				c.Behavior.DeliveryMode = "Standalone"
+				c.Behavior.ReplyVisibility = "public"
+				c.Behavior.OneReplyPerImage = true
+			})
+			useProvider(t, &fakeProvider{response: "A white square."})
+			image := dataURI("image/png", testImage(t, 4, 4, color.White))
+			status := &mastodon.Status{
+				ID:               mastodon.ID("standalone-" + tt.name),
+				URL:              "https://example.social/@poster/" + tt.name,
+				Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility:       "public",
+				SpoilerText:      "squares",
+				MediaAttachments: []mastodon.Attachment{{ID: "1", Type: "image", URL: image}, {ID: "2", Type: "image", URL: image}},
+			}
+			mention := &mastodon.Status{ID: mastodon.ID("standalone-mention-" + tt.name), Account: mastodon.Account{ID: "asker", Acct: "asker"}, Visibility: tt.visibility, Language: "en"}
+			c := newFakeClient(status, mention)
+			forgetReplies(t, c, status.ID)
+
+			generateAndPostAltText(c, status, mention.ID, nil)
+
+			posted := c.postedToots()
+			if len(posted) != 2 {
+				t.Fatalf("posted %d statuses, want 2", len(posted))
+			}
+			if posted[0].InReplyToID != "" {
+				t.Errorf("standalone post answers %v, want it outside the thread", posted[0].InReplyToID)
+			}
+			if posted[1].InReplyToID != "posted-1" {
+				t.Errorf("second post answers %v, want it threaded below the standalone post", posted[1].InReplyToID)
+			}
+			if want := "@asker Alt-text for " + status.URL + "\n\n"; !strings.HasPrefix(posted[0].Status, want) {
+				t.Errorf("standalone post = %q, want it to start with %q", posted[0].Status, want)
+			}
+			for i, toot := range posted {
+				if toot.Visibility != tt.want {
+					t.Errorf("post %d is %s, want %s", i, toot.Visibility, tt.want)
+				}
+				if toot.SpoilerText != "re: squares" {
+					t.Errorf("post %d has content warning %q, want the one of the original post", i, toot.SpoilerText)
+				}
+			}
+		})
+	}
+}
+
+func TestBoostsAreNotDescribed(t *testing.T) {
+	bot := mastodon.Account{ID: "bot", Acct: "altbot"}
+	follower := mastodon.Account{ID: "booster", Acct: "booster"}
+	stranger := mastodon.Account{ID: "stranger", Acct: "stranger"}
+	tests := []struct {
+		name      string
+		booster   mastodon.Account
+		author    mastodon.Account
+		boost     bool
+		described bool
+	}{
+		{"bot boosts its own post", bot, bot, true, false},
+		{"bot boosts a post", bot, stranger, true, false},
+		{"follower boosts the bot's post", follower, bot, true, false},
+		{"follower boosts a post", follower, stranger, true, false},
+		{"follower's own post", follower, follower, false, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Behavior.AutoDescribeDelaySeconds = 0
+				c.RateLimit.Enabled = false
+			})
+			provider := &fakeProvider{response: "A white square"}
+			useProvider(t, provider)
+			c := newFakeClient()
+			useBotAccountID(t, c, "bot")
+			c.relationships["booster"] = &mastodon.Relationship{ID: "booster", FollowedBy: true}
+
+			original := &mastodon.Status{
+				ID:               mastodon.ID(fmt.Sprintf("boosted-%d", i)),
+				Account:          tt.author,
+				CreatedAt:        time.Now(),
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+			}
+			update := original
+			if tt.boost {
+				// A boost repeats the media of the boosted post
+				update = &mastodon.Status{ID: mastodon.ID(fmt.Sprintf("boost-%d", i)), Account: tt.booster, CreatedAt: time.Now(),
+					Visibility: "public", Reblog: original, MediaAttachments: original.MediaAttachments}
+			}
+			c.statuses[original.ID], c.statuses[update.ID] = original, update
+			forgetReplies(t, c, original.ID, update.ID)
+
+			handleUpdate(c, update)
+
+			if described := provider.calls() > 0; described != tt.described {
+				t.Errorf("described = %v, want %v", described, tt.described)
+			}
+		})
+	}
+}
+
+func TestGeminiImageFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"jpeg", "jpeg"},
+		{"jpg", "jpeg"},
+		{".JPG", "jpeg"},
+		{"png", "png"},
+		{"webp", "webp"},
+		{"HEIC", "heic"},
+		{"heif", "heif"},
+		{"gif", ""},
+		{"bmp", ""},
+		{"image/png", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := geminiImageFormat(tt.format)
+		if got != tt.want {
+			t.Errorf("geminiImageFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+		if unsupported := errors.Is(err, ErrUnsupportedFormat); unsupported != (tt.want == "") {
+			t.Errorf("geminiImageFormat(%q) error = %v", tt.format, err)
+		}
+	}
+}
+
+func TestReplyDelayStaysInRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int
+		low      time.Duration
+		high     time.Duration
+	}{
+		{"range", 2, 5, 2 * time.Second, 5 * time.Second},
+		{"fixed", 3, 3, 3 * time.Second, 3 * time.Second},
+		{"max below min", 4, 1, 4 * time.Second, 4 * time.Second},
+		{"negative", -2, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Behavior.ReplyDelayMin = tt.min
+				c.Behavior.ReplyDelayMax = tt.max
+			})
+
+			// The extremes of the random source give the extremes of the range
+			saved := replyJitter
+			t.Cleanup(func() { replyJitter = saved })
+			for _, jitter := range []func(int64) int64{
+				func(int64) int64 { return 0 },
+				func(n int64) int64 { return n - 1 },
+				saved,
+			} {
+				replyJitter = jitter
+				if delay := replyDelay(); delay < tt.low || delay > tt.high {
+					t.Errorf("replyDelay = %v, want between %v and %v", delay, tt.low, tt.high)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitBeforeReplyStopsWhenCancelled(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.ReplyDelayMin = 60
+		c.Behavior.ReplyDelayMax = 60
+	})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if waitBeforeReply(cancelled) {
+		t.Error("waitBeforeReply waited for a cancelled context")
+	}
+}
+
+func TestSlowProviderTimesOut(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.LLM.PerAttachmentTimeoutSeconds = 1
+		c.Behavior.ReplyOnError = true
+		c.RateLimit.Enabled = false
+	})
+	useProvider(t, &fakeProvider{response: "Too late", delay: 3 * time.Second})
+
+	status := &mastodon.Status{
+		ID:               "slow-post",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "slow-mention", Account: status.Account, Language: "en"})
+	forgetReplies(t, c, status.ID)
+
+	start := time.Now()
+	generateAndPostAltText(c, status, "slow-mention", nil)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("replying took %v with a timeout of 1s", elapsed)
+	}
+	posted := c.postedToots()
+	timeoutMessage := getLocalizedString("en", "attachmentTimeout", "response")
+	if len(posted) != 1 || !strings.Contains(posted[0].Status, timeoutMessage) || strings.Contains(posted[0].Status, "Too late") {
+		t.Errorf("posted %+v, want the timeout message", posted)
+	}
+}
+
+func TestStripHTMLTags(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"<p>Hello world</p>", "Hello world"},
+		{"<p>First line<br />second line</p><p>New paragraph</p>", "First line\nsecond line\nNew paragraph"},
+		{`<p><span class="h-card"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> Fish &amp; chips &lt;3</p>`, "@altbot Fish & chips <3"},
+		{`<p>Look <a href="https://example.social/tags/cats" class="mention hashtag" rel="tag">#<span>cats</span></a> <a href="https://example.org/a" rel="nofollow noopener"><span class="invisible">https://</span><span class="">example.org/a</span></a></p>`, "Look #cats https://example.org/a"},
+		{"plain text", "plain text"},
+	}
+	for _, tt := range tests {
+		if got := stripHTMLTags(tt.content); got != tt.want {
+			t.Errorf("stripHTMLTags(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// isMediaTypeAllowed checks an attachment against the configured allowed and blocked media types.
+// Entries can be a Mastodon attachment type ("audio"), a file extension ("mp3") or a MIME type
+// ("audio/mpeg", "audio/*").
+func isMediaTypeAllowed(attachment mastodon.Attachment) bool {
+	for _, entry := range config.ImageProcessing.BlockedMediaTypes {
+		if mediaTypeMatches(attachment, entry) {
+			return false
+		}
+	}
+
+	if len(config.ImageProcessing.AllowedMediaTypes) == 0 {
+		return true
+	}
+
+	for _, entry := range config.ImageProcessing.AllowedMediaTypes {
+		if mediaTypeMatches(attachment, entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mediaTypeMatches reports whether a single allow/block list entry matches the attachment
+func mediaTypeMatches(attachment mastodon.Attachment, entry string) bool {
+	entry = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(entry), "."))
+	if entry == "" {
+		return false
+	}
+
+	if entry == strings.ToLower(attachment.Type) {
+		return true
+	}
+
+	extension := attachmentExtension(attachment)
+	if extension == "" {
+		return false
+	}
+	if entry == extension {
+		return true
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension("." + extension))
+	if mimeType == "" {
+		return false
+	}
+	if strings.HasSuffix(entry, "/*") {
+		return strings.HasPrefix(mimeType, strings.TrimSuffix(entry, "*"))
+	}
+	return entry == mimeType
+}
+
+// attachmentExtension returns the lowercase file extension of the attachment URL without the dot
+func attachmentExtension(attachment mastodon.Attachment) string {
+	parsedURL, err := url.Parse(attachment.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(path.Ext(parsedURL.Path), "."))
+}
+
+// maxSizeMBForURL returns the size limit for media from the URL's host, using the override of the
+// host or its closest listed parent domain if there is one
+func maxSizeMBForURL(mediaURL string) uint {
+	u, err := url.Parse(mediaURL)
+	if err != nil {
+		return config.ImageProcessing.MaxSizeMB
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if limit, ok := config.ImageProcessing.MaxSizeMBOverrides[host]; ok {
+			return limit
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return config.ImageProcessing.MaxSizeMB
+}
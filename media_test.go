@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestMaxSizeMBForURL(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.ImageProcessing.MaxSizeMB = 10
+		c.ImageProcessing.MaxSizeMBOverrides = map[string]uint{"media.example.org": 500, "example.net": 50}
+	})
+
+	tests := []struct {
+		url  string
+		want uint
+	}{
+		{"https://media.example.org/a.png", 500},
+		{"https://MEDIA.example.org:8443/a.png", 500},
+		{"https://cdn.media.example.org/a.png", 500},
+		{"https://example.org/a.png", 10},
+		{"https://files.example.net/a.mp4", 50},
+		{"https://example.net.evil.test/a.mp4", 10},
+		{"https://notexample.net/a.mp4", 10},
+		{"::not a url", 10},
+	}
+	for _, tt := range tests {
+		if got := maxSizeMBForURL(tt.url); got != tt.want {
+			t.Errorf("maxSizeMBForURL(%q) = %d, want %d", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSizeOverrideAppliesToDownloads(t *testing.T) {
+	media := bytes.Repeat([]byte("a"), 2*1024*1024)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(media)
+	}))
+	t.Cleanup(server.Close)
+	saved := mediaHTTPClient
+	mediaHTTPClient = server.Client()
+	t.Cleanup(func() { mediaHTTPClient = saved })
+
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxSizeMB = 1 })
+	if _, err := fetchMedia(server.URL+"/large", ""); err == nil {
+		t.Error("a file over the limit was downloaded from a host without an override")
+	}
+
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxSizeMBOverrides = map[string]uint{"127.0.0.1": 3} })
+	if data, err := fetchMedia(server.URL+"/large", ""); err != nil || len(data) != len(media) {
+		t.Errorf("fetchMedia = %d bytes, %v from the trusted host", len(data), err)
+	}
+}
+
+func TestIsMediaTypeAllowed(t *testing.T) {
+	png := mastodon.Attachment{Type: "image", URL: "https://files.example/media/cat.PNG?size=original"}
+	jpeg := mastodon.Attachment{Type: "image", URL: "https://files.example/media/cat.jpg"}
+	audio := mastodon.Attachment{Type: "audio", URL: "https://files.example/media/song.mp3"}
+	video := mastodon.Attachment{Type: "video", URL: "https://files.example/media/clip"}
+
+	tests := []struct {
+		name       string
+		allowed    []string
+		blocked    []string
+		attachment mastodon.Attachment
+		want       bool
+	}{
+		{"no lists", nil, nil, audio, true},
+		{"blocked type", nil, []string{"audio"}, audio, false},
+		{"other type than blocked", nil, []string{"audio"}, png, true},
+		{"blocked extension", nil, []string{".png"}, png, false},
+		{"blocked mime type", nil, []string{"image/jpeg"}, jpeg, false},
+		{"blocked mime wildcard", nil, []string{"image/*"}, png, false},
+		{"mime wildcard of another type", nil, []string{"video/*"}, png, true},
+		{"allowed type", []string{"image"}, nil, jpeg, true},
+		{"not allowed", []string{"image"}, nil, video, false},
+		{"allowed extension", []string{"mp3"}, nil, audio, true},
+		{"without extension", []string{"mp4"}, nil, video, false},
+		{"blocked wins", []string{"image"}, []string{"png"}, png, false},
+		{"blank entry", nil, []string{" "}, png, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.ImageProcessing.AllowedMediaTypes = tt.allowed
+				c.ImageProcessing.BlockedMediaTypes = tt.blocked
+			})
+			if got := isMediaTypeAllowed(tt.attachment); got != tt.want {
+				t.Errorf("isMediaTypeAllowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockedMediaTypeIsSkipped(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.RateLimit.Enabled = false
+		c.ImageProcessing.BlockedMediaTypes = []string{"audio"}
+	})
+	useVideoAudioCapability(t, true)
+	provider := &fakeProvider{response: "A white square", media: map[string]bool{"audio": true}}
+	useProvider(t, provider)
+
+	status := &mastodon.Status{
+		ID:         "mixed-media",
+		Account:    mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility: "public",
+		MediaAttachments: []mastodon.Attachment{
+			{ID: "a", Type: "audio", URL: dataURI("audio/mpeg", []byte("not really audio"))},
+			{ID: "b", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))},
+		},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "mention", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "en"})
+	forgetReplies(t, c, status.ID)
+
+	generateAndPostAltText(c, status, "mention", nil)
+
+	if provider.calls() != 1 {
+		t.Errorf("the provider was called %d times, want only the image described", provider.calls())
+	}
+	if posted := c.postedToots(); len(posted) != 1 || !strings.Contains(posted[0].Status, "A white square") {
+		t.Errorf("posted %+v, want the image's description", posted)
+	}
+}
@@ -135,6 +135,26 @@ func (mm *MetricsManager) logSuccessfulGeneration(userID, mediaType string, resp
 	mm.logEvent(userID, "successful_generation", details)
 }
 
+// logFailedGeneration logs a failed alt-text generation with the category of its error
+func (mm *MetricsManager) logFailedGeneration(userID, mediaType string, category ErrorCategory) {
+	details := map[string]interface{}{
+		"mediaType": mediaType,
+		"category":  string(category),
+	}
+	mm.logEvent(userID, "failed_generation", details)
+}
+
+// logProviderLatency logs how long a single LLM provider call took
+func (mm *MetricsManager) logProviderLatency(provider, mediaType string, responseTimeMillis int64, success bool) {
+	details := map[string]interface{}{
+		"provider":     provider,
+		"mediaType":    mediaType,
+		"responseTime": responseTimeMillis,
+		"success":      success,
+	}
+	mm.logEvent(config.Server.Username, "provider_latency", details)
+}
+
 // logRateLimitHit logs when a rate limit is hit
 func (mm *MetricsManager) logRateLimitHit(userID string) {
 	mm.logEvent(userID, "rate_limit_hit", nil)
@@ -164,12 +184,23 @@ func (mm *MetricsManager) logAltTextReminderSent(userID string) {
 	mm.logEvent(userID, "alt_text_reminder_sent", nil)
 }
 
-// logConsentRequest logs a consent request
+// logConsentRequested logs that the original poster was asked for consent
+func (mm *MetricsManager) logConsentRequested(userID string) {
+	mm.logEvent(userID, "consent_requested", nil)
+}
+
+// logConsentRequest logs the original poster's answer to a consent request
 func (mm *MetricsManager) logConsentRequest(userID string, granted bool) {
-	details := map[string]interface{}{
-		"granted": granted,
+	eventType := "consent_denied"
+	if granted {
+		eventType = "consent_granted"
 	}
-	mm.logEvent(userID, "consent_request", details)
+	mm.logEvent(userID, eventType, nil)
+}
+
+// logConsentExpired logs a consent request that was never answered
+func (mm *MetricsManager) logConsentExpired(userID string) {
+	mm.logEvent(userID, "consent_expired", nil)
 }
 
 // saveToFile writes the current metrics data to a file
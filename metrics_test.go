@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// eventsOfType returns the logged events of the type
+func eventsOfType(mm *MetricsManager, eventType string) []MetricEvent {
+	mm.fileMutex.Lock()
+	defer mm.fileMutex.Unlock()
+	var events []MetricEvent
+	for _, event := range mm.logs {
+		if event.EventType == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestProcessingDurationIsRecorded(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		success bool
+	}{
+		{"success", nil, true},
+		{"failure", errors.New("the model fell over"), false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+			mm := useMetrics(t)
+			useProvider(t, &fakeProvider{name: "slowpoke", response: "A white square", err: tt.err, delay: 30 * time.Millisecond})
+			status := &mastodon.Status{
+				ID:               mastodon.ID("timed-" + tt.name),
+				Account:          mastodon.Account{ID: "timed-poster", Acct: "timed-poster"},
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 9+i, color.White))}},
+			}
+			c := newFakeClient(status, &mastodon.Status{ID: "timed-mention", Account: status.Account, Language: "en"})
+			forgetReplies(t, c, status.ID)
+
+			generateAndPostAltText(c, status, "timed-mention", nil)
+
+			latencies := eventsOfType(mm, "provider_latency")
+			if len(latencies) != 1 {
+				t.Fatalf("logged %d provider latencies, want 1", len(latencies))
+			}
+			details := latencies[0].Details
+			if details["provider"] != "slowpoke" || details["mediaType"] != "image" || details["success"] != tt.success {
+				t.Errorf("provider latency = %+v, want the image call of slowpoke with success %v", details, tt.success)
+			}
+			if ms, _ := details["responseTime"].(int64); ms < 30 {
+				t.Errorf("provider latency = %v ms, want at least the 30 ms the call took", details["responseTime"])
+			}
+
+			generations := eventsOfType(mm, "successful_generation")
+			if !tt.success {
+				if len(generations) != 0 {
+					t.Errorf("logged a successful generation for a failure")
+				}
+				return
+			}
+			if len(generations) != 1 {
+				t.Fatalf("logged %d successful generations, want 1", len(generations))
+			}
+			if ms, _ := generations[0].Details["responseTime"].(int64); ms < 30 {
+				t.Errorf("generation took %v ms, want at least 30", generations[0].Details["responseTime"])
+			}
+		})
+	}
+}
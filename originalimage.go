@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxOriginalImageSize limits the original images sent in place of a downscaled one,
+// Gemini rejects inline requests larger than 20 MB
+const maxOriginalImageSize = 20 * 1024 * 1024
+
+// downscaleOrOriginal downscales the decoded image for the provider. If the image couldn't be
+// decoded or downscaled, e.g. a HEIC photo, the original bytes are sent instead as long as the
+// provider accepts their format.
+func downscaleOrOriginal(imageURL string, img []byte, decoded image.Image, sourceFormat string, decodeErr error) ([]byte, string, error) {
+	err := decodeErr
+	if err == nil {
+		var downscaled []byte
+		var format string
+		downscaled, format, err = downscaleImage(decoded, sourceFormat, config.ImageProcessing.DownscaleWidth, imageUploadFormat())
+		if err == nil {
+			return downscaled, format, nil
+		}
+	}
+
+	format, ok := originalImageFormat(img)
+	if !ok {
+		return nil, "", err
+	}
+	log.Printf("Couldn't downscale image %s, sending the original %s image instead: %v", loggableURL(imageURL), format, err)
+	return img, format, nil
+}
+
+// originalImageFormat returns the format of the image if the image provider accepts the original bytes
+func originalImageFormat(img []byte) (string, bool) {
+	if len(img) > maxOriginalImageSize {
+		return "", false
+	}
+
+	format := sniffImageFormat(img)
+	if format == "" {
+		return "", false
+	}
+
+	provider, err := activeProvider("image")
+	return format, err == nil && provider.AcceptsImageFormat(format)
+}
+
+// sniffImageFormat recognizes the image format from the file's first bytes, "" if it isn't an image
+func sniffImageFormat(img []byte) string {
+	// HEIC and HEIF files are ISO media files, told apart by the brand of their ftyp box
+	if len(img) >= 12 && string(img[4:8]) == "ftyp" {
+		switch string(img[8:12]) {
+		case "heic", "heix", "heim", "heis":
+			return "heic"
+		case "mif1", "msf1", "heif":
+			return "heif"
+		}
+		return ""
+	}
+
+	if format, ok := strings.CutPrefix(http.DetectContentType(img), "image/"); ok {
+		return format
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// heicHeader is the start of a HEIC photo, which the Go decoders can't read
+var heicHeader = []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+
+func TestDownscaleOrOriginal(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.DownscaleWidth = 4 })
+	png := testImage(t, 8, 8, color.White)
+	decoded, format, err := decodeImage(png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errDecode := errors.New("image: unknown format")
+
+	tests := []struct {
+		name       string
+		formats    map[string]bool
+		img        []byte
+		decoded    image.Image
+		format     string
+		decodeErr  error
+		wantFormat string
+		original   bool
+	}{
+		{"downscaled", nil, png, decoded, format, nil, "png", false},
+		{"undecodable accepted", map[string]bool{"heic": true}, heicHeader, nil, "", errDecode, "heic", true},
+		{"undecodable not accepted", nil, heicHeader, nil, "", errDecode, "", false},
+		{"not downscalable", nil, png, decoded, "svg", nil, "png", true},
+		{"too large", map[string]bool{"heic": true}, append(heicHeader, make([]byte, maxOriginalImageSize)...), nil, "", errDecode, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, &fakeProvider{formats: tt.formats})
+
+			data, format, err := downscaleOrOriginal("https://example.social/image", tt.img, tt.decoded, tt.format, tt.decodeErr)
+			if tt.wantFormat == "" {
+				if !errors.Is(err, tt.decodeErr) {
+					t.Errorf("downscaleOrOriginal = %s, %v, want the decode error", format, err)
+				}
+				return
+			}
+			if err != nil || format != tt.wantFormat {
+				t.Fatalf("downscaleOrOriginal = %s, %v, want %s", format, err, tt.wantFormat)
+			}
+			if sentOriginal := bytes.Equal(data, tt.img); sentOriginal != tt.original {
+				t.Errorf("sent the original = %v, want %v", sentOriginal, tt.original)
+			}
+		})
+	}
+}
+
+func TestSniffImageFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", testImage(t, 2, 2, color.Black), "png"},
+		{"heic", heicHeader, "heic"},
+		{"heif", []byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00"), "heif"},
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x00\x00"), ""},
+		{"text", []byte("hello"), ""},
+	}
+	for _, tt := range tests {
+		if got := sniffImageFormat(tt.data); got != tt.want {
+			t.Errorf("sniffImageFormat(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
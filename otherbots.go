@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// accountHandle returns the full user@domain handle of the account, also for local accounts
+func accountHandle(account *mastodon.Account) string {
+	if strings.Contains(account.Acct, "@") {
+		return strings.ToLower(account.Acct)
+	}
+	if parsedURL, err := url.Parse(account.URL); err == nil && parsedURL.Host != "" {
+		return strings.ToLower(account.Acct + "@" + parsedURL.Host)
+	}
+	return strings.ToLower(account.Acct)
+}
+
+// isKnownAltTextBot reports whether the account is one of the configured alt-text bots
+func isKnownAltTextBot(account *mastodon.Account) bool {
+	handle := accountHandle(account)
+	for _, bot := range config.Behavior.KnownAltTextBots {
+		if strings.ToLower(strings.TrimPrefix(bot, "@")) == handle {
+			return true
+		}
+	}
+	return false
+}
+
+// findOtherBotReply returns the reply of another known alt-text bot to the status, or nil if there is none
+func findOtherBotReply(c MastodonClient, status *mastodon.Status) *mastodon.Status {
+	if len(config.Behavior.KnownAltTextBots) == 0 {
+		return nil
+	}
+
+	context, err := c.GetStatusContext(ctx, status.ID)
+	if err != nil {
+		log.Printf("Error fetching replies of status %s: %v", status.ID, err)
+		return nil
+	}
+
+	for _, reply := range context.Descendants {
+		if inReplyTo(reply.InReplyToID) == status.ID && !isSelf(c, &reply.Account) && isKnownAltTextBot(&reply.Account) {
+			return reply
+		}
+	}
+	return nil
+}
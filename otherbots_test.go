@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestAccountHandle(t *testing.T) {
+	tests := []struct {
+		account mastodon.Account
+		want    string
+	}{
+		{mastodon.Account{Acct: "AltBot@Other.Example"}, "altbot@other.example"},
+		{mastodon.Account{Acct: "altbot", URL: "https://main.example/@altbot"}, "altbot@main.example"},
+		{mastodon.Account{Acct: "altbot"}, "altbot"},
+	}
+	for _, tt := range tests {
+		if got := accountHandle(&tt.account); got != tt.want {
+			t.Errorf("accountHandle(%s) = %s, want %s", tt.account.Acct, got, tt.want)
+		}
+	}
+}
+
+func TestFindOtherBotReply(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Behavior.KnownAltTextBots = []string{"@AltBot@other.example"} })
+	otherBot := mastodon.Account{ID: "other-bot", Acct: "altbot@other.example"}
+
+	c := newFakeClient()
+	c.contexts["post"] = &mastodon.Context{Descendants: []*mastodon.Status{
+		{ID: "human", InReplyToID: "post", Account: mastodon.Account{ID: "human", Acct: "human@other.example"}},
+		{ID: "nested", InReplyToID: "human", Account: otherBot},
+		{ID: "bot-reply", InReplyToID: "post", Account: otherBot},
+	}}
+	c.contexts["thread"] = &mastodon.Context{Descendants: []*mastodon.Status{
+		{ID: "nested", InReplyToID: "human", Account: otherBot},
+	}}
+
+	if reply := findOtherBotReply(c, &mastodon.Status{ID: "post"}); reply == nil || reply.ID != "bot-reply" {
+		t.Errorf("findOtherBotReply = %v, want the bot's direct reply", reply)
+	}
+	if reply := findOtherBotReply(c, &mastodon.Status{ID: "thread"}); reply != nil {
+		t.Errorf("findOtherBotReply = %s, a reply further down the thread doesn't answer the post", reply.ID)
+	}
+
+	withConfig(t, func(c *Config) { c.Behavior.KnownAltTextBots = nil })
+	if reply := findOtherBotReply(c, &mastodon.Status{ID: "post"}); reply != nil {
+		t.Errorf("findOtherBotReply = %s without any known bots", reply.ID)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// rgb is a color with 8 bits per channel
+type rgb struct {
+	R, G, B float64
+}
+
+// namedColors are the names dominant colors are mapped to
+var namedColors = []struct {
+	name  string
+	color rgb
+}{
+	{"black", rgb{0, 0, 0}},
+	{"white", rgb{255, 255, 255}},
+	{"gray", rgb{128, 128, 128}},
+	{"silver", rgb{192, 192, 192}},
+	{"red", rgb{220, 20, 60}},
+	{"maroon", rgb{128, 0, 0}},
+	{"orange", rgb{255, 140, 0}},
+	{"yellow", rgb{255, 215, 0}},
+	{"olive", rgb{128, 128, 0}},
+	{"green", rgb{34, 139, 34}},
+	{"lime", rgb{50, 205, 50}},
+	{"teal", rgb{0, 128, 128}},
+	{"cyan", rgb{0, 200, 200}},
+	{"blue", rgb{30, 90, 220}},
+	{"navy", rgb{0, 0, 128}},
+	{"purple", rgb{128, 0, 128}},
+	{"magenta", rgb{220, 0, 220}},
+	{"pink", rgb{255, 160, 190}},
+	{"brown", rgb{139, 69, 19}},
+	{"beige", rgb{235, 220, 185}},
+}
+
+// maxPaletteSamples bounds the number of pixels that are clustered
+const maxPaletteSamples = 4096
+
+// dominantColors finds the k most common colors of the image with k-means clustering on a grid of
+// sampled pixels, ordered from the largest cluster to the smallest. Transparent pixels are ignored.
+func dominantColors(img image.Image, k int) []rgb {
+	bounds := img.Bounds()
+	if k <= 0 || bounds.Empty() {
+		return nil
+	}
+
+	step := int(math.Ceil(math.Sqrt(float64(bounds.Dx()*bounds.Dy()) / maxPaletteSamples)))
+	if step < 1 {
+		step = 1
+	}
+
+	var samples []rgb
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			samples = append(samples, rgb{float64(r >> 8), float64(g >> 8), float64(b >> 8)})
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	// Start from distinct colors spread evenly over the brightness range, so the result is
+	// deterministic and no two clusters start out the same
+	seen := make(map[rgb]bool)
+	var sorted []rgb
+	for _, sample := range samples {
+		if !seen[sample] {
+			seen[sample] = true
+			sorted = append(sorted, sample)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return luminance(sorted[i]) < luminance(sorted[j]) })
+	if k > len(sorted) {
+		k = len(sorted)
+	}
+	centroids := make([]rgb, k)
+	for i := range centroids {
+		centroids[i] = sorted[(2*i+1)*len(sorted)/(2*k)]
+	}
+
+	assignments := make([]int, len(samples))
+	counts := make([]int, k)
+	for iteration := 0; iteration < 10; iteration++ {
+		sums := make([]rgb, k)
+		counts = make([]int, k)
+		for i, sample := range samples {
+			nearest := nearestColor(sample, centroids)
+			assignments[i] = nearest
+			sums[nearest].R += sample.R
+			sums[nearest].G += sample.G
+			sums[nearest].B += sample.B
+			counts[nearest]++
+		}
+
+		for i := range centroids {
+			if counts[i] > 0 {
+				centroids[i] = rgb{sums[i].R / float64(counts[i]), sums[i].G / float64(counts[i]), sums[i].B / float64(counts[i])}
+			}
+		}
+	}
+
+	order := make([]int, 0, k)
+	for i := range centroids {
+		if counts[i] > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+
+	colors := make([]rgb, len(order))
+	for i, index := range order {
+		colors[i] = centroids[index]
+	}
+	return colors
+}
+
+// nearestColor returns the index of the color closest to c
+func nearestColor(c rgb, colors []rgb) int {
+	nearest := 0
+	best := math.MaxFloat64
+	for i, other := range colors {
+		dr, dg, db := c.R-other.R, c.G-other.G, c.B-other.B
+		if distance := dr*dr + dg*dg + db*db; distance < best {
+			best = distance
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+func luminance(c rgb) float64 {
+	return 0.299*c.R + 0.587*c.G + 0.114*c.B
+}
+
+// colorName returns the name of the named color closest to c
+func colorName(c rgb) string {
+	palette := make([]rgb, len(namedColors))
+	for i, named := range namedColors {
+		palette[i] = named.color
+	}
+	return namedColors[nearestColor(c, palette)].name
+}
+
+// hex formats the color as #rrggbb
+func (c rgb) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", uint8(math.Round(c.R)), uint8(math.Round(c.G)), uint8(math.Round(c.B)))
+}
+
+// describePalette returns a localized line listing the dominant colors of the image by name and hex code
+func describePalette(img image.Image, lang string) string {
+	colors := dominantColors(img, config.ImageProcessing.PaletteColors)
+	if len(colors) == 0 {
+		return ""
+	}
+
+	entries := make([]string, len(colors))
+	for i, c := range colors {
+		entries[i] = fmt.Sprintf("%s (%s)", colorName(c), c.hex())
+	}
+	return fmt.Sprintf(getLocalizedString(lang, "colorPalette", "response"), strings.Join(entries, ", "))
+}
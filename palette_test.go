@@ -0,0 +1,79 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// stripes returns an image split into vertical stripes, each as wide as its weight
+func stripes(colors []color.Color, weights []int) image.Image {
+	width := 0
+	for _, weight := range weights {
+		width += weight
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, 10))
+	x := 0
+	for i, c := range colors {
+		for end := x + weights[i]; x < end; x++ {
+			for y := 0; y < 10; y++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+func TestDominantColorsLargestFirst(t *testing.T) {
+	red := color.RGBA{220, 20, 60, 255}
+	navy := color.RGBA{0, 0, 128, 255}
+	img := stripes([]color.Color{red, color.White, navy}, []int{20, 50, 30})
+
+	colors := dominantColors(img, 3)
+	if len(colors) != 3 {
+		t.Fatalf("found %d colors, want 3", len(colors))
+	}
+	for i, want := range []string{"#ffffff", "#000080", "#dc143c"} {
+		if got := colors[i].hex(); got != want {
+			t.Errorf("color %d = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestDominantColorsIgnoresTransparency(t *testing.T) {
+	img := stripes([]color.Color{color.Transparent, color.Black}, []int{90, 10})
+
+	if colors := dominantColors(img, 2); len(colors) != 1 || colors[0].hex() != "#000000" {
+		t.Errorf("colors = %v, want only black", colors)
+	}
+	if colors := dominantColors(stripes([]color.Color{color.Transparent}, []int{10}), 2); colors != nil {
+		t.Errorf("colors of a transparent image = %v", colors)
+	}
+}
+
+func TestColorName(t *testing.T) {
+	tests := []struct {
+		color rgb
+		want  string
+	}{
+		{rgb{5, 5, 5}, "black"},
+		{rgb{250, 250, 250}, "white"},
+		{rgb{200, 30, 50}, "red"},
+		{rgb{40, 80, 200}, "blue"},
+		{rgb{240, 170, 200}, "pink"},
+	}
+	for _, tt := range tests {
+		if got := colorName(tt.color); got != tt.want {
+			t.Errorf("colorName(%v) = %s, want %s", tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestDescribePalette(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.PaletteColors = 2 })
+	img := stripes([]color.Color{color.White, color.Black}, []int{60, 40})
+
+	if got, want := describePalette(img, "en"), "Dominant colors: white (#ffffff), black (#000000)"; got != want {
+		t.Errorf("describePalette = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// patrolSeen remembers the posts the patrol already looked at by accountKey, so each is handled only once
+var patrolSeen = make(map[string]time.Time)
+var patrolSeenMutex sync.Mutex
+
+// startPatrol periodically scans a timeline for recent posts with undescribed media
+func startPatrol(c MastodonClient, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if isPaused() {
+			continue
+		}
+		patrolOnce(c)
+	}
+}
+
+// patrolOnce runs a single scan of the configured timeline
+func patrolOnce(c MastodonClient) {
+	statuses, err := fetchPatrolTimeline(c)
+	if err != nil {
+		log.Printf("Error fetching timeline for patrol: %v", err)
+		return
+	}
+
+	for _, status := range selectPatrolCandidates(c, statuses, time.Now()) {
+		if blockedBy.Contains(c, status.Account.ID) {
+			continue
+		}
+		if hops := config.Behavior.SkipBelowOwnReplies; hops > 0 && isBelowOwnReply(c, status, hops) {
+			continue
+		}
+		if isStillFollower(c, &status.Account) {
+			// Followers opted in to automatic descriptions
+			generateAndPostAltText(c, status, status.ID, nil)
+		} else if config.Patrol.OfferToNonFollowers {
+			offerDescription(c, status)
+		}
+	}
+}
+
+// fetchPatrolTimeline returns the latest posts of the timeline selected by patrol.scope
+func fetchPatrolTimeline(c MastodonClient) ([]*mastodon.Status, error) {
+	switch strings.ToLower(config.Patrol.Scope) {
+	case "", "home":
+		return c.GetTimelineHome(ctx, nil)
+	case "local":
+		return c.GetTimelinePublic(ctx, true, nil)
+	case "tag":
+		return c.GetTimelineHashtag(ctx, strings.TrimPrefix(config.Patrol.Tag, "#"), false, nil)
+	default:
+		return nil, fmt.Errorf("unknown patrol scope: %s", config.Patrol.Scope)
+	}
+}
+
+// selectPatrolCandidates returns the recent, not yet seen posts with media that needs a description,
+// at most patrol.max_posts_per_run of them. Boosts are resolved to the boosted post.
+func selectPatrolCandidates(c MastodonClient, statuses []*mastodon.Status, now time.Time) []*mastodon.Status {
+	maxAge := time.Duration(config.Patrol.MaxAgeMinutes) * time.Minute
+
+	patrolSeenMutex.Lock()
+	defer patrolSeenMutex.Unlock()
+
+	// Forget posts that are too old to be picked up again anyway
+	retention := maxAge
+	if retention <= 0 {
+		retention = 24 * time.Hour
+	}
+	for id, seenAt := range patrolSeen {
+		if now.Sub(seenAt) > retention {
+			delete(patrolSeen, id)
+		}
+	}
+
+	var candidates []*mastodon.Status
+	for _, status := range statuses {
+		if config.Patrol.MaxPostsPerRun > 0 && len(candidates) >= config.Patrol.MaxPostsPerRun {
+			break
+		}
+		if status.Reblog != nil {
+			status = status.Reblog
+		}
+
+		if _, seen := patrolSeen[accountKey(c, status.ID)]; seen {
+			continue
+		}
+		if maxAge > 0 && now.Sub(status.CreatedAt) > maxAge {
+			continue
+		}
+		// Direct and followers-only posts weren't shared with the bot to be answered by it
+		if status.Visibility != "public" && status.Visibility != "unlisted" {
+			continue
+		}
+		if isDNI(c, &status.Account) || isPostDNI(status) || !matchesHashtagFilters(status) {
+			continue
+		}
+		consentMutex.Lock()
+		_, pending := consentRequests[accountKey(c, status.ID)]
+		consentMutex.Unlock()
+		if pending {
+			continue
+		}
+		if !hasUndescribedMedia(status) {
+			continue
+		}
+
+		patrolSeen[accountKey(c, status.ID)] = now
+		candidates = append(candidates, status)
+	}
+
+	return candidates
+}
+
+// hasUndescribedMedia reports whether any attachment of the status needs a description
+func hasUndescribedMedia(status *mastodon.Status) bool {
+	for _, attachment := range status.MediaAttachments {
+		if isMediaTypeAllowed(attachment) && needsGeneration(attachment) {
+			return true
+		}
+	}
+	return false
+}
+
+// offerDescription asks the poster whether they want a description, using the consent flow
+func offerDescription(c MastodonClient, status *mastodon.Status) {
+	// A mention may have asked the poster since the post was selected
+	consentMutex.Lock()
+	_, asked := consentRequests[accountKey(c, status.ID)]
+	if !asked {
+		consentRequests[accountKey(c, status.ID)] = ConsentRequest{
+			RequestID: status.ID,
+			PosterID:  status.Account.ID,
+			Timestamp: time.Now(),
+		}
+	}
+	consentMutex.Unlock()
+
+	if asked {
+		return
+	}
+	metricsManager.logConsentRequested(string(status.Account.ID))
+
+	message := fmt.Sprintf(getLocalizedString(status.Language, "patrolOffer", "response"), status.Account.Acct)
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: status.ID,
+		Visibility:  mapReplyVisibility(settingsFor(c).ReplyVisibility, status.Visibility),
+		Language:    status.Language,
+	})
+	if err != nil {
+		log.Printf("Error posting description offer: %v", err)
+	}
+
+	if err := saveConsentRequestsToFile("consent_requests.json"); err != nil {
+		log.Printf("Error saving consent requests: %v", err)
+	}
+}
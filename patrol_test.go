@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestPatrolLeavesPendingConsentRequestsAlone(t *testing.T) {
+	c := newFakeClient()
+	now := time.Now()
+	status := &mastodon.Status{
+		ID:               "patrol-1",
+		CreatedAt:        now,
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image"}},
+	}
+
+	consentMutex.Lock()
+	consentRequests[string(status.ID)] = ConsentRequest{RequestID: "mention", PosterID: "poster", Timestamp: now}
+	consentMutex.Unlock()
+	t.Cleanup(func() {
+		consentMutex.Lock()
+		delete(consentRequests, string(status.ID))
+		consentMutex.Unlock()
+	})
+
+	if candidates := selectPatrolCandidates(c, []*mastodon.Status{status}, now); len(candidates) != 0 {
+		t.Errorf("selected %d posts with a pending consent request", len(candidates))
+	}
+
+	offerDescription(c, status)
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Errorf("offered a description while the poster was already asked: %+v", posted[0])
+	}
+	consentMutex.Lock()
+	request := consentRequests[string(status.ID)]
+	consentMutex.Unlock()
+	if request.RequestID != "mention" {
+		t.Errorf("the pending request was replaced by %+v", request)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+// ErrPaused is returned instead of posting while the bot is paused
+var ErrPaused = errors.New("posting is paused")
+
+// PauseState tracks whether the bot has been paused by the admin or via the pause file
+type PauseState struct {
+	PausedByAdmin bool
+	lastPaused    bool
+	mu            sync.Mutex
+}
+
+var pauseState PauseState
+
+// setPaused pauses or resumes posting on behalf of the admin
+func setPaused(paused bool) {
+	pauseState.mu.Lock()
+	pauseState.PausedByAdmin = paused
+	pauseState.mu.Unlock()
+
+	isPaused()
+}
+
+// isPaused reports whether posting is currently paused, either by the admin or
+// because the configured pause file exists. State changes are logged.
+func isPaused() bool {
+	pauseState.mu.Lock()
+	defer pauseState.mu.Unlock()
+
+	paused := pauseState.PausedByAdmin
+	if !paused && config.Behavior.PauseFile != "" {
+		if _, err := os.Stat(config.Behavior.PauseFile); err == nil {
+			paused = true
+		}
+	}
+
+	if paused != pauseState.lastPaused {
+		if paused {
+			log.Printf("AltBot is paused, no posts will be made until resumed")
+		} else {
+			log.Printf("AltBot has been resumed")
+		}
+		pauseState.lastPaused = paused
+	}
+
+	return paused
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// usePaused pauses the bot for the duration of the test
+func usePaused(t *testing.T) {
+	t.Helper()
+	setPaused(true)
+	t.Cleanup(func() { setPaused(false) })
+}
+
+func TestNothingIsPostedWhilePaused(t *testing.T) {
+	withConfig(t, func(c *Config) { c.WeeklySummary.Enabled = true })
+	usePaused(t)
+	c := newFakeClient(&mastodon.Status{ID: "reminded", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "en"})
+	c.accounts["poster"] = &mastodon.Account{ID: "poster", Acct: "poster"}
+	t.Cleanup(func() {
+		welcomedMutex.Lock()
+		delete(welcomedAccounts, accountKey(c, "poster"))
+		welcomedMutex.Unlock()
+	})
+
+	sendWelcomeMessage(c, c.accounts["poster"])
+	notifyUserOfMissingAltText(c, c.statuses["reminded"], "poster")
+	NewRateLimiter().notifyAdmin(c, "poster")
+	GenerateWeeklySummary(c, ctx)
+	if _, err := reprocessDeadLetters(); !errors.Is(err, ErrPaused) {
+		t.Errorf("reprocessDeadLetters = %v, want ErrPaused", err)
+	}
+	if _, err := postStatus(ctx, c, &mastodon.Toot{Status: "hello"}); !errors.Is(err, ErrPaused) {
+		t.Errorf("postStatus = %v, want ErrPaused", err)
+	}
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Fatalf("posted %+v while paused", posted)
+	}
+
+	// Followers who arrived during the pause are still welcomed afterwards
+	setPaused(false)
+	sendWelcomeMessage(c, c.accounts["poster"])
+	if posted := c.postedToots(); len(posted) != 1 {
+		t.Errorf("posted %d welcome messages after resuming, want 1", len(posted))
+	}
+}
+
+func TestPauseIsConfirmedToTheAdmin(t *testing.T) {
+	t.Cleanup(func() { setPaused(false) })
+	c := newFakeClient()
+
+	handleAdminReply(c, &mastodon.Status{ID: "command", Content: "<p>@altbot pause</p>"}, NewRateLimiter())
+
+	if !isPaused() {
+		t.Error("the pause command didn't pause the bot")
+	}
+	if posted := c.postedToots(); len(posted) != 1 || posted[0].InReplyToID != "command" {
+		t.Errorf("posted %+v, want the confirmation", posted)
+	}
+}
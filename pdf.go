@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/mattn/go-mastodon"
+)
+
+// pdfProcessingCapability is set at startup when PDFs are enabled and the provider can read them
+var pdfProcessingCapability = false
+
+// pdfProcessingTimeout bounds how long an uploaded PDF may stay in processing before giving up
+var pdfProcessingTimeout = 2 * time.Minute
+
+// pdfPagePattern matches page objects, but not the /Pages tree nodes
+var pdfPagePattern = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// isPDFAttachment reports whether the attachment is a PDF document. Instances that allow
+// PDFs hand them out as the "unknown" media type, so the file extension decides.
+func isPDFAttachment(attachment mastodon.Attachment) bool {
+	return attachment.Type == "unknown" && attachmentExtension(attachment) == "pdf"
+}
+
+// isDescribablePDF reports whether the attachment is a PDF that should be described
+func isDescribablePDF(attachment mastodon.Attachment) bool {
+	return pdfProcessingCapability && isPDFAttachment(attachment)
+}
+
+// countPDFPages estimates the page count from the page objects in the file.
+// Pages inside compressed object streams aren't visible, so the count can be too low.
+func countPDFPages(data []byte) int {
+	return len(pdfPagePattern.FindAllIndex(data, -1))
+}
+
+// generatePDFAltText generates alt-text for a PDF document using the configured provider
+func generatePDFAltText(pdfURL string, remoteURL string, lang string) (string, error) {
+	prompt := localizedPrompt(lang, "generateDocumentAltText")
+
+	fmt.Println("Processing PDF: " + loggableURL(pdfURL))
+
+	pdfFilePath, err := downloadToTempFile(pdfURL, remoteURL, "document", "pdf")
+	if err != nil {
+		return "", err
+	}
+	defer removeTempFile(pdfFilePath)
+
+	data, err := os.ReadFile(pdfFilePath)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("%w: file is not a PDF: %s", ErrUnsupportedFormat, pdfURL)
+	}
+	if maxPages := config.ImageProcessing.MaxPDFPages; maxPages > 0 {
+		if pages := countPDFPages(data); pages > maxPages {
+			return "", fmt.Errorf("%w: PDF has %d pages, more than the limit of %d", ErrMediaTooLarge, pages, maxPages)
+		}
+	}
+
+	LogEvent("pdf_alt_text_generated")
+
+	provider, err := activeProvider("document")
+	if err != nil {
+		return "", err
+	}
+	altText, err := timeProviderCall(provider.Name(), "pdf", func() (string, error) {
+		return provider.DescribeDocument(prompt, pdfFilePath)
+	})
+	return flagLowQualityAltText(altText, lang), err
+}
+
+// GenerateDocumentAltWithGemini generates alt-text for a PDF document using the Gemini AI model
+func GenerateDocumentAltWithGemini(strPrompt string, documentFilePath string) (string, error) {
+	documentFile, err := os.Open(documentFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer documentFile.Close()
+
+	// Upload the document using the File API
+	opts := genai.UploadFileOptions{DisplayName: "Document for Alt-Text", MIMEType: "application/pdf"}
+	response, err := client.UploadFile(ctx, "", documentFile, &opts)
+	if err != nil {
+		return "", err
+	}
+
+	response, err = waitForFileActive(response, pdfProcessingTimeout, time.Second, func(name string) (*genai.File, error) {
+		return client.GetFile(ctx, name)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	prompt := []genai.Part{
+		genai.FileData{URI: response.URI},
+		genai.Text(strPrompt),
+	}
+
+	return geminiResult(model.GenerateContent(ctx, prompt...))
+}
+
+// waitForFileActive polls the uploaded file every interval until it's no longer processing.
+// It fails when processing takes longer than timeout or the file failed processing.
+func waitForFileActive(file *genai.File, timeout, interval time.Duration, getFile func(name string) (*genai.File, error)) (*genai.File, error) {
+	deadline := time.Now().Add(timeout)
+	for file.State == genai.FileStateProcessing {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("file %s still processing after %v", file.Name, timeout)
+		}
+		time.Sleep(interval)
+
+		var err error
+		if file, err = getFile(file.Name); err != nil {
+			return nil, err
+		}
+	}
+	if file.State == genai.FileStateFailed {
+		return nil, fmt.Errorf("processing file %s failed: %v", file.Name, file.State)
+	}
+	return file, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestCountPDFPages(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Pages /Kids [2 0 R 3 0 R] /Count 2 >> endobj\n" +
+		"2 0 obj << /Type /Page /Parent 1 0 R >> endobj\n3 0 obj << /Type/Page /Parent 1 0 R >> endobj\n")
+	if pages := countPDFPages(pdf); pages != 2 {
+		t.Errorf("countPDFPages = %d, want 2", pages)
+	}
+}
+
+func TestWaitForFileActive(t *testing.T) {
+	states := func(states ...genai.FileState) func(string) (*genai.File, error) {
+		return func(name string) (*genai.File, error) {
+			state := states[0]
+			if len(states) > 1 {
+				states = states[1:]
+			}
+			return &genai.File{Name: name, State: state}, nil
+		}
+	}
+	processing := &genai.File{Name: "files/doc", State: genai.FileStateProcessing}
+
+	file, err := waitForFileActive(processing, time.Second, time.Millisecond,
+		states(genai.FileStateProcessing, genai.FileStateActive))
+	if err != nil || file.State != genai.FileStateActive {
+		t.Errorf("waitForFileActive = %v, %v, want the active file", file, err)
+	}
+
+	if _, err := waitForFileActive(processing, time.Second, time.Millisecond, states(genai.FileStateFailed)); err == nil {
+		t.Error("waitForFileActive accepted a failed file")
+	}
+
+	start := time.Now()
+	if _, err := waitForFileActive(processing, 50*time.Millisecond, time.Millisecond, states(genai.FileStateProcessing)); err == nil {
+		t.Error("waitForFileActive returned a file that never finished processing")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waitForFileActive gave up after %v", elapsed)
+	}
+}
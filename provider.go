@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrUnsupportedMedia is returned by providers that cannot describe a media type
+var ErrUnsupportedMedia = errors.New("media type not supported by this provider")
+
+// ErrEmptyResponse is returned when the model answered without any text
+var ErrEmptyResponse = errors.New("the model returned an empty response")
+
+// ErrContentBlocked is returned when the model refused to describe the media, e.g. because of its safety filters
+var ErrContentBlocked = errors.New("the model blocked the content")
+
+// Provider describes media using an LLM backend
+type Provider interface {
+	Name() string
+	// SupportsMedia reports whether the provider can describe "image", "video", "audio" or "document" media
+	SupportsMedia(mediaType string) bool
+	// PreferredImageFormat is the format images are uploaded in, "jpeg", "png", "webp" or "" to
+	// keep JPEG images as they are and convert everything else to PNG
+	PreferredImageFormat() string
+	AcceptsImageFormat(format string) bool
+	DescribeImage(prompt string, image []byte, format string) (string, error)
+	DescribeVideo(prompt string, videoFilePath string) (string, error)
+	DescribeAudio(prompt string, audioFilePath string) (string, error)
+	DescribeDocument(prompt string, documentFilePath string) (string, error)
+}
+
+// llmProvider is the provider used for all generation, selected from config at startup
+var llmProvider Provider
+
+// mediaProviders holds the providers configured for single media types, overriding llmProvider
+var mediaProviders = make(map[string]Provider)
+
+// loadMediaProviders selects the providers configured for single media types,
+// failing if one of them can't describe its media type
+func loadMediaProviders() error {
+	overrides := map[string]string{
+		"image": config.LLM.ImageProvider,
+		"video": config.LLM.VideoProvider,
+		"audio": config.LLM.AudioProvider,
+	}
+	for mediaType, name := range overrides {
+		if name == "" {
+			continue
+		}
+		provider, err := newProvider(name)
+		if err != nil {
+			return err
+		}
+		if !provider.SupportsMedia(mediaType) {
+			return fmt.Errorf("provider %s can't describe %s", name, mediaType)
+		}
+		mediaProviders[mediaType] = provider
+	}
+	return nil
+}
+
+// providerFor returns the configured provider for the media type, without taking the budget into account
+func providerFor(mediaType string) Provider {
+	if provider, ok := mediaProviders[mediaType]; ok {
+		return provider
+	}
+	return llmProvider
+}
+
+// usesProvider reports whether the provider is configured for any media type
+func usesProvider(name string) bool {
+	if llmProvider.Name() == name {
+		return true
+	}
+	for _, provider := range mediaProviders {
+		if provider.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newProvider returns the provider registered under the given name
+func newProvider(name string) (Provider, error) {
+	switch name {
+	case "gemini":
+		return GeminiProvider{}, nil
+	case "ollama":
+		return OllamaProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", name)
+	}
+}
+
+// imageUploadFormat returns the format images are encoded in for the active provider,
+// taking image_processing.upload_formats over the provider's own preference
+func imageUploadFormat() string {
+	provider, err := activeProvider("image")
+	if err != nil {
+		return ""
+	}
+	if format, ok := config.ImageProcessing.UploadFormats[provider.Name()]; ok {
+		format = strings.ToLower(format)
+		if provider.AcceptsImageFormat(format) {
+			return format
+		}
+		log.Printf("Provider %s doesn't accept %q images, using the default format", provider.Name(), format)
+	}
+	return provider.PreferredImageFormat()
+}
+
+// GeminiProvider describes images, video, audio and documents using the Gemini API
+type GeminiProvider struct{}
+
+func (GeminiProvider) Name() string { return "gemini" }
+
+func (GeminiProvider) SupportsMedia(mediaType string) bool { return true }
+
+func (GeminiProvider) PreferredImageFormat() string { return "" }
+
+func (GeminiProvider) AcceptsImageFormat(format string) bool {
+	_, ok := geminiImageFormats[format]
+	return ok
+}
+
+func (GeminiProvider) DescribeImage(prompt string, image []byte, format string) (string, error) {
+	return GenerateImageAltWithGemini(prompt, image, format)
+}
+
+func (GeminiProvider) DescribeVideo(prompt string, videoFilePath string) (string, error) {
+	return GenerateVideoAltWithGemini(prompt, videoFilePath)
+}
+
+func (GeminiProvider) DescribeAudio(prompt string, audioFilePath string) (string, error) {
+	return GenerateAudioAltWithGemini(prompt, audioFilePath)
+}
+
+func (GeminiProvider) DescribeDocument(prompt string, documentFilePath string) (string, error) {
+	return GenerateDocumentAltWithGemini(prompt, documentFilePath)
+}
+
+// OllamaProvider describes images using a local Ollama model
+type OllamaProvider struct{}
+
+func (OllamaProvider) Name() string { return "ollama" }
+
+func (OllamaProvider) SupportsMedia(mediaType string) bool { return mediaType == "image" }
+
+func (OllamaProvider) PreferredImageFormat() string { return "" }
+
+func (OllamaProvider) AcceptsImageFormat(format string) bool {
+	return format == "jpeg" || format == "png"
+}
+
+func (OllamaProvider) DescribeImage(prompt string, image []byte, format string) (string, error) {
+	return GenerateImageAltWithOllama(prompt, image, format)
+}
+
+func (OllamaProvider) DescribeVideo(prompt string, videoFilePath string) (string, error) {
+	return "", ErrUnsupportedMedia
+}
+
+func (OllamaProvider) DescribeAudio(prompt string, audioFilePath string) (string, error) {
+	return "", ErrUnsupportedMedia
+}
+
+func (OllamaProvider) DescribeDocument(prompt string, documentFilePath string) (string, error) {
+	return "", ErrUnsupportedMedia
+}
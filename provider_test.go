@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/mattn/go-mastodon"
+)
+
+func TestGenerateImageAltTextUsesConfiguredProvider(t *testing.T) {
+	provider := &fakeProvider{response: "Here's alt text for the image: A red square @home"}
+	useProvider(t, provider)
+
+	altText, err := generateImageAltText(dataURI("image/png", testImage(t, 8, 8, color.RGBA{255, 0, 0, 255})), "", "en", "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if provider.calls() != 1 {
+		t.Fatalf("provider was called %d times, want 1", provider.calls())
+	}
+	if provider.formatsSent[0] != "png" {
+		t.Errorf("image sent as %q, want png", provider.formatsSent[0])
+	}
+	if want := "A red square [@]home"; altText != want {
+		t.Errorf("alt-text = %q, want %q", altText, want)
+	}
+}
+
+func TestGenerateImageAltTextPassesProviderErrors(t *testing.T) {
+	useProvider(t, &fakeProvider{err: ErrContentBlocked})
+
+	_, err := generateImageAltText(dataURI("image/png", testImage(t, 8, 8, color.White)), "", "en", "", false, nil)
+	if !errors.Is(err, ErrContentBlocked) {
+		t.Errorf("error = %v, want ErrContentBlocked", err)
+	}
+}
+
+func TestGeminiResultErrors(t *testing.T) {
+	text := &genai.Candidate{Content: &genai.Content{Parts: []genai.Part{genai.Text("A red square")}}}
+	tests := []struct {
+		name string
+		resp *genai.GenerateContentResponse
+		err  error
+		want error
+	}{
+		{"text", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{text}}, nil, nil},
+		{"no candidates", &genai.GenerateContentResponse{}, nil, ErrEmptyResponse},
+		{"empty text", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Parts: []genai.Part{genai.Text("  ")}}},
+		}}, nil, ErrEmptyResponse},
+		{"safety finish", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+			{FinishReason: genai.FinishReasonSafety},
+		}}, nil, ErrContentBlocked},
+		{"blocked prompt", nil, &genai.BlockedError{PromptFeedback: &genai.PromptFeedback{}}, ErrContentBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := geminiResult(tt.resp, tt.err)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderForPrefersMediaTypeOverride(t *testing.T) {
+	general := &fakeProvider{name: "general"}
+	video := &fakeProvider{name: "video", media: map[string]bool{"video": true}}
+	useProvider(t, general)
+	mediaProviders["video"] = video
+
+	if got := providerFor("image"); got != general {
+		t.Errorf("providerFor(image) = %s, want general", got.Name())
+	}
+	if got := providerFor("video"); got != video {
+		t.Errorf("providerFor(video) = %s, want video", got.Name())
+	}
+}
+
+func TestImageUploadFormatFallsBackWhenNotAccepted(t *testing.T) {
+	useProvider(t, &fakeProvider{formats: map[string]bool{"png": true, "webp": true}})
+
+	tests := []struct {
+		configured string
+		want       string
+	}{
+		{"webp", "webp"},
+		{"WEBP", "webp"},
+		{"jpeg", ""},
+	}
+	for _, tt := range tests {
+		withConfig(t, func(c *Config) {
+			c.ImageProcessing.UploadFormats = map[string]string{"fake": tt.configured}
+		})
+		if got := imageUploadFormat(); got != tt.want {
+			t.Errorf("imageUploadFormat() with %q = %q, want %q", tt.configured, got, tt.want)
+		}
+	}
+}
+
+// useFakeOllama puts an ollama command on the PATH that prints the output and records its arguments
+func useFakeOllama(t *testing.T, output string) (argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ollama command is a shell script")
+	}
+	dir := t.TempDir()
+	argsFile = filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > \"$FAKE_OLLAMA_ARGS\"\nprintf '%s' \"$FAKE_OLLAMA_OUTPUT\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ollama"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_OLLAMA_ARGS", argsFile)
+	t.Setenv("FAKE_OLLAMA_OUTPUT", output)
+	return argsFile
+}
+
+func TestOllamaOutputIsCleanedUp(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.LLM.OllamaModel = "llava:13b"
+		c.LLM.MaxAltChars = 60
+		c.RateLimit.Enabled = false
+	})
+	argsFile := useFakeOllama(t, "\nHere's alt text describing the image:\n  A cat called @whiskers sleeps on a sunny windowsill next to a potted basil plant.\n\n")
+	useProvider(t, OllamaProvider{})
+	status := &mastodon.Status{
+		ID:               "ollama-post",
+		Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 7, 3, color.RGBA{R: 90, G: 90, A: 255}))}},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "ollama-mention", Account: status.Account, Language: "en"})
+	forgetReplies(t, c, status.ID)
+
+	generateAndPostAltText(c, status, "ollama-mention", nil)
+
+	posted := c.postedToots()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d replies, want 1", len(posted))
+	}
+	description := strings.TrimPrefix(strings.SplitN(posted[0].Status, "\n\n", 2)[0], "@poster ")
+	if !strings.HasPrefix(description, "A cat called [@]whiskers sleeps") || !strings.HasSuffix(description, "…") {
+		t.Errorf("description = %q, want the introduction, mention and end removed", description)
+	}
+	if n := utf8.RuneCountInString(description); n > 60 {
+		t.Errorf("description is %d characters long, want at most 60", n)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(args), "run\nllava:13b\n") {
+		t.Errorf("ollama was run with %q, want the configured model", args)
+	}
+}
+
+func TestEmptyOllamaOutputIsAnError(t *testing.T) {
+	useFakeOllama(t, " \n\n")
+
+	if _, err := (OllamaProvider{}).DescribeImage("Describe this image.", testImage(t, 4, 4, color.White), "png"); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("error = %v, want ErrEmptyResponse", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// isLowQualityAltText reports whether a generated description is too short or one of the
+// configured low-value phrases, like "An image."
+func isLowQualityAltText(altText string) bool {
+	altText = strings.TrimSpace(altText)
+	if altText == "" {
+		// Empty responses are handled as errors
+		return false
+	}
+
+	if minLength := config.LLM.MinAltTextLength; minLength > 0 && utf8.RuneCountInString(altText) < minLength {
+		return true
+	}
+
+	normalized := strings.ToLower(strings.Trim(altText, " .!"))
+	for _, phrase := range config.LLM.LowValuePhrases {
+		if normalized == strings.ToLower(strings.Trim(phrase, " .!")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flagLowQualityAltText appends a note to descriptions that didn't pass the quality check
+func flagLowQualityAltText(altText, lang string) string {
+	if !isLowQualityAltText(altText) {
+		return altText
+	}
+	return altText + " " + getLocalizedString(lang, "lowQualityNote", "response")
+}
+
+// isRefusal reports whether the model output starts with one of the configured refusal phrases
+func isRefusal(altText string) bool {
+	normalized := strings.ToLower(strings.TrimSpace(altText))
+	for _, phrase := range config.LLM.RefusalPhrases {
+		phrase = strings.ToLower(strings.TrimSpace(phrase))
+		if phrase != "" && strings.HasPrefix(normalized, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsLowQualityAltText(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.LLM.MinAltTextLength = 15
+		c.LLM.LowValuePhrases = []string{"An image.", "A picture of something"}
+	})
+
+	tests := []struct {
+		altText string
+		want    bool
+	}{
+		{"", false},
+		{"A cat.", true},
+		{"一只猫在沙发上睡觉，旁边有一只狗", false},
+		{"an image", true},
+		{"  A picture of something!  ", true},
+		{"A tabby cat asleep on a green sofa.", false},
+		{"An image of a tabby cat asleep on a sofa.", false},
+	}
+	for _, tt := range tests {
+		if got := isLowQualityAltText(tt.altText); got != tt.want {
+			t.Errorf("isLowQualityAltText(%q) = %v, want %v", tt.altText, got, tt.want)
+		}
+	}
+}
+
+func TestFlagLowQualityAltText(t *testing.T) {
+	withConfig(t, func(c *Config) { c.LLM.MinAltTextLength = 15 })
+
+	if got, want := flagLowQualityAltText("A cat.", "en"), "A cat. (This description may be incomplete.)"; got != want {
+		t.Errorf("flagLowQualityAltText = %q, want %q", got, want)
+	}
+	if got := flagLowQualityAltText("A tabby cat asleep on a green sofa.", "en"); got != "A tabby cat asleep on a green sofa." {
+		t.Errorf("flagLowQualityAltText changed a good description to %q", got)
+	}
+}
+
+func TestRefusalsAreBlockedContent(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.LLM.RefusalPhrases = []string{"I cannot help with that", " As an AI "}
+	})
+
+	tests := []struct {
+		output  string
+		refusal bool
+	}{
+		{"I cannot help with that request.", true},
+		{"  i CANNOT help with that", true},
+		{"As an AI, I can't see images.", true},
+		{"A sign saying \"I cannot help with that\".", false},
+		{"A red square", false},
+	}
+	for _, tt := range tests {
+		altText, err := timeProviderCall("fake", "image", func() (string, error) { return tt.output, nil })
+		if refusal := errors.Is(err, ErrContentBlocked); refusal != tt.refusal {
+			t.Errorf("%q: error = %v, want a refusal: %v", tt.output, err, tt.refusal)
+		}
+		if tt.refusal && altText != "" {
+			t.Errorf("%q: the refusal was kept as alt-text %q", tt.output, altText)
+		}
+	}
+}
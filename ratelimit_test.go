@@ -0,0 +1,190 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useRateLimit enables the rate limit with a fresh state, allowing perMinute requests a minute
+func useRateLimit(t *testing.T, perMinute int) {
+	t.Helper()
+	withConfig(t, func(c *Config) {
+		c.RateLimit.Enabled = true
+		c.RateLimit.MaxRequestsPerMinute = perMinute
+		c.RateLimit.MaxRequestsPerHour = 10 * perMinute
+		c.RateLimit.ShadowBanThreshold = 3
+	})
+	saved := rateLimiter
+	rateLimiter = NewRateLimiter()
+	t.Cleanup(func() { rateLimiter = saved })
+}
+
+// clientWithUser returns a fake client that knows the user as an established account
+func clientWithUser(userID mastodon.ID) *fakeClient {
+	c := newFakeClient()
+	c.accounts[userID] = &mastodon.Account{ID: userID, Acct: string(userID), CreatedAt: time.Now().AddDate(-1, 0, 0)}
+	return c
+}
+
+func TestRateLimitDoesNotBanForPostsLargerThanTheLimit(t *testing.T) {
+	useRateLimit(t, 2)
+	c := clientWithUser("user")
+
+	fourImages := []string{"image", "image", "image", "image"}
+	for i := 0; i < 5; i++ {
+		if rateLimiter.IncrementAll(c, "user", fourImages) {
+			t.Fatal("a 4-image post was allowed under a limit of 2")
+		}
+	}
+	if rateLimiter.IsShadowBanned("user") || rateLimiter.ExceededCounts["user"] != 0 {
+		t.Errorf("rejections of an oversized post counted: exceeded %d, banned %v",
+			rateLimiter.ExceededCounts["user"], rateLimiter.IsShadowBanned("user"))
+	}
+
+	// A post that fits is still allowed, and going over the limit with it still counts
+	if !rateLimiter.IncrementAll(c, "user", []string{"image", "image"}) {
+		t.Fatal("a 2-image post was refused under a limit of 2")
+	}
+	if rateLimiter.IncrementAll(c, "user", []string{"image"}) || rateLimiter.ExceededCounts["user"] != 1 {
+		t.Errorf("exceeding the limit counted %d times, want 1", rateLimiter.ExceededCounts["user"])
+	}
+}
+
+func TestFourImagePostUnderLimitOfTwoIsReportedOnce(t *testing.T) {
+	useRateLimit(t, 2)
+	provider := &fakeProvider{response: "A square"}
+	useProvider(t, provider)
+	c := clientWithUser("requester")
+
+	image := dataURI("image/png", testImage(t, 4, 4, color.White))
+	status := &mastodon.Status{
+		ID:         "post",
+		Account:    mastodon.Account{ID: "requester", Acct: "requester"},
+		Visibility: "public",
+		MediaAttachments: []mastodon.Attachment{
+			{ID: "1", Type: "image", URL: image}, {ID: "2", Type: "image", URL: image},
+			{ID: "3", Type: "image", URL: image}, {ID: "4", Type: "image", URL: image},
+		},
+	}
+	c.statuses["post"] = status
+	c.statuses["mention"] = &mastodon.Status{
+		ID:          "mention",
+		InReplyToID: "post",
+		Account:     mastodon.Account{ID: "requester", Acct: "requester"},
+		Visibility:  "public",
+		Language:    "en",
+	}
+
+	generateAndPostAltText(c, status, "mention", nil)
+
+	if provider.calls() != 0 {
+		t.Errorf("described %d images of a post over the limit", provider.calls())
+	}
+	posted := c.postedToots()
+	limitMessage := getLocalizedString("en", "rateLimitExceeded", "response")
+	if len(posted) != 1 || strings.Count(posted[0].Status, limitMessage) != 1 {
+		t.Errorf("posted %+v, want one rate limit message", posted)
+	}
+}
+
+func TestExceededRateLimitReply(t *testing.T) {
+	tests := []struct {
+		exceededReply string
+		replies       int
+	}{
+		{"message", 1},
+		{"silent", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exceededReply, func(t *testing.T) {
+			useRateLimit(t, 1)
+			withConfig(t, func(c *Config) { c.RateLimit.ExceededReply = tt.exceededReply })
+			provider := &fakeProvider{response: "A square"}
+			useProvider(t, provider)
+			c := clientWithUser("hasty")
+			c.statuses["hasty-mention"] = &mastodon.Status{ID: "hasty-mention", Account: mastodon.Account{ID: "hasty", Acct: "hasty"}, Language: "en"}
+
+			// The only request of the minute is used up already
+			rateLimiter.Increment(c, "hasty", "image")
+
+			status := &mastodon.Status{
+				ID:               mastodon.ID("hasty-" + tt.exceededReply),
+				Account:          mastodon.Account{ID: "hasty", Acct: "hasty"},
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "1", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+			}
+			forgetReplies(t, c, status.ID)
+			generateAndPostAltText(c, status, "hasty-mention", nil)
+
+			if provider.calls() != 0 {
+				t.Error("described an image over the rate limit")
+			}
+			posted := c.postedToots()
+			if len(posted) != tt.replies {
+				t.Fatalf("posted %d replies, want %d", len(posted), tt.replies)
+			}
+			if tt.replies > 0 {
+				limitMessage := getLocalizedString("en", "rateLimitExceeded", "response")
+				if !strings.Contains(posted[0].Status, limitMessage) || strings.Contains(posted[0].Status, getLocalizedString("en", "altTextError", "response")) {
+					t.Errorf("reply %q, want only the rate limit message", posted[0].Status)
+				}
+			}
+		})
+	}
+}
+
+func TestMediaTypesAreCountedIndependently(t *testing.T) {
+	useRateLimit(t, 10)
+	withConfig(t, func(c *Config) {
+		c.RateLimit.MediaLimits = map[string]MediaRateLimit{
+			"video": {MaxPerMinute: 1, MaxPerHour: 2},
+			"audio": {MaxPerMinute: 2},
+		}
+	})
+	c := clientWithUser("listener")
+
+	if !rateLimiter.Increment(c, "listener", "video") {
+		t.Fatal("the first video was refused")
+	}
+	if rateLimiter.Increment(c, "listener", "gifv") {
+		t.Error("a gifv was allowed over the video limit")
+	}
+	for i := 0; i < 2; i++ {
+		if !rateLimiter.Increment(c, "listener", "audio") {
+			t.Errorf("audio %d was refused, the video limit shouldn't apply to it", i+1)
+		}
+	}
+	if rateLimiter.Increment(c, "listener", "audio") {
+		t.Error("a third audio was allowed over its limit of 2")
+	}
+	for i := 0; i < 5; i++ {
+		if !rateLimiter.Increment(c, "listener", "image") {
+			t.Errorf("image %d was refused, images have no media limit", i+1)
+		}
+	}
+	if rateLimiter.ExceededCounts["listener"] != 0 {
+		t.Errorf("media limits counted %d times towards a shadow ban", rateLimiter.ExceededCounts["listener"])
+	}
+
+	// Every type has its own minute, and the hour still limits video after the minute is over
+	rateLimiter.ResetMinuteCounts()
+	if !rateLimiter.Increment(c, "listener", "video") {
+		t.Error("a video was refused in the next minute")
+	}
+	rateLimiter.ResetMinuteCounts()
+	if rateLimiter.Increment(c, "listener", "video") {
+		t.Error("a third video was allowed over the hourly limit of 2")
+	}
+
+	// A post is counted as a whole: refused entirely when one of its types is over the limit
+	if rateLimiter.IncrementAll(c, "listener", []string{"image", "video"}) {
+		t.Error("a post with a video over the limit was allowed")
+	}
+	if got := rateLimiter.MinuteCounts["listener"]; got != 0 {
+		t.Errorf("minute count = %d after the refused post, want the image not counted", got)
+	}
+}
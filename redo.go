@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// parseRedoCommand detects a "redo" command in a reply to one of the bot's descriptions and
+// returns the optional hint written after it
+func parseRedoCommand(content string) (string, bool) {
+	words := strings.Fields(mentionText(content))
+	for i, word := range words {
+		if strings.ToLower(word) == "redo" {
+			return strings.Join(words[i+1:], " "), true
+		}
+	}
+	return "", false
+}
+
+// handleRedo regenerates the description of the original post when its requester or author asks for it.
+// The new description is posted as a reply to the redo request.
+func handleRedo(c MastodonClient, originalID mastodon.ID, notification *mastodon.Notification, hint string) {
+	if isPaused() || isDNI(c, &notification.Account) {
+		return
+	}
+
+	mapMutex.Lock()
+	replyInfo, exists := replyMap[accountKey(c, originalID)]
+	mapMutex.Unlock()
+	if !exists {
+		return
+	}
+
+	status, err := fetchStatus(ctx, c, originalID)
+	if err != nil {
+		log.Printf("Error fetching original status %s for redo: %v", originalID, err)
+		return
+	}
+
+	if notification.Account.ID != replyInfo.RequesterID && notification.Account.ID != status.Account.ID {
+		log.Printf("Ignoring redo by %s, who neither requested nor wrote the post", notification.Account.Acct)
+		return
+	}
+
+	// Without a hint the cache would return the same description again
+	if hint == "" {
+		hint = getLocalizedString(promptLanguage(notification.Status.Language), "redoHint", "prompt")
+	}
+
+	log.Printf("Regenerating description of %s for %s", originalID, notification.Account.Acct)
+	generateAndPostAltTextWithHint(c, status, notification.Status.ID, nil, hint)
+}
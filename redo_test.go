@@ -0,0 +1,83 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestParseRedoCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		hint    string
+		isRedo  bool
+	}{
+		{`<p><span class="h-card"><a href="https://example.social/@altbot" class="u-url mention">@<span>altbot</span></a></span> redo</p>`, "", true},
+		{"<p>@altbot Redo focus on the sign</p>", "focus on the sign", true},
+		{"<p>@altbot please redo, more detail</p>", "more detail", false},
+		{"<p>@altbot thanks!</p>", "", false},
+	}
+	for _, tt := range tests {
+		hint, isRedo := parseRedoCommand(tt.content)
+		if isRedo != tt.isRedo || (isRedo && hint != tt.hint) {
+			t.Errorf("parseRedoCommand(%q) = %q, %v, want %q, %v", tt.content, hint, isRedo, tt.hint, tt.isRedo)
+		}
+	}
+}
+
+func TestHandleRedo(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester mastodon.ID
+		redone    bool
+	}{
+		{"requester", "requester", true},
+		{"author", "poster", true},
+		{"someone else", "stranger", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+			provider := &fakeProvider{response: "A closer look at a white square"}
+			useProvider(t, provider)
+
+			original := &mastodon.Status{
+				ID:               "redone-post",
+				Account:          mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility:       "public",
+				MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}},
+			}
+			request := &mastodon.Status{
+				ID:         "redo-request",
+				Account:    mastodon.Account{ID: tt.requester, Acct: string(tt.requester)},
+				Content:    "<p>@altbot redo focus on the sign</p>",
+				Visibility: "public",
+				Language:   "en",
+			}
+			c := newFakeClient(original, request)
+			forgetReplies(t, c, original.ID)
+			mapMutex.Lock()
+			replyMap[accountKey(c, original.ID)] = ReplyInfo{OriginalID: original.ID, ReplyID: "first-reply", RequesterID: "requester", Timestamp: time.Now()}
+			mapMutex.Unlock()
+
+			handleRedo(c, original.ID, &mastodon.Notification{Account: request.Account, Status: request}, "focus on the sign")
+
+			posted := c.postedToots()
+			if redone := len(posted) == 1; redone != tt.redone {
+				t.Fatalf("posted %+v, want a new description %v", posted, tt.redone)
+			}
+			if !tt.redone {
+				return
+			}
+			if posted[0].InReplyToID != "redo-request" {
+				t.Errorf("the new description replies to %s, want the redo request", posted[0].InReplyToID)
+			}
+			if len(provider.prompts) != 1 || !strings.Contains(provider.prompts[0], "focus on the sign") {
+				t.Errorf("prompts = %q, want the hint in the prompt", provider.prompts)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mattn/go-mastodon"
+)
+
+// oobRedirectURI makes the server show the authorization code instead of redirecting
+const oobRedirectURI = "urn:ietf:wg:oauth:2.0:oob"
+
+// runRegistration registers AltBot as an application on the Mastodon server and stores the obtained
+// credentials in the config file. Without an email the authorization code flow is used interactively,
+// with an email the password grant is used and the password is read from ALTBOT_PASSWORD.
+func runRegistration(filePath, email string) {
+	fmt.Println(Cyan + "Registering AltBot with your Mastodon server..." + Reset)
+
+	if _, err := toml.DecodeFile(filePath, &config); err != nil {
+		log.Fatalf("Error loading %s: %v", filePath, err)
+	}
+
+	interactive := email == ""
+	if interactive {
+		config.Server.MastodonServer = promptString(Blue+"Mastodon Server URL:"+Reset, config.Server.MastodonServer)
+	}
+
+	registerCtx := context.Background()
+	app, err := mastodon.RegisterApp(registerCtx, &mastodon.AppConfig{
+		Server:       config.Server.MastodonServer,
+		ClientName:   "AltBot",
+		Scopes:       "read write follow",
+		Website:      "https://github.com/micr0-dev/AltBot",
+		RedirectURIs: oobRedirectURI,
+	})
+	if err != nil {
+		log.Fatalf("Error registering application: %v", err)
+	}
+
+	c := mastodon.NewClient(&mastodon.Config{
+		Server:       config.Server.MastodonServer,
+		ClientID:     app.ClientID,
+		ClientSecret: app.ClientSecret,
+	})
+
+	if interactive {
+		fmt.Printf("Open the following URL while logged in as the bot account and authorize AltBot:\n\n%s\n\n", app.AuthURI)
+		code := promptString(Green+"Authorization Code:"+Reset, "")
+		err = c.AuthenticateToken(registerCtx, code, oobRedirectURI)
+	} else {
+		password := os.Getenv("ALTBOT_PASSWORD")
+		if password == "" {
+			log.Fatal("ALTBOT_PASSWORD must be set when registering with an email")
+		}
+		err = c.Authenticate(registerCtx, email, password)
+	}
+	if err != nil {
+		log.Fatalf("Error obtaining access token: %v", err)
+	}
+
+	config.Server.ClientSecret = app.ClientSecret
+	config.Server.AccessToken = c.Config.AccessToken
+
+	// Use the account's actual username, so it doesn't have to be entered by hand
+	if account, err := c.GetAccountCurrentUser(registerCtx); err == nil {
+		config.Server.Username = account.Username
+	}
+
+	saveConfig(filePath)
+
+	fmt.Println(Green + "Registration complete! The credentials have been saved to " + filePath + Reset)
+}
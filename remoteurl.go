@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// fetchMedia downloads the media at mediaURL. When that fails, e.g. because the instance evicted
+// the file from its cache, the copy on the origin server at remoteURL is tried instead, if known.
+// Media embedded as a data URI is decoded without any request.
+func fetchMedia(mediaURL, remoteURL string) ([]byte, error) {
+	if isDataURI(mediaURL) {
+		return decodeDataURI(mediaURL)
+	}
+
+	data, err := fetchMediaFrom(mediaURL)
+	if err == nil {
+		return data, nil
+	}
+
+	if remoteURL == "" || remoteURL == mediaURL {
+		return nil, err
+	}
+
+	log.Printf("Error fetching %s, trying the remote URL %s: %v", loggableURL(mediaURL), loggableURL(remoteURL), err)
+	return fetchMediaFrom(remoteURL)
+}
+
+// fetchMediaFrom downloads a single URL, respecting the size limit for its host
+func fetchMediaFrom(mediaURL string) ([]byte, error) {
+	resp, err := mediaHTTPClient.Get(mediaURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status fetching %s: %s", ErrMediaUnavailable, mediaURL, resp.Status)
+	}
+
+	// Check the Content-Length header
+	maxSizeMB := maxSizeMBForURL(mediaURL)
+	maxSize := int64(maxSizeMB) * 1024 * 1024
+	contentLength := resp.Header.Get("Content-Length")
+	if contentLength != "" {
+		size, err := strconv.ParseInt(contentLength, 10, 64)
+		if err == nil && size > maxSize {
+			return nil, fmt.Errorf("%w: file size exceeds maximum limit of %d MB", ErrMediaTooLarge, maxSizeMB)
+		}
+	}
+
+	// The header may be missing or wrong, so the body is cut off right after the limit as well
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: file size exceeds maximum limit of %d MB", ErrMediaTooLarge, maxSizeMB)
+	}
+	return data, nil
+}
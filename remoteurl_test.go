@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// mediaServer serves the media at /origin, everything else was evicted from the cache
+func mediaServer(t *testing.T, media []byte) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxSizeMB = 1 })
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/origin" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(media)
+	}))
+	t.Cleanup(server.Close)
+
+	saved := mediaHTTPClient
+	mediaHTTPClient = server.Client()
+	t.Cleanup(func() { mediaHTTPClient = saved })
+	return server, &requests
+}
+
+func TestFetchMediaFallsBackToRemoteURL(t *testing.T) {
+	server, requests := mediaServer(t, []byte("media"))
+
+	data, err := fetchMedia(server.URL+"/cache", server.URL+"/origin")
+	if err != nil || string(data) != "media" {
+		t.Errorf("fetchMedia = %q, %v, want the media from the origin", data, err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("made %d requests, want 2", n)
+	}
+}
+
+func TestFetchMediaWithoutRemoteURL(t *testing.T) {
+	server, requests := mediaServer(t, []byte("media"))
+
+	for _, remoteURL := range []string{"", server.URL + "/cache"} {
+		requests.Store(0)
+		if _, err := fetchMedia(server.URL+"/cache", remoteURL); err == nil {
+			t.Errorf("fetchMedia with remote URL %q found evicted media", remoteURL)
+		}
+		if n := requests.Load(); n != 1 {
+			t.Errorf("made %d requests with remote URL %q, want 1", n, remoteURL)
+		}
+	}
+
+	if data, err := fetchMedia(server.URL+"/origin", ""); err != nil || string(data) != "media" {
+		t.Errorf("fetchMedia = %q, %v", data, err)
+	}
+}
+
+func TestEvictedAttachmentIsDescribedFromOrigin(t *testing.T) {
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+	server, _ := mediaServer(t, testImage(t, 8, 8, color.White))
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+
+	status := &mastodon.Status{
+		ID:         "evicted",
+		Account:    mastodon.Account{ID: "poster", Acct: "poster@origin.example"},
+		Visibility: "public",
+		MediaAttachments: []mastodon.Attachment{
+			{ID: "a", Type: "image", URL: server.URL + "/cache", RemoteURL: server.URL + "/origin"},
+		},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "mention", Account: mastodon.Account{ID: "poster"}, Language: "en"})
+	forgetReplies(t, c, status.ID)
+
+	generateAndPostAltText(c, status, "mention", nil)
+
+	if provider.calls() != 1 {
+		t.Errorf("described %d images, want the one from the origin server", provider.calls())
+	}
+}
+
+func TestFetchMediaEnforcesTheLimitWithoutContentLength(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxSizeMB = 1 })
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing first sends the body chunked, without a Content-Length
+		w.(http.Flusher).Flush()
+		chunk := make([]byte, 64*1024)
+		for i := 0; i < 40; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	saved := mediaHTTPClient
+	mediaHTTPClient = server.Client()
+	t.Cleanup(func() { mediaHTTPClient = saved })
+
+	if data, err := fetchMedia(server.URL+"/huge", ""); !errors.Is(err, ErrMediaTooLarge) {
+		t.Errorf("fetchMedia = %d bytes, %v, want ErrMediaTooLarge", len(data), err)
+	}
+}
+
+func TestMediaClientHasATimeout(t *testing.T) {
+	if mediaHTTPClient.Timeout <= 0 {
+		t.Error("media downloads can hang forever")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "sync"
+
+// retryBudget caps the number of retries spent on a single post, shared by all of its attachments
+type retryBudget struct {
+	mu        sync.Mutex
+	remaining int
+	limited   bool
+}
+
+// newRetryBudget returns a budget of llm.max_retries_per_post retries (0 = unlimited)
+func newRetryBudget() *retryBudget {
+	return &retryBudget{
+		remaining: config.LLM.MaxRetriesPerPost,
+		limited:   config.LLM.MaxRetriesPerPost > 0,
+	}
+}
+
+// take uses up one retry and reports whether the budget allowed it. A nil budget is unlimited.
+func (b *retryBudget) take() bool {
+	if b == nil || !b.limited {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestRetryBudgetBoundsProviderCalls(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		calls      int
+	}{
+		{"limited", 2, 4 + 2},
+		{"unlimited", 0, 4 * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.LLM.RetryOnEmpty = true
+				c.LLM.MaxRetriesPerPost = tt.maxRetries
+				c.RateLimit.Enabled = false
+			})
+			// Every answer is empty, so every attachment asks for a retry
+			provider := &fakeProvider{err: ErrEmptyResponse}
+			useProvider(t, provider)
+
+			image := dataURI("image/png", testImage(t, 4, 4, color.White))
+			status := &mastodon.Status{
+				ID:         mastodon.ID("retried-" + tt.name),
+				Account:    mastodon.Account{ID: "poster", Acct: "poster"},
+				Visibility: "public",
+				MediaAttachments: []mastodon.Attachment{
+					{ID: "1", Type: "image", URL: image}, {ID: "2", Type: "image", URL: image},
+					{ID: "3", Type: "image", URL: image}, {ID: "4", Type: "image", URL: image},
+				},
+			}
+			c := newFakeClient(status, &mastodon.Status{ID: "retry-mention", Account: status.Account, Language: "en"})
+			forgetReplies(t, c, status.ID)
+
+			generateAndPostAltText(c, status, "retry-mention", nil)
+
+			if provider.calls() != tt.calls {
+				t.Errorf("the provider was called %d times, want %d", provider.calls(), tt.calls)
+			}
+		})
+	}
+}
+
+func TestNilRetryBudgetIsUnlimited(t *testing.T) {
+	var budget *retryBudget
+	for i := 0; i < 10; i++ {
+		if !budget.take() {
+			t.Fatal("a nil budget refused a retry")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// allowedInternalNetworks are internal networks media may still be fetched from, e.g. a local media proxy
+var allowedInternalNetworks []*net.IPNet
+
+// carrierGradeNAT is the shared address space of RFC 6598, which net.IP doesn't treat as private
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// mediaHTTPClient is used for every media fetch. It refuses to connect to loopback, private and
+// link-local addresses, so a malicious instance can't make the bot request internal services.
+// The check happens after DNS resolution and applies to redirects as well. Proxies are never used,
+// since the check would only see the proxy's address while the proxy connects to the target.
+// A download may take a few minutes at most, so a stalled server can't hold up a description forever.
+var mediaHTTPClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: checkDialAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// loadAllowedInternalNetworks parses the configured allowlist of internal networks
+func loadAllowedInternalNetworks(cidrs []string) error {
+	allowedInternalNetworks = nil
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("invalid network %q", cidr)
+			}
+			network = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		}
+		allowedInternalNetworks = append(allowedInternalNetworks, network)
+	}
+	return nil
+}
+
+// checkDialAddress rejects connections to internal addresses that aren't explicitly allowed
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("refusing to connect to unresolved address %s", host)
+	}
+
+	if isInternalIP(ip) && !isAllowedInternalIP(ip) {
+		return fmt.Errorf("refusing to connect to internal address %s", ip)
+	}
+	return nil
+}
+
+// isInternalIP reports whether the IP is loopback, private, link-local or otherwise not publicly routable
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip)
+}
+
+func isAllowedInternalIP(ip net.IP) bool {
+	for _, network := range allowedInternalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMediaClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := mediaHTTPClient.Get(server.URL); err == nil {
+		t.Error("fetched media from a loopback address")
+	}
+
+	// A proxy would connect to the target itself, out of reach of the address check
+	if transport := mediaHTTPClient.Transport.(*http.Transport); transport.Proxy != nil {
+		t.Error("media fetches go through a proxy")
+	}
+}
@@ -15,9 +15,11 @@ func runSetupWizard(filePath string) {
 	fmt.Println(Cyan + "Welcome to the AltBot Setup Wizard!" + Reset)
 
 	// Load the default config
-	if _, err := toml.DecodeFile("config.toml", &config); err != nil {
+	md, err := toml.DecodeFile("config.toml", &config)
+	if err != nil {
 		log.Fatalf("Error loading config.toml: %v", err)
 	}
+	applyConfigDefaults(md)
 
 	config.Server.MastodonServer = promptString(Blue+"Mastodon Server URL:"+Reset, config.Server.MastodonServer)
 	config.Server.ClientSecret = promptString(Pink+"Mastodon Client Secret:"+Reset, config.Server.ClientSecret)
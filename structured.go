@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// StructuredDescription is the JSON object returned by the model in structured output mode
+type StructuredDescription struct {
+	Caption        string `json:"caption"`
+	OCRText        string `json:"ocr_text"`
+	ContainsPeople bool   `json:"contains_people"`
+	IsNSFW         bool   `json:"is_nsfw"`
+}
+
+// structuredDescriptionSchema enforces the StructuredDescription format on the model's response
+var structuredDescriptionSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"caption": {
+			Type:        genai.TypeString,
+			Description: "The alt-text description of the image",
+		},
+		"ocr_text": {
+			Type:        genai.TypeString,
+			Description: "All text visible in the image, transcribed word for word, or an empty string if there is none",
+		},
+		"contains_people": {
+			Type:        genai.TypeBoolean,
+			Description: "Whether the image shows one or more people",
+		},
+		"is_nsfw": {
+			Type:        genai.TypeBoolean,
+			Description: "Whether the image contains sexual, violent or otherwise sensitive content",
+		},
+	},
+	Required: []string{"caption", "ocr_text", "contains_people", "is_nsfw"},
+}
+
+// structuredModel is a copy of the Gemini model that responds with a StructuredDescription
+var structuredModel *genai.GenerativeModel
+
+// setupStructuredModel derives the structured output model from the configured Gemini model
+func setupStructuredModel(base *genai.GenerativeModel) {
+	structured := *base
+	structured.ResponseMIMEType = "application/json"
+	structured.ResponseSchema = structuredDescriptionSchema
+	structuredModel = &structured
+}
+
+// defaultStructuredTemplate is used when no structured_template is configured
+const defaultStructuredTemplate = "{{caption}}"
+
+// parseStructuredDescription parses the JSON response of the structured output model
+func parseStructuredDescription(response string) (StructuredDescription, error) {
+	var description StructuredDescription
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimSuffix(strings.TrimPrefix(response, "```"), "```")
+
+	err := json.Unmarshal([]byte(response), &description)
+	return description, err
+}
+
+// formatStructuredDescription renders the description using the configured template.
+// Lines whose placeholders are all empty are left out.
+func formatStructuredDescription(description StructuredDescription, template string) string {
+	values := map[string]string{
+		"{{caption}}":         strings.TrimSpace(description.Caption),
+		"{{ocr_text}}":        strings.TrimSpace(description.OCRText),
+		"{{contains_people}}": strconv.FormatBool(description.ContainsPeople),
+		"{{is_nsfw}}":         strconv.FormatBool(description.IsNSFW),
+	}
+
+	var lines []string
+	for _, line := range strings.Split(template, "\n") {
+		hasPlaceholder := false
+		hasValue := false
+		for placeholder, value := range values {
+			if strings.Contains(line, placeholder) {
+				hasPlaceholder = true
+				if value != "" {
+					hasValue = true
+				}
+				line = strings.ReplaceAll(line, placeholder, value)
+			}
+		}
+
+		if hasPlaceholder && !hasValue {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
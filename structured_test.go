@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestParseStructuredDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"plain", `{"caption": "A red bus", "ocr_text": "Route 7", "contains_people": true, "is_nsfw": false}`},
+		{"code fence", "```json\n{\"caption\": \"A red bus\", \"ocr_text\": \"Route 7\", \"contains_people\": true}\n```"},
+		{"bare fence", "  ```\n{\"caption\": \"A red bus\", \"ocr_text\": \"Route 7\", \"contains_people\": true}```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description, err := parseStructuredDescription(tt.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := StructuredDescription{Caption: "A red bus", OCRText: "Route 7", ContainsPeople: true}
+			if description != want {
+				t.Errorf("parsed %+v, want %+v", description, want)
+			}
+		})
+	}
+
+	if _, err := parseStructuredDescription("A red bus"); err == nil {
+		t.Error("parsed a plain text response without an error")
+	}
+}
+
+func TestFormatStructuredDescription(t *testing.T) {
+	description := StructuredDescription{Caption: " A red bus ", ContainsPeople: true}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"default", defaultStructuredTemplate, "A red bus"},
+		{"empty lines left out", "{{caption}}\nText: {{ocr_text}}", "A red bus"},
+		{"booleans", "{{caption}} (people: {{contains_people}}, sensitive: {{is_nsfw}})", "A red bus (people: true, sensitive: false)"},
+		{"static lines kept", "Description:\n{{caption}}", "Description:\nA red bus"},
+	}
+	for _, tt := range tests {
+		if got := formatStructuredDescription(description, tt.template); got != tt.want {
+			t.Errorf("%s: formatted %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	withText := StructuredDescription{Caption: "A sign", OCRText: "Exit"}
+	if got, want := formatStructuredDescription(withText, "{{caption}}\nText: {{ocr_text}}"), "A sign\nText: Exit"; got != want {
+		t.Errorf("formatted %q, want %q", got, want)
+	}
+}
+
+func TestStructuredTemplateDefaultsToTheCaption(t *testing.T) {
+	withConfig(t, func(c *Config) { *c = Config{} })
+
+	md, err := toml.Decode("[gemini]\nstructured_output = true\n", &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyConfigDefaults(md)
+
+	description := StructuredDescription{Caption: "A red bus"}
+	if got := formatStructuredDescription(description, config.Gemini.StructuredTemplate); got != "A red bus" {
+		t.Errorf("formatted %q with the default template, want the caption", got)
+	}
+}
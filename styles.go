@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// StylePreferences stores the preferred description style of each account
+type StylePreferences struct {
+	Styles   map[string]string `json:"styles"`
+	filePath string
+	mu       sync.Mutex
+}
+
+var stylePreferences = StylePreferences{
+	Styles: make(map[string]string),
+}
+
+// Get returns the prompt instruction for the account's preferred style, or an empty string
+func (sp *StylePreferences) Get(userID string) string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	style, ok := sp.Styles[userID]
+	if !ok {
+		return ""
+	}
+	return config.PromptStyles[style]
+}
+
+// Set stores the preferred style of an account, an empty style removes the preference
+func (sp *StylePreferences) Set(userID, style string) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if style == "" {
+		delete(sp.Styles, userID)
+	} else {
+		sp.Styles[userID] = style
+	}
+
+	return sp.saveToFile()
+}
+
+// LoadFromFile loads the stored style preferences
+func (sp *StylePreferences) LoadFromFile(filePath string) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	sp.filePath = filePath
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist. Start fresh.
+		}
+		return err
+	}
+	return json.Unmarshal(data, sp)
+}
+
+func (sp *StylePreferences) saveToFile() error {
+	data, err := json.Marshal(sp)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sp.filePath, data, 0644)
+}
+
+// applyPromptStyle prepends the style instruction to the prompt
+func applyPromptStyle(prompt, style string) string {
+	if style == "" {
+		return prompt
+	}
+	return style + " " + prompt
+}
+
+// handleStyleCommand handles "setstyle <name>" commands, returning true if the mention was one
+func handleStyleCommand(c MastodonClient, notification *mastodon.Notification) bool {
+	words := strings.Fields(strings.ToLower(mentionText(notification.Status.Content)))
+
+	var style string
+	found := false
+	for i, word := range words {
+		if word == "setstyle" {
+			found = true
+			if i+1 < len(words) {
+				style = words[i+1]
+			}
+			break
+		}
+	}
+
+	if !found {
+		return false
+	}
+
+	lang := notification.Status.Language
+	var message string
+	if style == "default" || style == "reset" {
+		style = ""
+	}
+
+	if _, ok := config.PromptStyles[style]; style != "" && !ok {
+		message = fmt.Sprintf(getLocalizedString(lang, "styleUnknown", "response"), strings.Join(availablePromptStyles(), ", "))
+	} else if err := stylePreferences.Set(string(notification.Account.ID), style); err != nil {
+		log.Printf("Error saving style preference: %v", err)
+		return true
+	} else if style == "" {
+		message = getLocalizedString(lang, "styleReset", "response")
+	} else {
+		message = fmt.Sprintf(getLocalizedString(lang, "styleSet", "response"), style)
+	}
+
+	_, err := postStatus(ctx, c, &mastodon.Toot{
+		Status:      fmt.Sprintf("@%s %s", notification.Account.Acct, message),
+		InReplyToID: notification.Status.ID,
+		Visibility:  "direct",
+		Language:    lang,
+	})
+	if err != nil {
+		log.Printf("Error posting style confirmation: %v", err)
+	}
+
+	return true
+}
+
+// availablePromptStyles returns the configured style names in alphabetical order
+func availablePromptStyles() []string {
+	var styles []string
+	for style := range config.PromptStyles {
+		styles = append(styles, style)
+	}
+	sort.Strings(styles)
+	return styles
+}
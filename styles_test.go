@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useStylePreferences starts the test with no style preferences, saved in a scratch file
+func useStylePreferences(t *testing.T) {
+	t.Helper()
+	withConfig(t, func(c *Config) {
+		c.PromptStyles = map[string]string{"brief": "Keep it to one sentence.", "detailed": "Describe every detail."}
+	})
+	stylePreferences.mu.Lock()
+	savedStyles, savedPath := stylePreferences.Styles, stylePreferences.filePath
+	stylePreferences.Styles = make(map[string]string)
+	stylePreferences.filePath = filepath.Join(t.TempDir(), "styles.json")
+	stylePreferences.mu.Unlock()
+	t.Cleanup(func() {
+		stylePreferences.mu.Lock()
+		stylePreferences.Styles, stylePreferences.filePath = savedStyles, savedPath
+		stylePreferences.mu.Unlock()
+	})
+}
+
+// styleCommand sends the mention to handleStyleCommand
+func styleCommand(c MastodonClient, content string) bool {
+	status := &mastodon.Status{ID: "style-command", Account: mastodon.Account{ID: "styler", Acct: "styler"}, Content: content, Language: "en"}
+	return handleStyleCommand(c, &mastodon.Notification{Type: "mention", Account: status.Account, Status: status})
+}
+
+func TestStyleCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		style   string
+		reply   string
+	}{
+		{"set", "<p>@altbot setstyle Brief</p>", "Keep it to one sentence.", fmt.Sprintf(getLocalizedString("en", "styleSet", "response"), "brief")},
+		{"unknown", "<p>@altbot setstyle poetic</p>", "Describe every detail.", fmt.Sprintf(getLocalizedString("en", "styleUnknown", "response"), "brief, detailed")},
+		{"missing name", "<p>@altbot setstyle</p>", "", getLocalizedString("en", "styleReset", "response")},
+		{"reset", "<p>@altbot setstyle reset</p>", "", getLocalizedString("en", "styleReset", "response")},
+		{"default", "<p>@altbot setstyle default</p>", "", getLocalizedString("en", "styleReset", "response")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStylePreferences(t)
+			if err := stylePreferences.Set("styler", "detailed"); err != nil {
+				t.Fatal(err)
+			}
+			c := newFakeClient()
+
+			if !styleCommand(c, tt.content) {
+				t.Fatal("the command wasn't handled")
+			}
+
+			if got := stylePreferences.Get("styler"); got != tt.style {
+				t.Errorf("style = %q, want %q", got, tt.style)
+			}
+			posted := c.postedToots()
+			if len(posted) != 1 || posted[0].Status != "@styler "+tt.reply || posted[0].Visibility != "direct" || posted[0].InReplyToID != "style-command" {
+				t.Errorf("posted %+v, want a direct reply %q", posted, tt.reply)
+			}
+		})
+	}
+}
+
+func TestOtherMentionsAreNotStyleCommands(t *testing.T) {
+	useStylePreferences(t)
+	c := newFakeClient()
+
+	if styleCommand(c, "<p>@altbot what style is this painting?</p>") {
+		t.Error("a mention without setstyle was handled as a style command")
+	}
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Errorf("posted %+v", posted)
+	}
+}
+
+func TestStylePreferencesPersist(t *testing.T) {
+	useStylePreferences(t)
+	if err := stylePreferences.Set("styler", "brief"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := StylePreferences{Styles: make(map[string]string)}
+	if err := loaded.LoadFromFile(stylePreferences.filePath); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Get("styler"); got != "Keep it to one sentence." {
+		t.Errorf("loaded style = %q, want the brief style", got)
+	}
+
+	// A style that was removed from the configuration is ignored
+	withConfig(t, func(c *Config) { c.PromptStyles = map[string]string{"detailed": "Describe every detail."} })
+	if got := loaded.Get("styler"); got != "" {
+		t.Errorf("style = %q for a style that no longer exists", got)
+	}
+}
+
+func TestPreferredStyleIsAddedToThePrompt(t *testing.T) {
+	useStylePreferences(t)
+	withConfig(t, func(c *Config) { c.RateLimit.Enabled = false })
+	if err := stylePreferences.Set("styler", "brief"); err != nil {
+		t.Fatal(err)
+	}
+	provider := &fakeProvider{response: "A green square."}
+	useProvider(t, provider)
+
+	status := &mastodon.Status{
+		ID:               "styled-post",
+		Account:          mastodon.Account{ID: "styler", Acct: "styler"},
+		Visibility:       "public",
+		MediaAttachments: []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 6, 3, color.RGBA{G: 200, A: 255}))}},
+	}
+	c := newFakeClient(status, &mastodon.Status{ID: "styled-mention", Account: status.Account, Language: "en"})
+	forgetReplies(t, c, status.ID)
+
+	generateAndPostAltText(c, status, "styled-mention", nil)
+
+	if len(provider.prompts) != 1 || !strings.HasPrefix(provider.prompts[0], "Keep it to one sentence. ") {
+		t.Errorf("prompts = %q, want the style in front", provider.prompts)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	summaryChartWidth  = 640
+	summaryChartHeight = 360
+	summaryChartMargin = 40
+)
+
+var (
+	summaryChartBackground = color.RGBA{255, 255, 255, 255}
+	summaryChartBar        = color.RGBA{86, 58, 204, 255}
+	summaryChartText       = color.RGBA{40, 40, 40, 255}
+)
+
+// dailyAltTextCounts counts the generated alt-texts of each of the last seven days, oldest first
+func dailyAltTextCounts(entries []LogEntry, now time.Time) ([]int, []string) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	counts := make([]int, 7)
+	labels := make([]string, 7)
+	for i := range labels {
+		labels[i] = today.AddDate(0, 0, i-6).Format("Mon")
+	}
+
+	for _, entry := range entries {
+		if entry.EventType != "alt_text_generated" {
+			continue
+		}
+		entryTime := entry.Timestamp.In(now.Location())
+		entryDay := time.Date(entryTime.Year(), entryTime.Month(), entryTime.Day(), 0, 0, 0, 0, now.Location())
+		daysAgo := int(math.Round(today.Sub(entryDay).Hours() / 24)) // Days around DST changes aren't 24 hours long
+		if daysAgo >= 0 && daysAgo < 7 {
+			counts[6-daysAgo]++
+		}
+	}
+
+	return counts, labels
+}
+
+// renderSummaryChart draws a bar chart of the counts as a PNG image
+func renderSummaryChart(title string, counts []int, labels []string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, summaryChartWidth, summaryChartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{summaryChartBackground}, image.Point{}, draw.Src)
+
+	drawChartText(img, title, summaryChartWidth/2, summaryChartMargin/2+5)
+
+	maxCount := 1
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+
+	if len(counts) > 0 {
+		slotWidth := (summaryChartWidth - 2*summaryChartMargin) / len(counts)
+		barWidth := slotWidth * 2 / 3
+		baseline := summaryChartHeight - summaryChartMargin
+		plotHeight := summaryChartHeight - 3*summaryChartMargin
+
+		for i, count := range counts {
+			x := summaryChartMargin + i*slotWidth + (slotWidth-barWidth)/2
+			barHeight := count * plotHeight / maxCount
+			bar := image.Rect(x, baseline-barHeight, x+barWidth, baseline)
+			draw.Draw(img, bar, &image.Uniform{summaryChartBar}, image.Point{}, draw.Src)
+
+			drawChartText(img, fmt.Sprintf("%d", count), x+barWidth/2, baseline-barHeight-6)
+			if i < len(labels) {
+				drawChartText(img, labels[i], x+barWidth/2, baseline+18)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawChartText draws the text centered horizontally on x, with its baseline at y
+func drawChartText(img draw.Image, text string, x, y int) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{summaryChartText},
+		Face: basicfont.Face7x13,
+	}
+	drawer.Dot = fixed.P(x-drawer.MeasureString(text).Round()/2, y)
+	drawer.DrawString(text)
+}
+
+// summaryChartDescription is the alt-text of the chart, listing the same numbers it shows
+func summaryChartDescription(title string, counts []int, labels []string) string {
+	days := make([]string, len(counts))
+	for i, count := range counts {
+		days[i] = fmt.Sprintf("%s %d", labels[i], count)
+	}
+	return fmt.Sprintf("Bar chart: %s. %s.", title, strings.Join(days, ", "))
+}
+
+// uploadSummaryChart renders the chart of the past week and uploads it with its alt-text
+func uploadSummaryChart(c MastodonClient, ctx context.Context) (*mastodon.Attachment, error) {
+	entries, err := readLogEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	title := "Alt-texts generated per day"
+	counts, labels := dailyAltTextCounts(entries, time.Now())
+	chart, err := renderSummaryChart(title, counts, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.UploadMediaFromMedia(ctx, &mastodon.Media{
+		File:        bytes.NewReader(chart),
+		Description: summaryChartDescription(title, counts, labels),
+	})
+}
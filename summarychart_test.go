@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyAltTextCounts(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) // A Wednesday
+	day := func(daysAgo, hour int) time.Time {
+		return time.Date(2026, 10, 14-daysAgo, hour, 0, 0, 0, time.UTC)
+	}
+	entries := []LogEntry{
+		{Timestamp: day(0, 1), EventType: "alt_text_generated"},
+		{Timestamp: day(0, 11), EventType: "alt_text_generated"},
+		{Timestamp: day(1, 23), EventType: "alt_text_generated"},
+		{Timestamp: day(6, 0), EventType: "alt_text_generated"},
+		{Timestamp: day(7, 23), EventType: "alt_text_generated"},
+		{Timestamp: day(0, 2), EventType: "new_follower"},
+	}
+
+	counts, labels := dailyAltTextCounts(entries, now)
+
+	if want := []int{1, 0, 0, 0, 0, 1, 2}; !slices.Equal(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+	if want := []string{"Thu", "Fri", "Sat", "Sun", "Mon", "Tue", "Wed"}; !slices.Equal(labels, want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+}
+
+func TestDailyAltTextCountsAcrossDSTChange(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	// The clocks went back on Sunday, October 25, 2026, making it 25 hours long
+	now := time.Date(2026, 10, 27, 0, 30, 0, 0, berlin)
+	entries := []LogEntry{
+		{Timestamp: time.Date(2026, 10, 25, 23, 30, 0, 0, berlin), EventType: "alt_text_generated"},
+		{Timestamp: time.Date(2026, 10, 24, 0, 30, 0, 0, berlin), EventType: "alt_text_generated"},
+	}
+
+	counts, labels := dailyAltTextCounts(entries, now)
+
+	if want := []int{0, 0, 0, 1, 1, 0, 0}; !slices.Equal(counts, want) {
+		t.Errorf("counts = %v (%v), want %v", counts, labels, want)
+	}
+}
+
+func TestSummaryChartIsUploadedWithDescription(t *testing.T) {
+	writeEventLog(t, LogEntry{Timestamp: time.Now(), EventType: "alt_text_generated"})
+	c := newFakeClient()
+
+	attachment, err := uploadSummaryChart(c, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.uploaded) != 1 {
+		t.Fatalf("uploaded %d files, want the chart", len(c.uploaded))
+	}
+	chart, err := png.Decode(c.uploaded[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := chart.Bounds().Size(); size.X != summaryChartWidth || size.Y != summaryChartHeight {
+		t.Errorf("chart is %v, want %dx%d", size, summaryChartWidth, summaryChartHeight)
+	}
+	if attachment.Description == "" || !strings.HasSuffix(attachment.Description, " 1.") {
+		t.Errorf("description = %q, want today's count last", attachment.Description)
+	}
+}
+
+func TestSummaryChartDescription(t *testing.T) {
+	got := summaryChartDescription("Alt-texts generated per day", []int{3, 0, 5}, []string{"Mon", "Tue", "Wed"})
+	if want := "Bar chart: Alt-texts generated per day. Mon 3, Tue 0, Wed 5."; got != want {
+		t.Errorf("summaryChartDescription = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrTempStorageFull is returned when writing a temporary media file would exceed the configured limit
+var ErrTempStorageFull = errors.New("temporary storage limit reached")
+
+// TempFileTracker keeps track of the disk space used by temporary media files
+type TempFileTracker struct {
+	files map[string]int64
+	total int64
+	mu    sync.Mutex
+}
+
+var tempFiles = TempFileTracker{
+	files: make(map[string]int64),
+}
+
+// Reserve accounts for a new temporary file of the given size, returning false if
+// it would exceed the configured maximum temporary storage
+func (t *TempFileTracker) Reserve(path string, size int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limit := int64(config.ImageProcessing.MaxTempStorageMB) * 1024 * 1024
+	if limit > 0 && t.total+size > limit {
+		return false
+	}
+
+	t.files[path] = size
+	t.total += size
+	return true
+}
+
+// Release frees the space accounted for a temporary file
+func (t *TempFileTracker) Release(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if size, ok := t.files[path]; ok {
+		t.total -= size
+		delete(t.files, path)
+	}
+}
+
+// createTrackedTempFile writes data to a new temporary file if the storage limit allows it
+func createTrackedTempFile(pattern string, data []byte) (string, error) {
+	tmpFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	if !tempFiles.Reserve(tmpFile.Name(), int64(len(data))) {
+		os.Remove(tmpFile.Name())
+		return "", ErrTempStorageFull
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
+		removeTempFile(tmpFile.Name())
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+// removeTempFile deletes a temporary file and releases its accounted space
+func removeTempFile(path string) {
+	os.Remove(path)
+	tempFiles.Release(path)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestTempStorageLimitIsEnforced(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxTempStorageMB = 1 })
+
+	half := bytes.Repeat([]byte("a"), 512*1024)
+	first, err := createTrackedTempFile("test-*.bin", half)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { removeTempFile(first) })
+	second, err := createTrackedTempFile("test-*.bin", half)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The limit is reached, one more byte doesn't fit and leaves no file behind
+	if path, err := createTrackedTempFile("test-*.bin", []byte("a")); !errors.Is(err, ErrTempStorageFull) {
+		removeTempFile(path)
+		t.Fatalf("createTrackedTempFile = %v over the limit, want ErrTempStorageFull", err)
+	}
+
+	// Removing a file frees its space again
+	removeTempFile(second)
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Errorf("the removed file still exists: %v", err)
+	}
+	third, err := createTrackedTempFile("test-*.bin", half)
+	if err != nil {
+		t.Errorf("createTrackedTempFile = %v after space was freed", err)
+	}
+	removeTempFile(third)
+}
+
+func TestTempStorageWithoutLimit(t *testing.T) {
+	withConfig(t, func(c *Config) { c.ImageProcessing.MaxTempStorageMB = 0 })
+	tracker := TempFileTracker{files: make(map[string]int64)}
+
+	if !tracker.Reserve("huge", 1<<40) {
+		t.Error("a limit of 0 refused a file")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// maxDepthCacheEntries bounds the reply depth cache, it is cleared once full
+const maxDepthCacheEntries = 10000
+
+// replyDepthCache remembers how deep statuses are in their thread by instanceKey, so walking a thread
+// only fetches the statuses that haven't been seen before
+var replyDepthCache = make(map[string]int)
+var replyDepthMutex sync.Mutex
+
+// inReplyTo converts the InReplyToID of a status, which may be a string or an ID, to an ID
+func inReplyTo(ref interface{}) mastodon.ID {
+	switch id := ref.(type) {
+	case string:
+		return mastodon.ID(id)
+	case mastodon.ID:
+		return id
+	}
+	return ""
+}
+
+// replyDepth returns how many replies deep the status is in its thread, 0 for a top-level post.
+// The walk stops as soon as the depth exceeds limit.
+func replyDepth(c MastodonClient, status *mastodon.Status, limit int) int {
+	depth := 0
+	complete := true
+	var ancestors []mastodon.ID
+
+	current := status
+	for {
+		parentID := inReplyTo(current.InReplyToID)
+		if parentID == "" {
+			break
+		}
+
+		replyDepthMutex.Lock()
+		cached, ok := replyDepthCache[instanceKey(c, parentID)]
+		replyDepthMutex.Unlock()
+		if ok {
+			depth += cached + 1
+			break
+		}
+
+		depth++
+		if depth > limit {
+			return depth
+		}
+		ancestors = append(ancestors, parentID)
+
+		parent, err := fetchStatus(ctx, c, parentID)
+		if err != nil {
+			// A deleted or hidden parent ends the walk, but the depth is only a lower bound then
+			complete = false
+			break
+		}
+		current = parent
+	}
+
+	if complete {
+		replyDepthMutex.Lock()
+		if len(replyDepthCache)+len(ancestors) > maxDepthCacheEntries {
+			replyDepthCache = make(map[string]int)
+		}
+		for i, id := range ancestors {
+			replyDepthCache[instanceKey(c, id)] = depth - (i + 1)
+		}
+		replyDepthMutex.Unlock()
+	}
+
+	return depth
+}
+
+// isBelowOwnReply reports whether the status replies to a post of the bot within the given number of hops.
+// Describing such posts automatically could let the bot keep a thread going by itself.
+func isBelowOwnReply(c MastodonClient, status *mastodon.Status, hops int) bool {
+	current := status
+	for i := 0; i < hops; i++ {
+		parentID := inReplyTo(current.InReplyToID)
+		if parentID == "" {
+			return false
+		}
+
+		// Recent descriptions are known without fetching them
+		if _, ok := findOriginalForReply(c, parentID); ok {
+			return true
+		}
+
+		parent, err := fetchStatus(ctx, c, parentID)
+		if err != nil {
+			return false
+		}
+		if isSelf(c, &parent.Account) {
+			return true
+		}
+		current = parent
+	}
+	return false
+}
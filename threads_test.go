@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// botThread returns a client with a thread started by a reply of the bot: bot-reply ← first ← second
+func botThread(t *testing.T) *fakeClient {
+	t.Helper()
+	c := newFakeClient(
+		&mastodon.Status{ID: "post", Account: mastodon.Account{ID: "user"}},
+		&mastodon.Status{ID: "bot-reply", InReplyToID: "post", Account: mastodon.Account{ID: "bot"}},
+		&mastodon.Status{ID: "first", InReplyToID: "bot-reply", Account: mastodon.Account{ID: "user"}},
+		&mastodon.Status{ID: "second", InReplyToID: "first", Account: mastodon.Account{ID: "other"}},
+	)
+	key := instanceKey(c, "bot")
+	botAccountIDs[key] = true
+	t.Cleanup(func() { delete(botAccountIDs, key) })
+	return c
+}
+
+func TestIsBelowOwnReply(t *testing.T) {
+	c := botThread(t)
+	status := &mastodon.Status{ID: "third", InReplyToID: "second", Account: mastodon.Account{ID: "user"}}
+
+	tests := []struct {
+		hops int
+		want bool
+	}{
+		{1, false},
+		{2, false},
+		{3, true},
+		{5, true},
+	}
+	for _, tt := range tests {
+		if got := isBelowOwnReply(c, status, tt.hops); got != tt.want {
+			t.Errorf("isBelowOwnReply with %d hops = %v, want %v", tt.hops, got, tt.want)
+		}
+	}
+
+	if isBelowOwnReply(c, c.statuses["post"], 5) {
+		t.Error("a top-level post is below a reply of the bot")
+	}
+	if isBelowOwnReply(c, &mastodon.Status{ID: "reply", InReplyToID: "post"}, 5) {
+		t.Error("a reply to the user's post is below a reply of the bot")
+	}
+}
+
+func TestIsBelowOwnReplyKnowsRecentReplies(t *testing.T) {
+	c := botThread(t)
+	// The bot's reply can't be fetched, but it's one of the replies the bot remembers
+	delete(c.statuses, "bot-reply")
+	mapMutex.Lock()
+	replyMap[accountKey(c, "post")] = ReplyInfo{OriginalID: "post", ReplyID: "bot-reply"}
+	mapMutex.Unlock()
+	t.Cleanup(func() {
+		mapMutex.Lock()
+		delete(replyMap, accountKey(c, "post"))
+		mapMutex.Unlock()
+	})
+
+	if !isBelowOwnReply(c, c.statuses["second"], 2) {
+		t.Error("a reply two hops below a remembered reply of the bot wasn't recognized")
+	}
+}
+
+// useReplyDepthCache starts the test with an empty reply depth cache
+func useReplyDepthCache(t *testing.T) {
+	t.Helper()
+	replyDepthMutex.Lock()
+	saved := replyDepthCache
+	replyDepthCache = make(map[string]int)
+	replyDepthMutex.Unlock()
+	t.Cleanup(func() {
+		replyDepthMutex.Lock()
+		replyDepthCache = saved
+		replyDepthMutex.Unlock()
+	})
+}
+
+// replyChain returns a client with a thread of the given length, chain-0 being the top-level post,
+// and the last status of the thread
+func replyChain(length int) (*fakeClient, *mastodon.Status) {
+	c := newFakeClient()
+	var last *mastodon.Status
+	for i := 0; i < length; i++ {
+		status := &mastodon.Status{ID: mastodon.ID(fmt.Sprintf("chain-%d", i)), Account: mastodon.Account{ID: "user"}}
+		if i > 0 {
+			// Both types of InReplyToID the API client produces
+			if i%2 == 0 {
+				status.InReplyToID = string(last.ID)
+			} else {
+				status.InReplyToID = last.ID
+			}
+		}
+		c.statuses[status.ID] = status
+		last = status
+	}
+	return c, last
+}
+
+func TestReplyDepth(t *testing.T) {
+	tests := []struct {
+		length int
+		limit  int
+		want   int
+	}{
+		{1, 10, 0},
+		{2, 10, 1},
+		{6, 10, 5},
+		{11, 10, 10},
+		{30, 10, 11},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%d", tt.length, tt.limit), func(t *testing.T) {
+			useReplyDepthCache(t)
+			c, last := replyChain(tt.length)
+
+			if got := replyDepth(c, last, tt.limit); got != tt.want {
+				t.Errorf("replyDepth = %d, want %d", got, tt.want)
+			}
+			if len(c.fetched) > tt.limit {
+				t.Errorf("fetched %d statuses, want the walk stopped after %d", len(c.fetched), tt.limit)
+			}
+		})
+	}
+}
+
+func TestReplyDepthReusesWalkedThreads(t *testing.T) {
+	useReplyDepthCache(t)
+	c, last := replyChain(8)
+	if got := replyDepth(c, c.statuses["chain-4"], 10); got != 4 {
+		t.Fatalf("replyDepth(chain-4) = %d, want 4", got)
+	}
+	c.fetched = nil
+
+	if got := replyDepth(c, last, 10); got != 7 {
+		t.Errorf("replyDepth(chain-7) = %d, want 7", got)
+	}
+	// The ancestors of chain-4 are known from the first walk
+	if !slices.Equal(c.fetched, []mastodon.ID{"chain-6", "chain-5", "chain-4"}) {
+		t.Errorf("fetched %v, want only the statuses from chain-4 on", c.fetched)
+	}
+}
+
+func TestReplyDepthWithDeletedParent(t *testing.T) {
+	useReplyDepthCache(t)
+	c, last := replyChain(6)
+	delete(c.statuses, "chain-2")
+
+	if got := replyDepth(c, last, 10); got != 3 {
+		t.Errorf("replyDepth = %d, want the 3 replies up to the deleted post", got)
+	}
+
+	// The depth was only a lower bound, so it isn't remembered
+	c.statuses["chain-2"] = &mastodon.Status{ID: "chain-2", InReplyToID: "chain-1"}
+	if got := replyDepth(c, last, 10); got != 5 {
+		t.Errorf("replyDepth after the parent is back = %d, want 5", got)
+	}
+}
+
+func TestMentionsInDeepThreadsAreIgnored(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Behavior.MaxReplyDepth = 3
+		c.RateLimit.Enabled = false
+	})
+	useReplyDepthCache(t)
+	provider := &fakeProvider{response: "A white square"}
+	useProvider(t, provider)
+	c, last := replyChain(6)
+	last.MediaAttachments = []mastodon.Attachment{{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 4, 4, color.White))}}
+	mention := &mastodon.Status{ID: "deep-mention", Account: mastodon.Account{ID: "asker", Acct: "asker"}, InReplyToID: last.ID, Content: "<p>@altbot</p>", Language: "en"}
+	c.statuses[mention.ID] = mention
+	forgetReplies(t, c, last.ID)
+
+	handleMention(c, &mastodon.Notification{Type: "mention", Account: mention.Account, Status: mention})
+
+	if posted := c.postedToots(); len(posted) != 0 || provider.calls() != 0 {
+		t.Errorf("answered a mention 6 replies deep with %+v", posted)
+	}
+}
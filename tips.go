@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// tipStateFile remembers the tip of the last posted weekly summary
+const tipStateFile = "weekly_tip.json"
+
+// TipState is the persisted tip selection of the weekly summary
+type TipState struct {
+	LastIndex int `json:"last_index"`
+}
+
+// loadLastTipIndex returns the index of the last posted tip, or -1 if none was posted yet
+func loadLastTipIndex(filePath string) (int, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, nil
+		}
+		return -1, err
+	}
+
+	var state TipState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return -1, err
+	}
+	return state.LastIndex, nil
+}
+
+// saveLastTipIndex stores the index of the tip that was just posted
+func saveLastTipIndex(filePath string, index int) error {
+	data, err := json.Marshal(TipState{LastIndex: index})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// nextTipIndex picks the next of count tips after the last one. With weekly_summary.tip_selection
+// "rotate" the tips are posted in order, otherwise a random tip other than the last one is picked.
+func nextTipIndex(count, last int) int {
+	if count == 0 {
+		return -1
+	}
+
+	if strings.ToLower(config.WeeklySummary.TipSelection) == "rotate" {
+		return (last + 1) % count
+	}
+
+	// The last tip is unknown or no longer in the list, so every tip is fine
+	if last < 0 || last >= count || count == 1 {
+		return rand.Intn(count)
+	}
+
+	// Pick among the other tips by skipping over the last one
+	index := rand.Intn(count - 1)
+	if index >= last {
+		index++
+	}
+	return index
+}
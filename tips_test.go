@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNextTipIndexRotates(t *testing.T) {
+	withConfig(t, func(c *Config) { c.WeeklySummary.TipSelection = "rotate" })
+
+	tests := []struct {
+		count, last, want int
+	}{
+		{3, -1, 0},
+		{3, 0, 1},
+		{3, 2, 0},
+		{3, 7, 2},
+		{0, -1, -1},
+	}
+	for _, tt := range tests {
+		if got := nextTipIndex(tt.count, tt.last); got != tt.want {
+			t.Errorf("nextTipIndex(%d, %d) = %d, want %d", tt.count, tt.last, got, tt.want)
+		}
+	}
+}
+
+func TestNextTipIndexNeverRepeatsTheLastTip(t *testing.T) {
+	withConfig(t, func(c *Config) { c.WeeklySummary.TipSelection = "random" })
+
+	picked := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		index := nextTipIndex(3, 1)
+		if index == 1 || index < 0 || index >= 3 {
+			t.Fatalf("nextTipIndex(3, 1) = %d", index)
+		}
+		picked[index] = true
+	}
+	if !picked[0] || !picked[2] {
+		t.Errorf("picked %v, want both other tips", picked)
+	}
+	if index := nextTipIndex(1, 0); index != 0 {
+		t.Errorf("nextTipIndex(1, 0) = %d, want the only tip", index)
+	}
+}
+
+func TestLastTipIndexIsPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weekly_tip.json")
+	if index, err := loadLastTipIndex(path); err != nil || index != -1 {
+		t.Fatalf("loadLastTipIndex without a file = %d, %v, want -1", index, err)
+	}
+	if err := saveLastTipIndex(path, 4); err != nil {
+		t.Fatal(err)
+	}
+	if index, err := loadLastTipIndex(path); err != nil || index != 4 {
+		t.Errorf("loadLastTipIndex = %d, %v, want 4", index, err)
+	}
+}
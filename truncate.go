@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sentenceBoundaries end a clause in scripts without spaces between words, like Chinese and Japanese.
+// Unlike spaces, they are kept at the end of the truncated text.
+const sentenceBoundaries = "。、，！？；：」』）"
+
+// truncateAltText shortens the alt-text to at most maxChars characters, marking the cut with an
+// ellipsis. A limit of 0 or less disables it. The cut never splits a character or a grapheme cluster
+// like an emoji sequence, and with llm.truncate_at "word" (the default) it goes back to the last word
+// or clause boundary if there is one in the second half of the text.
+func truncateAltText(altText string, maxChars int) string {
+	runes := []rune(altText)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return altText
+	}
+
+	// Leave room for the ellipsis and keep grapheme clusters together
+	cut := graphemeBoundary(runes, maxChars-1)
+
+	if !strings.EqualFold(config.LLM.TruncateAt, "grapheme") {
+		for i := cut; i > cut/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+			if strings.ContainsRune(sentenceBoundaries, runes[i-1]) {
+				cut = i
+				break
+			}
+		}
+	}
+
+	return strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(".,;:", r)
+	}) + "…"
+}
+
+// graphemeBoundary moves the cut before the rune at index cut back until it doesn't split a grapheme
+// cluster. It covers combining marks, variation selectors, emoji modifiers, zero width joiner
+// sequences, emoji tag sequences and flags, which is what descriptions run into in practice.
+func graphemeBoundary(runes []rune, cut int) int {
+	for cut > 0 && cut < len(runes) && (continuesCluster(runes[cut]) || runes[cut-1] == '\u200d') {
+		cut--
+	}
+
+	// Flags are pairs of regional indicators, an odd number of them before the cut splits one
+	if cut < len(runes) && isRegionalIndicator(runes[cut]) {
+		indicators := 0
+		for i := cut - 1; i >= 0 && isRegionalIndicator(runes[i]); i-- {
+			indicators++
+		}
+		if indicators%2 == 1 {
+			cut--
+		}
+	}
+
+	return cut
+}
+
+// continuesCluster reports whether the rune belongs to the grapheme cluster of the rune before it
+func continuesCluster(r rune) bool {
+	switch {
+	case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), unicode.Is(unicode.Mc, r):
+		return true
+	case r == '\u200d': // Zero width joiner
+		return true
+	case r >= '\ufe00' && r <= '\ufe0f': // Variation selectors
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // Emoji skin tone modifiers
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // Emoji tag sequences, like subdivision flags
+		return true
+	}
+	return false
+}
+
+// isRegionalIndicator reports whether the rune is one of the letters that make up flag emoji
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
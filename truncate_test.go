@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestTruncateAltText(t *testing.T) {
+	tests := []struct {
+		name       string
+		truncateAt string
+		altText    string
+		maxChars   int
+		want       string
+	}{
+		{"short enough", "word", "A cat on a sofa", 20, "A cat on a sofa"},
+		{"disabled", "word", "A cat on a sofa", 0, "A cat on a sofa"},
+		{"word boundary", "word", "A cat sleeping on a sofa", 18, "A cat sleeping on…"},
+		{"no boundary in the second half", "word", "A cat sleeping on a sofa", 14, "A cat sleepin…"},
+		{"trailing punctuation", "word", "A cat, asleep on a sofa", 12, "A cat…"},
+		{"grapheme", "grapheme", "A cat sleeping on a sofa", 14, "A cat sleepin…"},
+		{"CJK clause", "word", "一只猫在沙发上睡觉，旁边有一只狗。", 12, "一只猫在沙发上睡觉，…"},
+		{"CJK without boundary", "word", "一只猫在沙发上睡觉旁边有一只狗", 8, "一只猫在沙发上…"},
+		{"skin tone", "grapheme", "Waving 👋🏽 hand", 9, "Waving…"},
+		{"zero width joiner", "grapheme", "A family 👨‍👩‍👧 at home", 13, "A family…"},
+		{"flag", "grapheme", "Flags 🇩🇪🇫🇷 flying", 9, "Flags 🇩🇪…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.LLM.TruncateAt = tt.truncateAt })
+
+			got := truncateAltText(tt.altText, tt.maxChars)
+			if got != tt.want {
+				t.Errorf("truncateAltText = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateAltText = %q is not valid UTF-8", got)
+			}
+			if tt.maxChars > 0 && utf8.RuneCountInString(got) > tt.maxChars {
+				t.Errorf("truncateAltText = %q is longer than %d characters", got, tt.maxChars)
+			}
+		})
+	}
+}
+
+func TestTruncateAltTextNeverSplitsEmoji(t *testing.T) {
+	withConfig(t, func(c *Config) { c.LLM.TruncateAt = "grapheme" })
+	clusters := []string{"👍🏿", "👩‍💻", "🏳️‍🌈", "🇯🇵", "é"}
+	altText := strings.Repeat(strings.Join(clusters, ""), 4)
+
+	for maxChars := 2; maxChars < utf8.RuneCountInString(altText); maxChars++ {
+		got := strings.TrimSuffix(truncateAltText(altText, maxChars), "…")
+		rest := got
+		for rest != "" {
+			found := false
+			for _, cluster := range clusters {
+				if strings.HasPrefix(rest, cluster) {
+					rest = strings.TrimPrefix(rest, cluster)
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("truncating to %d characters split a cluster: %q", maxChars, got)
+			}
+		}
+	}
+}
+
+func TestTruncationIncludesAddedText(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.LLM.MaxAltChars = 80
+		c.ImageProcessing.DescribeShape = true
+		c.ImageProcessing.DescribePalette = true
+	})
+	useProvider(t, &fakeProvider{response: strings.Repeat("A long description of a white square. ", 10)})
+
+	c := newFakeClient()
+	replyPost := &mastodon.Status{ID: "mention", Account: mastodon.Account{ID: "poster", Acct: "poster"}, Language: "en"}
+	attachment := mastodon.Attachment{ID: "a", Type: "image", URL: dataURI("image/png", testImage(t, 16, 9, color.White))}
+
+	altText, err := generateInLanguages(c, replyPost, attachment, func(imageURL, remoteURL, lang string) (string, error) {
+		return generateImageAltText(imageURL, remoteURL, lang, "", true, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := utf8.RuneCountInString(altText); n > 80 {
+		t.Errorf("alt-text is %d characters long, want at most 80: %q", n, altText)
+	}
+	if !strings.HasPrefix(altText, "Image: wide, 16:9, PNG.") || !strings.HasSuffix(altText, "…") {
+		t.Errorf("alt-text = %q, want the shape in front and the end cut off", altText)
+	}
+}
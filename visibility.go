@@ -0,0 +1,47 @@
+package main
+
+import "strings"
+
+// replyVisibilities lists the known visibilities from the most public to the most restrictive.
+// Levels the bot can't post with itself, like the "mutuals" and "limited" visibilities of some
+// forks, are replied to with postAs, which must never be less restrictive than the level itself.
+var replyVisibilities = []struct {
+	name   string
+	postAs string
+}{
+	{"public", "public"},
+	{"unlisted", "unlisted"},
+	{"private", "private"},
+	{"mutuals", "direct"},
+	{"limited", "direct"},
+	{"direct", "direct"},
+}
+
+// visibilityLevel returns the position of the visibility in replyVisibilities
+func visibilityLevel(visibility string) (int, bool) {
+	visibility = strings.ToLower(visibility)
+	for i, level := range replyVisibilities {
+		if level.name == visibility {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// mapReplyVisibility returns the visibility of the bot's reply, which is the more restrictive
+// of the configured reply visibility and the visibility of the original post
+func mapReplyVisibility(configured, original string) string {
+	// An unknown or empty configured visibility falls back to the default of "unlisted"
+	configuredLevel, ok := visibilityLevel(configured)
+	if !ok {
+		configuredLevel, _ = visibilityLevel("unlisted")
+	}
+
+	// The audience of an unknown original visibility can't be judged, so use the most restrictive one
+	originalLevel, ok := visibilityLevel(original)
+	if !ok {
+		return "direct"
+	}
+
+	return replyVisibilities[max(configuredLevel, originalLevel)].postAs
+}
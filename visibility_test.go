@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestMapReplyVisibility(t *testing.T) {
+	// want[configured][original], the configured visibility being the reply_visibility setting
+	want := map[string]map[string]string{
+		"public": {
+			"public": "public", "unlisted": "unlisted", "private": "private", "direct": "direct",
+			"mutuals": "direct", "limited": "direct", "": "direct", "bogus": "direct",
+		},
+		"unlisted": {
+			"public": "unlisted", "unlisted": "unlisted", "private": "private", "direct": "direct",
+			"mutuals": "direct", "limited": "direct", "": "direct", "bogus": "direct",
+		},
+		"private": {
+			"public": "private", "unlisted": "private", "private": "private", "direct": "direct",
+			"mutuals": "direct", "limited": "direct", "": "direct", "bogus": "direct",
+		},
+		"direct": {
+			"public": "direct", "unlisted": "direct", "private": "direct", "direct": "direct",
+			"mutuals": "direct", "limited": "direct", "": "direct", "bogus": "direct",
+		},
+		// Unknown settings fall back to "unlisted"
+		"": {
+			"public": "unlisted", "unlisted": "unlisted", "private": "private", "direct": "direct",
+			"mutuals": "direct", "limited": "direct", "": "direct", "bogus": "direct",
+		},
+		"bogus": {
+			"public": "unlisted", "unlisted": "unlisted", "private": "private", "direct": "direct",
+			"mutuals": "direct", "limited": "direct", "": "direct", "bogus": "direct",
+		},
+		// Matching ignores case
+		"Private": {
+			"PUBLIC": "private", "Unlisted": "private", "DIRECT": "direct",
+		},
+	}
+
+	for configured, originals := range want {
+		for original, expected := range originals {
+			if got := mapReplyVisibility(configured, original); got != expected {
+				t.Errorf("mapReplyVisibility(%q, %q) = %q, want %q", configured, original, got, expected)
+			}
+		}
+	}
+}
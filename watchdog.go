@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventWatchdog tracks when the last streaming event arrived, to detect a connection that stopped delivering events
+type EventWatchdog struct {
+	lastEvent time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// eventWatchdogs holds one watchdog per bot account by username, every account has its own stream
+var eventWatchdogs = make(map[string]*EventWatchdog)
+var eventWatchdogsMutex sync.Mutex
+
+// watchdogNow is the clock of new watchdogs
+var watchdogNow = time.Now
+
+// watchdogFor returns the watchdog of the account's stream, creating it on first use
+func watchdogFor(account string) *EventWatchdog {
+	eventWatchdogsMutex.Lock()
+	defer eventWatchdogsMutex.Unlock()
+
+	w, ok := eventWatchdogs[account]
+	if !ok {
+		w = &EventWatchdog{now: watchdogNow}
+		eventWatchdogs[account] = w
+	}
+	return w
+}
+
+// Touch records that an event arrived
+func (w *EventWatchdog) Touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastEvent = w.now()
+}
+
+// LastEvent returns when the last event arrived
+func (w *EventWatchdog) LastEvent() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastEvent
+}
+
+// Idle reports whether no event arrived within the timeout
+func (w *EventWatchdog) Idle(timeout time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.now().Sub(w.lastEvent) >= timeout
+}
+
+// lastEvents returns when the last event of each account's stream arrived
+func lastEvents() map[string]time.Time {
+	eventWatchdogsMutex.Lock()
+	defer eventWatchdogsMutex.Unlock()
+
+	events := make(map[string]time.Time, len(eventWatchdogs))
+	for account, w := range eventWatchdogs {
+		events[account] = w.LastEvent()
+	}
+	return events
+}
+
+// idleAccounts returns the accounts whose stream delivered no event within the timeout, sorted by username
+func idleAccounts(timeout time.Duration) []string {
+	eventWatchdogsMutex.Lock()
+	defer eventWatchdogsMutex.Unlock()
+
+	var idle []string
+	for account, w := range eventWatchdogs {
+		if w.Idle(timeout) {
+			idle = append(idle, account)
+		}
+	}
+	sort.Strings(idle)
+	return idle
+}
+
+// startIdleWatchdog exits the process when a stream delivered no event within the timeout, so a supervisor
+// like systemd or Docker can restart the bot with fresh streaming connections
+func startIdleWatchdog(timeout time.Duration) {
+	for account := range botAccounts {
+		watchdogFor(account).Touch()
+	}
+
+	interval := timeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+
+	for range time.Tick(interval) {
+		for _, account := range idleAccounts(timeout) {
+			log.Fatalf("No streaming events received for %s since %s, exiting so the bot can be restarted", account, watchdogFor(account).LastEvent().Format(time.RFC3339))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFakeWatchdogClock gives new watchdogs a clock that only moves when the returned function is called
+func useFakeWatchdogClock(t *testing.T) (advance func(time.Duration)) {
+	t.Helper()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	savedNow, savedWatchdogs := watchdogNow, eventWatchdogs
+	watchdogNow = func() time.Time { return now }
+	eventWatchdogs = make(map[string]*EventWatchdog)
+	t.Cleanup(func() { watchdogNow, eventWatchdogs = savedNow, savedWatchdogs })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestIdleStreamIsDetectedPerAccount(t *testing.T) {
+	advance := useFakeWatchdogClock(t)
+	watchdogFor("altbot").Touch()
+	watchdogFor("second").Touch()
+
+	advance(4 * time.Minute)
+	watchdogFor("altbot").Touch()
+	if idle := idleAccounts(5 * time.Minute); len(idle) != 0 {
+		t.Errorf("idle accounts = %v before the timeout", idle)
+	}
+
+	advance(2 * time.Minute)
+	if idle := idleAccounts(5 * time.Minute); len(idle) != 1 || idle[0] != "second" {
+		t.Errorf("idle accounts = %v, want only the account without events", idle)
+	}
+}
+
+func TestHealthzReportsIdleStreams(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Server.IdleTimeoutSeconds = 60 })
+	advance := useFakeWatchdogClock(t)
+	watchdogFor("altbot").Touch()
+
+	healthz := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return recorder
+	}
+
+	if recorder := healthz(); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "last event for altbot: 2024-03-01T12:00:00Z") {
+		t.Errorf("/healthz answered %d %q, want ok with the last event", recorder.Code, recorder.Body)
+	}
+
+	advance(2 * time.Minute)
+	if recorder := healthz(); recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), "no streaming events for altbot") {
+		t.Errorf("/healthz answered %d %q for an idle stream", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+
+	"golang.org/x/image/riff"
+	"golang.org/x/image/webp"
+)
+
+var (
+	webpFormType  = riff.FourCC{'W', 'E', 'B', 'P'}
+	webpChunkVP8X = riff.FourCC{'V', 'P', '8', 'X'}
+	webpChunkANMF = riff.FourCC{'A', 'N', 'M', 'F'}
+)
+
+const (
+	webpAnimationFlag = 1 << 1
+	webpAlphaFlag     = 1 << 4
+)
+
+// The canvas of an animation is allocated before any frame is decoded, and every frame up to the
+// last sampled one has to be composited, so both are capped
+const (
+	maxAnimationPixels = 4096 * 4096
+	maxAnimationFrames = 1000
+)
+
+var (
+	errInvalidAnimatedWebP  = errors.New("invalid animated webp")
+	errAnimatedWebPTooLarge = errors.New("animated webp is too large")
+)
+
+// isAnimatedWebP reports whether the data is an extended WebP file with the animation flag set
+func isAnimatedWebP(data []byte) bool {
+	return len(data) >= 21 &&
+		string(data[0:4]) == "RIFF" &&
+		string(data[8:12]) == "WEBP" &&
+		string(data[12:16]) == "VP8X" &&
+		data[20]&webpAnimationFlag != 0
+}
+
+// animatedWebPSize returns the canvas size from the header of an animated WebP
+func animatedWebPSize(data []byte) (int, int, bool) {
+	if !isAnimatedWebP(data) || len(data) < 30 {
+		return 0, 0, false
+	}
+	return int(readUint24(data[24:27])) + 1, int(readUint24(data[27:30])) + 1, true
+}
+
+// countWebPFrames returns the number of animation frames without decoding any of them
+func countWebPFrames(data []byte) (int, error) {
+	formType, reader, err := riff.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	if formType != webpFormType {
+		return 0, errInvalidAnimatedWebP
+	}
+
+	count := 0
+	for {
+		chunkID, _, _, err := reader.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if chunkID == webpChunkANMF {
+			count++
+		}
+	}
+}
+
+// decodeAnimatedWebP decodes up to n evenly spaced frames of an animated WebP, always including the
+// first one. Each frame is composited onto the full canvas, but only the sampled ones are kept.
+func decodeAnimatedWebP(data []byte, n int) ([]image.Image, error) {
+	frameCount, err := countWebPFrames(data)
+	if err != nil {
+		return nil, err
+	}
+	if frameCount == 0 {
+		return nil, errInvalidAnimatedWebP
+	}
+	if frameCount > maxAnimationFrames {
+		return nil, errAnimatedWebPTooLarge
+	}
+	sampled := sampleFrameIndexes(frameCount, n)
+
+	formType, reader, err := riff.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if formType != webpFormType {
+		return nil, errInvalidAnimatedWebP
+	}
+
+	var canvas *image.RGBA
+	var frames []image.Image
+	index := 0
+
+	for len(frames) < len(sampled) {
+		chunkID, _, chunkData, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch chunkID {
+		case webpChunkVP8X:
+			header, err := io.ReadAll(chunkData)
+			if err != nil {
+				return nil, err
+			}
+			if len(header) < 10 {
+				return nil, errInvalidAnimatedWebP
+			}
+			width := int(readUint24(header[4:7])) + 1
+			height := int(readUint24(header[7:10])) + 1
+			if width*height > maxAnimationPixels {
+				return nil, errAnimatedWebPTooLarge
+			}
+			canvas = image.NewRGBA(image.Rect(0, 0, width, height))
+
+		case webpChunkANMF:
+			if canvas == nil {
+				return nil, errInvalidAnimatedWebP
+			}
+			frameData, err := io.ReadAll(chunkData)
+			if err != nil {
+				return nil, err
+			}
+			if len(frameData) < 16 {
+				return nil, errInvalidAnimatedWebP
+			}
+
+			x := 2 * int(readUint24(frameData[0:3]))
+			y := 2 * int(readUint24(frameData[3:6]))
+			width := int(readUint24(frameData[6:9])) + 1
+			height := int(readUint24(frameData[9:12])) + 1
+			flags := frameData[15]
+
+			rect := image.Rect(x, y, x+width, y+height)
+			if !rect.In(canvas.Bounds()) {
+				return nil, errInvalidAnimatedWebP
+			}
+
+			frame, err := decodeWebPFrame(frameData[16:], width, height)
+			if err != nil {
+				return nil, err
+			}
+
+			// Blend the frame onto the canvas unless the "do not blend" flag is set
+			op := draw.Over
+			if flags&0x02 != 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, rect, frame, frame.Bounds().Min, op)
+
+			if index == sampled[len(frames)] {
+				snapshot := image.NewRGBA(canvas.Bounds())
+				copy(snapshot.Pix, canvas.Pix)
+				frames = append(frames, snapshot)
+			}
+			index++
+
+			// Dispose the frame area to transparent if requested
+			if flags&0x01 != 0 {
+				draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil, errInvalidAnimatedWebP
+	}
+
+	return frames, nil
+}
+
+// decodeWebPFrame wraps the chunks of a single animation frame into a standalone WebP and decodes it
+func decodeWebPFrame(frameChunks []byte, width, height int) (image.Image, error) {
+	var flags byte
+	if len(frameChunks) >= 4 && string(frameChunks[0:4]) == "ALPH" {
+		flags |= webpAlphaFlag
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = flags
+	putUint24(vp8x[4:7], uint32(width-1))
+	putUint24(vp8x[7:10], uint32(height-1))
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+len(vp8x)+len(frameChunks)))
+	buf.WriteString("WEBP")
+	buf.WriteString("VP8X")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(vp8x)))
+	buf.Write(vp8x)
+	buf.Write(frameChunks)
+
+	return webp.Decode(&buf)
+}
+
+// sampleFrameIndexes picks up to n evenly spaced frame indexes in increasing order, always including the first one
+func sampleFrameIndexes(count, n int) []int {
+	if n < 1 {
+		n = 1
+	}
+	if count < n {
+		n = count
+	}
+
+	indexes := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		indexes = append(indexes, i*count/n)
+	}
+	return indexes
+}
+
+// buildMontage arranges the frames in a grid so the whole animation can be described from one image
+func buildMontage(frames []image.Image) image.Image {
+	if len(frames) == 1 {
+		return frames[0]
+	}
+
+	columns := int(math.Ceil(math.Sqrt(float64(len(frames)))))
+	rows := (len(frames) + columns - 1) / columns
+	bounds := frames[0].Bounds()
+
+	montage := image.NewRGBA(image.Rect(0, 0, columns*bounds.Dx(), rows*bounds.Dy()))
+	for i, frame := range frames {
+		x := (i % columns) * bounds.Dx()
+		y := (i / columns) * bounds.Dy()
+		draw.Draw(montage, image.Rect(x, y, x+bounds.Dx(), y+bounds.Dy()), frame, frame.Bounds().Min, draw.Src)
+	}
+
+	return montage
+}
+
+func readUint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
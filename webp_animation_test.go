@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// encodeAnimatedWebP builds an animated WebP with one full-canvas frame per color
+func encodeAnimatedWebP(t *testing.T, width, height int, colors []color.Color) []byte {
+	t.Helper()
+
+	var chunks bytes.Buffer
+	writeChunk := func(id string, data []byte) {
+		chunks.WriteString(id)
+		binary.Write(&chunks, binary.LittleEndian, uint32(len(data)))
+		chunks.Write(data)
+		if len(data)%2 != 0 {
+			chunks.WriteByte(0)
+		}
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpAnimationFlag
+	putUint24(vp8x[4:7], uint32(width-1))
+	putUint24(vp8x[7:10], uint32(height-1))
+	writeChunk("VP8X", vp8x)
+	writeChunk("ANIM", make([]byte, 6))
+
+	for _, fill := range colors {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(img, img.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+		var still bytes.Buffer
+		if err := nativewebp.Encode(&still, img, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// The frame header is followed by the VP8L chunk of the still image
+		frame := make([]byte, 16)
+		putUint24(frame[6:9], uint32(width-1))
+		putUint24(frame[9:12], uint32(height-1))
+		frame[15] = 0x02 // don't blend
+		writeChunk("ANMF", append(frame, still.Bytes()[12:]...))
+	}
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(4+chunks.Len()))
+	file.WriteString("WEBP")
+	file.Write(chunks.Bytes())
+	return file.Bytes()
+}
+
+func TestDecodeAnimatedWebPSamplesFrames(t *testing.T) {
+	colors := []color.Color{
+		color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255},
+		color.RGBA{255, 255, 0, 255}, color.RGBA{0, 255, 255, 255}, color.RGBA{255, 0, 255, 255},
+	}
+	data := encodeAnimatedWebP(t, 4, 3, colors)
+	if !isAnimatedWebP(data) {
+		t.Fatal("test animation isn't recognised as animated WebP")
+	}
+
+	frames, err := decodeAnimatedWebP(data, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames 0, 2 and 4 of six
+	want := []color.Color{colors[0], colors[2], colors[4]}
+	if len(frames) != len(want) {
+		t.Fatalf("decoded %d frames, want %d", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		if frame.Bounds() != image.Rect(0, 0, 4, 3) {
+			t.Errorf("frame %d has bounds %v", i, frame.Bounds())
+		}
+		r, g, b, _ := frame.At(1, 1).RGBA()
+		wr, wg, wb, _ := want[i].RGBA()
+		if r != wr || g != wg || b != wb {
+			t.Errorf("frame %d is %v, want %v", i, frame.At(1, 1), want[i])
+		}
+	}
+}
+
+func TestDecodeAnimatedWebPRejectsHugeAnimations(t *testing.T) {
+	// The canvas size is only declared, nothing this size is ever encoded
+	huge := encodeAnimatedWebP(t, 2, 2, []color.Color{color.White})
+	putUint24(huge[24:27], 1<<24-1)
+	putUint24(huge[27:30], 1<<24-1)
+	if _, err := decodeAnimatedWebP(huge, 4); !errors.Is(err, errAnimatedWebPTooLarge) {
+		t.Errorf("huge canvas: error = %v, want errAnimatedWebPTooLarge", err)
+	}
+
+	colors := make([]color.Color, maxAnimationFrames+1)
+	for i := range colors {
+		colors[i] = color.White
+	}
+	if _, err := decodeAnimatedWebP(encodeAnimatedWebP(t, 1, 1, colors), 4); !errors.Is(err, errAnimatedWebPTooLarge) {
+		t.Errorf("too many frames: error = %v, want errAnimatedWebPTooLarge", err)
+	}
+}
+
+func TestSampleFrameIndexes(t *testing.T) {
+	tests := []struct {
+		count, n int
+		want     []int
+	}{
+		{1, 4, []int{0}},
+		{3, 4, []int{0, 1, 2}},
+		{8, 4, []int{0, 2, 4, 6}},
+		{10, 3, []int{0, 3, 6}},
+		{5, 0, []int{0}},
+	}
+	for _, tt := range tests {
+		if got := sampleFrameIndexes(tt.count, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("sampleFrameIndexes(%d, %d) = %v, want %v", tt.count, tt.n, got, tt.want)
+		}
+	}
+}
@@ -6,10 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mattn/go-mastodon"
@@ -20,57 +20,179 @@ type WeeklySummary struct {
 	NewUserCount int
 }
 
-func GenerateWeeklySummary(c *mastodon.Client, ctx context.Context) {
-	if !config.WeeklySummary.Enabled {
+func GenerateWeeklySummary(c MastodonClient, ctx context.Context) {
+	if !config.WeeklySummary.Enabled || isPaused() {
 		return
 	}
 
-	// Fetch data for the past week
-	summary := fetchWeeklyData()
-
-	// Calculate leaderboard
-	entries, err := readLogEntries()
+	// Select the tip, avoiding the one posted last week
+	lastTip, err := loadLastTipIndex(tipStateFile)
 	if err != nil {
-		log.Printf("Error reading log entries: %v", err)
-		return
+		log.Printf("Error loading last weekly tip: %v", err)
 	}
-	userScores := calculateLeaderboard(entries)
-	topUsers := getTopUsers(userScores)
+	tipIndex := nextTipIndex(len(config.WeeklySummary.Tips), lastTip)
 
-	// Format leaderboard
-	var leaderboardBuilder strings.Builder
-	for _, user := range topUsers {
-		leaderboardBuilder.WriteString(user + "\n")
+	toot := &mastodon.Toot{
+		Status:     renderWeeklySummary(ctx, tipIndex),
+		Visibility: "public",
 	}
-	leaderboard := leaderboardBuilder.String()
-
-	// Select a random tip from the list
-	tipOfTheWeek := config.WeeklySummary.Tips[rand.Intn(len(config.WeeklySummary.Tips))]
 
-	// Create the summary message using the template
-	message := strings.ReplaceAll(config.WeeklySummary.MessageTemplate, "{{alt_text_count}}", fmt.Sprintf("%d", summary.AltTextCount))
-	message = strings.ReplaceAll(message, "{{new_user_count}}", fmt.Sprintf("%d", summary.NewUserCount))
-	message = strings.ReplaceAll(message, "{{tip_of_the_week}}", tipOfTheWeek)
-	message = strings.ReplaceAll(message, "{{leaderboard}}", leaderboard)
+	// The chart is optional, without it the summary is posted as text only
+	if config.WeeklySummary.AttachChart {
+		if chart, err := uploadSummaryChart(c, ctx); err != nil {
+			log.Printf("Error attaching chart to weekly summary: %v", err)
+		} else {
+			toot.MediaIDs = []mastodon.ID{chart.ID}
+		}
+	}
 
 	// Post the summary
-	post, err := c.PostStatus(ctx, &mastodon.Toot{
-		Status:     message,
-		Visibility: "public",
-	})
+	post, err := postStatus(ctx, c, toot)
 	if err != nil {
 		log.Printf("Error posting weekly summary: %v", err)
 	} else {
 		log.Printf("Weekly summary posted! \nLink: %s", post.URL)
 		metricsManager.logWeeklySummary(config.Server.Username)
+		if tipIndex >= 0 {
+			if err := saveLastTipIndex(tipStateFile, tipIndex); err != nil {
+				log.Printf("Error saving last weekly tip: %v", err)
+			}
+		}
 	}
 }
 
+// renderWeeklySummary fills the summary template with the current statistics and the tip at tipIndex
+func renderWeeklySummary(ctx context.Context, tipIndex int) string {
+	// Gather the statistics, a failed or slow one doesn't hold up the rest of the summary
+	stats := gatherSummaryStats(ctx, weeklySummaryStats())
+
+	tipOfTheWeek := ""
+	if tipIndex >= 0 && tipIndex < len(config.WeeklySummary.Tips) {
+		tipOfTheWeek = config.WeeklySummary.Tips[tipIndex]
+	}
+
+	// Create the summary message using the template
+	message := config.WeeklySummary.MessageTemplate
+	for placeholder, value := range stats {
+		message = strings.ReplaceAll(message, "{{"+placeholder+"}}", value)
+	}
+	return strings.ReplaceAll(message, "{{tip_of_the_week}}", tipOfTheWeek)
+}
+
+// previewWeeklySummary renders the next weekly summary without posting it or remembering its tip
+func previewWeeklySummary(ctx context.Context) string {
+	lastTip, err := loadLastTipIndex(tipStateFile)
+	if err != nil {
+		log.Printf("Error loading last weekly tip: %v", err)
+	}
+	return renderWeeklySummary(ctx, nextTipIndex(len(config.WeeklySummary.Tips), lastTip))
+}
+
+// summaryStat is a statistic filling one placeholder of the weekly summary template
+type summaryStat struct {
+	placeholder string
+	fallback    string // Used when gathering the statistic fails or times out
+	gather      func() (string, error)
+}
+
+// weeklySummaryStats returns the statistics of the weekly summary. They share one read of the event log.
+func weeklySummaryStats() []summaryStat {
+	readLog := sync.OnceValues(readLogEntries)
+	return []summaryStat{
+		{"alt_text_count", "?", func() (string, error) {
+			entries, err := readLog()
+			return fmt.Sprintf("%d", fetchWeeklyData(entries).AltTextCount), err
+		}},
+		{"new_user_count", "?", func() (string, error) {
+			entries, err := readLog()
+			return fmt.Sprintf("%d", fetchWeeklyData(entries).NewUserCount), err
+		}},
+		{"leaderboard", "", func() (string, error) {
+			entries, err := readLog()
+			if err != nil {
+				return "", err
+			}
+
+			var leaderboardBuilder strings.Builder
+			for _, user := range getTopUsers(calculateLeaderboard(entries)) {
+				leaderboardBuilder.WriteString(user + "\n")
+			}
+			return leaderboardBuilder.String(), nil
+		}},
+	}
+}
+
+// gatherSummaryStats gathers the statistics with at most weekly_summary.stats_concurrency running at
+// once, giving up after weekly_summary.stats_timeout_seconds. Statistics that failed or didn't finish
+// in time get their fallback value, so a best-effort summary can still be posted.
+func gatherSummaryStats(ctx context.Context, stats []summaryStat) map[string]string {
+	if timeout := config.WeeklySummary.StatsTimeoutSeconds; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	concurrency := config.WeeklySummary.StatsConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+	for _, stat := range stats {
+		values[stat.placeholder] = stat.fallback
+	}
+
+	var wg sync.WaitGroup
+	for _, stat := range stats {
+		wg.Add(1)
+		go func(stat summaryStat) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				log.Printf("Skipping weekly summary statistic %s: %v", stat.placeholder, ctx.Err())
+				return
+			}
+
+			type result struct {
+				value string
+				err   error
+			}
+			done := make(chan result, 1)
+			go func() {
+				value, err := stat.gather()
+				done <- result{value, err}
+			}()
+
+			select {
+			case r := <-done:
+				if r.err != nil {
+					log.Printf("Error gathering weekly summary statistic %s: %v", stat.placeholder, r.err)
+					return
+				}
+				mu.Lock()
+				values[stat.placeholder] = r.value
+				mu.Unlock()
+			case <-ctx.Done():
+				log.Printf("Gave up on weekly summary statistic %s: %v", stat.placeholder, ctx.Err())
+			}
+		}(stat)
+	}
+	wg.Wait()
+
+	return values
+}
+
 func calculateLeaderboard(entries []LogEntry) map[string]int {
 	userScores := make(map[string]int)
 
 	for _, entry := range entries {
-		if entry.EventType == "human_written_alt_text" {
+		// Entries logged without a username can't be attributed to anyone
+		if entry.EventType == "human_written_alt_text" && entry.Username != "" {
 			userScores[entry.Username]++
 		}
 	}
@@ -101,7 +223,7 @@ func getTopUsers(userScores map[string]int) []string {
 	return topUsers
 }
 
-func startWeeklySummaryScheduler(c *mastodon.Client) {
+func startWeeklySummaryScheduler(c MastodonClient) {
 	for {
 		now := time.Now()
 		// Calculate the next scheduled time based on config
@@ -156,13 +278,8 @@ func parseDayOfWeek(day string) time.Weekday {
 	}
 }
 
-func fetchWeeklyData() WeeklySummary {
-	entries, err := readLogEntries()
-	if err != nil {
-		log.Printf("Error reading log entries: %v", err)
-		return WeeklySummary{}
-	}
-
+// fetchWeeklyData counts the alt-texts and new followers of the past week in the event log
+func fetchWeeklyData(entries []LogEntry) WeeklySummary {
 	oneWeekAgo := time.Now().AddDate(0, 0, -7)
 	altTextCount := 0
 	newUserCount := 0
@@ -216,32 +333,25 @@ type LogEntry struct {
 	Username  string    `json:"username,omitempty"`
 }
 
+// LogEvent appends an event without a username to the event log
 func LogEvent(eventType string) {
+	LogEventWithUsername(eventType, "")
+}
+
+// LogEventWithUsername appends an event to altbot_log.json. Depending on weekly_summary.analytics
+// the username is kept ("full"), dropped ("local") or nothing is logged at all ("off").
+func LogEventWithUsername(eventType, username string) {
 	if !config.WeeklySummary.Enabled {
 		return
 	}
-	entry := LogEntry{
-		Timestamp: time.Now(),
-		EventType: eventType,
-	}
 
-	file, err := os.OpenFile("altbot_log.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Printf("Error opening log file: %v", err)
+	switch strings.ToLower(config.WeeklySummary.Analytics) {
+	case "off":
 		return
+	case "local":
+		username = ""
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(entry); err != nil {
-		log.Printf("Error writing log entry: %v", err)
-	}
-}
-
-func LogEventWithUsername(eventType, username string) {
-	if !config.WeeklySummary.Enabled {
-		return
-	}
 	entry := LogEntry{
 		Timestamp: time.Now(),
 		EventType: eventType,
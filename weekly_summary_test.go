@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeEventLog replaces the event log with the entries
+func writeEventLog(t *testing.T, entries ...LogEntry) {
+	t.Helper()
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile("altbot_log.json", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove("altbot_log.json") })
+}
+
+func TestGatherSummaryStatsFallsBackOnFailureAndTimeout(t *testing.T) {
+	withConfig(t, func(c *Config) { c.WeeklySummary.StatsConcurrency = 2 })
+	blocked := make(chan struct{})
+	t.Cleanup(func() { close(blocked) })
+
+	stats := []summaryStat{
+		{"fast", "?", func() (string, error) { return "42", nil }},
+		{"failing", "?", func() (string, error) { return "", errors.New("log unavailable") }},
+		{"stuck", "-", func() (string, error) { <-blocked; return "never", nil }},
+		{"queued", "!", func() (string, error) { <-blocked; return "never", nil }},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	values := gatherSummaryStats(ctx, stats)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("gathering took %v", elapsed)
+	}
+	want := map[string]string{"fast": "42", "failing": "?", "stuck": "-", "queued": "!"}
+	for placeholder, value := range want {
+		if values[placeholder] != value {
+			t.Errorf("%s = %q, want %q", placeholder, values[placeholder], value)
+		}
+	}
+}
+
+func TestWeeklySummaryStatsReadTheLogOnce(t *testing.T) {
+	withConfig(t, func(c *Config) { c.WeeklySummary.StatsConcurrency = 1 })
+	now := time.Now()
+	writeEventLog(t,
+		LogEntry{Timestamp: now, EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now, EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now.AddDate(0, 0, -10), EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now, EventType: "new_follower"},
+		LogEntry{Timestamp: now, EventType: "human_written_alt_text", Username: "alice"},
+	)
+
+	var reads atomic.Int32
+	stats := weeklySummaryStats()
+	for i, stat := range stats {
+		gather := stat.gather
+		stats[i].gather = func() (string, error) {
+			// Deleting the log after the first statistic fails any statistic reading it again
+			value, err := gather()
+			if reads.Add(1) == 1 {
+				os.Remove("altbot_log.json")
+			}
+			return value, err
+		}
+	}
+	values := gatherSummaryStats(context.Background(), stats)
+
+	if values["alt_text_count"] != "2" || values["new_user_count"] != "1" {
+		t.Errorf("counts = %q alt-texts, %q new users, want 2 and 1", values["alt_text_count"], values["new_user_count"])
+	}
+	if !strings.Contains(values["leaderboard"], "@alice (1 alt-texts)") {
+		t.Errorf("leaderboard = %q", values["leaderboard"])
+	}
+}
+
+func TestWeeklySummaryIsPostedWithoutEventLog(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WeeklySummary.Enabled = true
+		c.WeeklySummary.MessageTemplate = "{{alt_text_count}} alt-texts, {{new_user_count}} new users"
+	})
+	os.Remove("altbot_log.json")
+	c := newFakeClient()
+
+	GenerateWeeklySummary(c, context.Background())
+
+	posted := c.postedToots()
+	if len(posted) != 1 || posted[0].Status != "? alt-texts, ? new users" {
+		t.Errorf("posted %+v, want a summary with fallback values", posted)
+	}
+}
+
+func TestEventLogFollowsAnalyticsSetting(t *testing.T) {
+	tests := []struct {
+		analytics string
+		logged    bool
+		username  string
+	}{
+		{"full", true, "alice"},
+		{"local", true, ""},
+		{"off", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.analytics, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.WeeklySummary.Enabled = true
+				c.WeeklySummary.Analytics = tt.analytics
+			})
+			writeEventLog(t)
+
+			LogEventWithUsername("human_written_alt_text", "alice")
+
+			entries, err := readLogEntries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logged := len(entries) == 1; logged != tt.logged {
+				t.Fatalf("logged %d entries, want logged: %v", len(entries), tt.logged)
+			}
+			if tt.logged && entries[0].Username != tt.username {
+				t.Errorf("username = %q, want %q", entries[0].Username, tt.username)
+			}
+		})
+	}
+}
+
+func TestRenderWeeklySummary(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WeeklySummary.MessageTemplate = "{{alt_text_count}} alt-texts and {{new_user_count}} new users.\n{{leaderboard}}Tip: {{tip_of_the_week}} {{unknown}}"
+		c.WeeklySummary.Tips = []string{"Describe the text in images.", "Keep it short."}
+	})
+	now := time.Now()
+	writeEventLog(t,
+		LogEntry{Timestamp: now, EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now, EventType: "new_follower"},
+		LogEntry{Timestamp: now, EventType: "human_written_alt_text", Username: "alice"},
+		LogEntry{Timestamp: now, EventType: "human_written_alt_text", Username: "alice"},
+		LogEntry{Timestamp: now, EventType: "human_written_alt_text", Username: "bob"},
+	)
+
+	tests := []struct {
+		tipIndex int
+		want     string
+	}{
+		{1, "1 alt-texts and 1 new users.\n1. @alice (2 alt-texts)\n2. @bob (1 alt-texts)\nTip: Keep it short. {{unknown}}"},
+		{-1, "1 alt-texts and 1 new users.\n1. @alice (2 alt-texts)\n2. @bob (1 alt-texts)\nTip:  {{unknown}}"},
+		{5, "1 alt-texts and 1 new users.\n1. @alice (2 alt-texts)\n2. @bob (1 alt-texts)\nTip:  {{unknown}}"},
+	}
+	for _, tt := range tests {
+		if got := renderWeeklySummary(context.Background(), tt.tipIndex); got != tt.want {
+			t.Errorf("renderWeeklySummary(%d) = %q, want %q", tt.tipIndex, got, tt.want)
+		}
+	}
+}
+
+func TestPreviewMatchesThePostedSummary(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WeeklySummary.Enabled = true
+		c.WeeklySummary.MessageTemplate = "{{alt_text_count}} alt-texts. Tip: {{tip_of_the_week}}"
+		c.WeeklySummary.Tips = []string{"First tip.", "Second tip.", "Third tip."}
+		c.WeeklySummary.TipSelection = "rotate"
+	})
+	writeEventLog(t, LogEntry{Timestamp: time.Now(), EventType: "alt_text_generated"})
+	if err := saveLastTipIndex(tipStateFile, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(tipStateFile) })
+	c := newFakeClient()
+
+	// Previewing neither posts nor moves on to the next tip
+	preview := previewWeeklySummary(context.Background())
+	if again := previewWeeklySummary(context.Background()); again != preview {
+		t.Errorf("the second preview = %q, want %q again", again, preview)
+	}
+	if posted := c.postedToots(); len(posted) != 0 {
+		t.Errorf("the preview posted %+v", posted)
+	}
+	if preview != "1 alt-texts. Tip: Second tip." {
+		t.Errorf("preview = %q", preview)
+	}
+
+	GenerateWeeklySummary(c, context.Background())
+	if posted := c.postedToots(); len(posted) != 1 || posted[0].Status != preview {
+		t.Fatalf("posted %+v, want the previewed summary", posted)
+	}
+	if next := previewWeeklySummary(context.Background()); next != "1 alt-texts. Tip: Third tip." {
+		t.Errorf("preview after posting = %q, want the next tip", next)
+	}
+}